@@ -0,0 +1,185 @@
+// Package cache provides in-process caching shared by the Redis-backed caches.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mycobrun/cobrun-shared/telemetry"
+)
+
+// LRUConfig bounds an LRU cache.
+type LRUConfig struct {
+	// MaxEntries is the number of entries kept; the least recently used is
+	// evicted beyond it. 0 means unbounded.
+	MaxEntries int
+	// TTL caps how long an entry is kept. 0 means entries are kept until
+	// evicted, or for the ttl they were set with.
+	TTL time.Duration
+}
+
+// DefaultLRUConfig returns sensible defaults for a hot key cache.
+func DefaultLRUConfig() LRUConfig {
+	return LRUConfig{
+		MaxEntries: 10000,
+		TTL:        30 * time.Second,
+	}
+}
+
+// LRU is an in-process least recently used cache bounded by size and age,
+// safe for concurrent use.
+type LRU struct {
+	config LRUConfig
+
+	mu    sync.Mutex
+	order *list.List // front is most recently used
+	items map[string]*list.Element
+
+	metrics *TierMetrics
+	now     func() time.Time
+}
+
+type lruEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time // zero if the entry does not expire
+}
+
+// NewLRU creates an LRU cache.
+func NewLRU(config LRUConfig) *LRU {
+	return &LRU{
+		config: config,
+		order:  list.New(),
+		items:  make(map[string]*list.Element),
+		now:    time.Now,
+	}
+}
+
+// TierMetrics records the metrics of one cache tier. A nil TierMetrics
+// records nothing.
+type TierMetrics struct {
+	metrics *telemetry.CacheMetrics
+	tier    string
+}
+
+// NewTierMetrics returns metrics for tier, or nil if metrics is nil.
+func NewTierMetrics(metrics *telemetry.CacheMetrics, tier string) *TierMetrics {
+	if metrics == nil {
+		return nil
+	}
+	return &TierMetrics{metrics: metrics, tier: tier}
+}
+
+// Hit records a cache hit.
+func (m *TierMetrics) Hit() {
+	if m != nil {
+		m.metrics.RecordHit(context.Background(), m.tier)
+	}
+}
+
+// Miss records a cache miss.
+func (m *TierMetrics) Miss() {
+	if m != nil {
+		m.metrics.RecordMiss(context.Background(), m.tier)
+	}
+}
+
+// Evicted records an eviction.
+func (m *TierMetrics) Evicted() {
+	if m != nil {
+		m.metrics.RecordEviction(context.Background(), m.tier)
+	}
+}
+
+// WithMetrics records hits, misses and evictions as the "local" tier.
+func (c *LRU) WithMetrics(metrics *telemetry.CacheMetrics) *LRU {
+	c.metrics = NewTierMetrics(metrics, "local")
+	return c
+}
+
+// Get returns the value for key and marks it as recently used.
+func (c *LRU) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		c.metrics.Miss()
+		return nil, false
+	}
+	entry := elem.Value.(*lruEntry)
+	if !entry.expiresAt.IsZero() && !c.now().Before(entry.expiresAt) {
+		c.remove(elem)
+		c.metrics.Miss()
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	c.metrics.Hit()
+	return entry.value, true
+}
+
+// Set stores value for ttl, capped by the configured TTL. A ttl of 0 uses
+// the configured TTL; a negative ttl removes the key.
+func (c *LRU) Set(key string, value []byte, ttl time.Duration) {
+	if ttl < 0 {
+		c.Delete(key)
+		return
+	}
+	if ttl == 0 || (c.config.TTL > 0 && ttl > c.config.TTL) {
+		ttl = c.config.TTL
+	}
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = c.now().Add(ttl)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*lruEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry{key: key, value: value, expiresAt: expiresAt})
+
+	for c.config.MaxEntries > 0 && c.order.Len() > c.config.MaxEntries {
+		c.remove(c.order.Back())
+		c.metrics.Evicted()
+	}
+}
+
+// Delete removes keys.
+func (c *LRU) Delete(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		if elem, ok := c.items[key]; ok {
+			c.remove(elem)
+		}
+	}
+}
+
+// Purge removes every entry.
+func (c *LRU) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.items = make(map[string]*list.Element)
+}
+
+// Len returns the number of entries, including expired ones not yet removed.
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *LRU) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.items, elem.Value.(*lruEntry).key)
+}
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/mycobrun/cobrun-shared/telemetry"
+)
+
+func TestLRU_EvictsLeastRecentlyUsed(t *testing.T) {
+	lru := NewLRU(LRUConfig{MaxEntries: 2})
+	lru.Set("a", []byte("1"), 0)
+	lru.Set("b", []byte("2"), 0)
+	lru.Get("a")
+	lru.Set("c", []byte("3"), 0)
+
+	if _, ok := lru.Get("b"); ok {
+		t.Error("expected the least recently used key to be evicted")
+	}
+	if v, ok := lru.Get("a"); !ok || string(v) != "1" {
+		t.Errorf("expected a to be kept, got %q, %v", v, ok)
+	}
+	if lru.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", lru.Len())
+	}
+}
+
+func TestLRU_TTL(t *testing.T) {
+	now := time.Now()
+	lru := NewLRU(LRUConfig{TTL: time.Minute})
+	lru.now = func() time.Time { return now }
+
+	lru.Set("capped", []byte("x"), time.Hour)
+	lru.Set("short", []byte("x"), 10*time.Second)
+	lru.Set("default", []byte("x"), 0)
+	lru.Set("expired", []byte("x"), -time.Second)
+
+	now = now.Add(30 * time.Second)
+	if _, ok := lru.Get("short"); ok {
+		t.Error("expected the entry's own ttl to apply")
+	}
+	if _, ok := lru.Get("expired"); ok {
+		t.Error("a negative ttl should not be stored")
+	}
+	now = now.Add(31 * time.Second)
+	for _, key := range []string{"capped", "default"} {
+		if _, ok := lru.Get(key); ok {
+			t.Errorf("%s: expected the configured TTL to cap the entry", key)
+		}
+	}
+	if lru.Len() != 0 {
+		t.Errorf("expected expired entries to be removed, got %d", lru.Len())
+	}
+}
+
+func TestLRU_Metrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+	metrics, err := telemetry.NewCacheMetrics(meter, "test")
+	if err != nil {
+		t.Fatalf("NewCacheMetrics failed: %v", err)
+	}
+
+	lru := NewLRU(LRUConfig{MaxEntries: 1}).WithMetrics(metrics)
+	lru.Set("a", []byte("1"), 0)
+	lru.Get("a")
+	lru.Get("b")
+	lru.Set("b", []byte("2"), 0)
+
+	var data metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &data); err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	counts := map[string]int64{}
+	for _, scope := range data.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			for _, point := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				if tier, _ := point.Attributes.Value("tier"); tier.AsString() == "local" {
+					counts[m.Name] += point.Value
+				}
+			}
+		}
+	}
+	for _, name := range []string{"cache_test_hits_total", "cache_test_misses_total", "cache_test_evictions_total"} {
+		if counts[name] != 1 {
+			t.Errorf("expected %s=1, got %d", name, counts[name])
+		}
+	}
+}
@@ -38,6 +38,12 @@ type Config struct {
 	RedisPassword       string
 	SQLConnectionString string
 
+	// Redis topology
+	RedisMode            string   // standalone, cluster or sentinel
+	RedisAddrs           []string // cluster seed nodes or sentinels
+	RedisMasterName      string   // sentinel master set name
+	RedisReadFromReplica bool     // send read-only commands to replicas
+
 	// JWT
 	JWTSecret   string
 	JWTIssuer   string
@@ -79,6 +85,12 @@ func Load(serviceName string) (*Config, error) {
 		RateLimitBurst:     getEnvInt("RATE_LIMIT_BURST", 200),
 	}
 
+	// Redis topology is not secret, so it comes from the environment either way
+	cfg.RedisMode = getEnv("REDIS_MODE", "standalone")
+	cfg.RedisAddrs = getEnvSlice("REDIS_ADDRS", "")
+	cfg.RedisMasterName = getEnv("REDIS_MASTER_NAME", "")
+	cfg.RedisReadFromReplica = getEnvBool("REDIS_READ_FROM_REPLICA", false)
+
 	// Load secrets from Key Vault in production
 	if cfg.KeyVaultName != "" && cfg.Environment != "development" {
 		if err := cfg.loadFromKeyVault(context.Background()); err != nil {
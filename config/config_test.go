@@ -417,6 +417,40 @@ func TestLoad_CustomCORSOrigins(t *testing.T) {
 	}
 }
 
+func TestLoad_RedisTopology(t *testing.T) {
+	originalEnv := os.Getenv("ENVIRONMENT")
+	originalMode := os.Getenv("REDIS_MODE")
+	originalAddrs := os.Getenv("REDIS_ADDRS")
+	originalReplica := os.Getenv("REDIS_READ_FROM_REPLICA")
+
+	os.Setenv("ENVIRONMENT", "development")
+	os.Setenv("REDIS_MODE", "cluster")
+	os.Setenv("REDIS_ADDRS", "redis-0:6379, redis-1:6379")
+	os.Setenv("REDIS_READ_FROM_REPLICA", "true")
+
+	defer func() {
+		os.Setenv("ENVIRONMENT", originalEnv)
+		os.Setenv("REDIS_MODE", originalMode)
+		os.Setenv("REDIS_ADDRS", originalAddrs)
+		os.Setenv("REDIS_READ_FROM_REPLICA", originalReplica)
+	}()
+
+	cfg, err := Load("test-service")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.RedisMode != "cluster" {
+		t.Errorf("RedisMode = %s, want cluster", cfg.RedisMode)
+	}
+	if len(cfg.RedisAddrs) != 2 || cfg.RedisAddrs[1] != "redis-1:6379" {
+		t.Errorf("RedisAddrs = %v, want [redis-0:6379 redis-1:6379]", cfg.RedisAddrs)
+	}
+	if !cfg.RedisReadFromReplica {
+		t.Error("RedisReadFromReplica should be true")
+	}
+}
+
 func TestLoad_Version(t *testing.T) {
 	originalEnv := os.Getenv("ENVIRONMENT")
 	originalVersion := os.Getenv("VERSION")
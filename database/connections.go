@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/mycobrun/cobrun-shared/config"
@@ -31,6 +32,8 @@ type ConnectionConfig struct {
 	SQLPassword   string
 	SQLUseMSI     bool
 	SQLConnString string
+	SQLReadReplica bool   // route reads marked with WithReadReplica to a read-only replica
+	SQLReplicaHost string // defaults to SQLHost (Azure SQL read scale-out)
 
 	// Cosmos DB
 	CosmosEndpoint string
@@ -41,6 +44,10 @@ type ConnectionConfig struct {
 	RedisHost     string
 	RedisPassword string
 	RedisDB       int
+	RedisMode            RedisMode // standalone, cluster or sentinel
+	RedisAddrs           []string  // cluster seed nodes or sentinels; defaults to RedisHost
+	RedisMasterName      string    // sentinel master set name
+	RedisReadFromReplica bool      // send read-only commands to replicas
 
 	// Connection options
 	MaxRetries    int
@@ -59,6 +66,8 @@ func DefaultConnectionConfig() *ConnectionConfig {
 		SQLPassword:   getEnv("SQL_PASSWORD", ""),
 		SQLUseMSI:     getEnvBool("SQL_USE_MSI", false),
 		SQLConnString: os.Getenv("SQL_CONNECTION_STRING"),
+		SQLReadReplica: getEnvBool("SQL_READ_REPLICA", false),
+		SQLReplicaHost: getEnv("SQL_REPLICA_HOST", ""),
 
 		// Cosmos DB
 		CosmosEndpoint: getEnv("COSMOSDB_ENDPOINT", ""),
@@ -69,6 +78,10 @@ func DefaultConnectionConfig() *ConnectionConfig {
 		RedisHost:     getEnv("REDIS_HOST", "localhost:6379"),
 		RedisPassword: getEnv("REDIS_PASSWORD", ""),
 		RedisDB:       getEnvInt("REDIS_DB", 0),
+		RedisMode:            RedisMode(getEnv("REDIS_MODE", string(RedisModeStandalone))),
+		RedisAddrs:           getEnvList("REDIS_ADDRS"),
+		RedisMasterName:      getEnv("REDIS_MASTER_NAME", ""),
+		RedisReadFromReplica: getEnvBool("REDIS_READ_FROM_REPLICA", false),
 
 		// Options
 		MaxRetries:  getEnvInt("DB_MAX_RETRIES", 5),
@@ -93,6 +106,10 @@ func ConnectionConfigFromConfig(cfg *config.Config) *ConnectionConfig {
 		RedisHost:     cfg.RedisHost,
 		RedisPassword: cfg.RedisPassword,
 		RedisDB:       0,
+		RedisMode:            RedisMode(cfg.RedisMode),
+		RedisAddrs:           cfg.RedisAddrs,
+		RedisMasterName:      cfg.RedisMasterName,
+		RedisReadFromReplica: cfg.RedisReadFromReplica,
 
 		// Options
 		MaxRetries:  getEnvInt("DB_MAX_RETRIES", 5),
@@ -127,6 +144,9 @@ func NewConnections(ctx context.Context, config *ConnectionConfig) (*Connections
 			User:     config.SQLUser,
 			Password: config.SQLPassword,
 			UseMSI:   config.SQLUseMSI,
+
+			ReadReplica: config.SQLReadReplica,
+			ReplicaHost: config.SQLReplicaHost,
 		}
 
 		var err error
@@ -172,11 +192,15 @@ func NewConnections(ctx context.Context, config *ConnectionConfig) (*Connections
 	}
 
 	// Connect to Redis if configured
-	if config.RedisHost != "" {
+	if config.RedisHost != "" || len(config.RedisAddrs) > 0 {
 		redisConfig := RedisConfig{
 			Host:     config.RedisHost,
 			Password: config.RedisPassword,
 			DB:       config.RedisDB,
+			Mode:       config.RedisMode,
+			Addrs:      config.RedisAddrs,
+			MasterName: config.RedisMasterName,
+			ReadOnly:   config.RedisReadFromReplica,
 		}
 
 		var err error
@@ -298,6 +322,16 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func getEnvBool(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		return value == "true" || value == "1" || value == "yes"
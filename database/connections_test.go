@@ -4,6 +4,8 @@ import (
 	"os"
 	"testing"
 	"time"
+
+	"github.com/mycobrun/cobrun-shared/config"
 )
 
 func TestDefaultConnectionConfig(t *testing.T) {
@@ -57,6 +59,27 @@ func TestDefaultConnectionConfig(t *testing.T) {
 	}
 }
 
+func TestConnectionConfigFromConfig_Redis(t *testing.T) {
+	os.Setenv("REDIS_MODE", "sentinel")
+	defer os.Unsetenv("REDIS_MODE")
+
+	cfg := &config.Config{
+		RedisHost:            "redis:6379",
+		RedisMode:            "cluster",
+		RedisAddrs:           []string{"redis-0:6379", "redis-1:6379"},
+		RedisReadFromReplica: true,
+	}
+	connConfig := ConnectionConfigFromConfig(cfg)
+
+	// Only cfg is read, not the environment
+	if connConfig.RedisMode != RedisModeCluster {
+		t.Errorf("expected cluster mode, got %s", connConfig.RedisMode)
+	}
+	if len(connConfig.RedisAddrs) != 2 || !connConfig.RedisReadFromReplica {
+		t.Errorf("unexpected Redis topology %v, replica reads %v", connConfig.RedisAddrs, connConfig.RedisReadFromReplica)
+	}
+}
+
 func TestDefaultConnectionConfig_WithEnvVars(t *testing.T) {
 	// Save and restore env vars
 	origVars := map[string]string{
@@ -2,23 +2,17 @@
 package database
 
 import (
-	"bytes"
 	"context"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"net/url"
-	"strings"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
+	"github.com/mycobrun/cobrun-shared/telemetry"
 )
 
 // CosmosConfig holds Cosmos DB configuration.
@@ -30,10 +24,15 @@ type CosmosConfig struct {
 }
 
 // CosmosClient wraps the Azure Cosmos DB client.
+//
+// All data access goes through the azcosmos SDK. Errors are returned as
+// *errors.AppError (see mapCosmosError) and every request reports its
+// CosmosDiagnostics to the client's metrics and OnOperation hook.
 type CosmosClient struct {
 	client   *azcosmos.Client
 	database *azcosmos.DatabaseClient
 	config   CosmosConfig
+	observer *cosmosObserver
 }
 
 // NewCosmosClient creates a new Cosmos DB client.
@@ -72,21 +71,57 @@ func NewCosmosClient(ctx context.Context, config CosmosConfig) (*CosmosClient, e
 		client:   client,
 		database: database,
 		config:   config,
+		observer: &cosmosObserver{},
 	}, nil
 }
 
+// WithMetrics records the latency, errors and request charge of every
+// operation. Call it during setup, before the client is used.
+func (c *CosmosClient) WithMetrics(metrics *telemetry.DatabaseMetrics) *CosmosClient {
+	c.observer.metrics = metrics
+	return c
+}
+
+// OnOperation registers a hook called with the diagnostics of every
+// operation, e.g. to log slow or expensive requests. Call it during setup,
+// before the client is used.
+func (c *CosmosClient) OnOperation(fn func(ctx context.Context, diag CosmosDiagnostics)) *CosmosClient {
+	c.observer.onOperation = fn
+	return c
+}
+
 // Container returns a container client.
 func (c *CosmosClient) Container(name string) (*CosmosContainer, error) {
 	container, err := c.database.NewContainer(name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get container %s: %w", name, err)
 	}
-	return &CosmosContainer{container: container}, nil
+	return &CosmosContainer{container: container, name: name, observer: c.observer}, nil
+}
+
+// Container is the item API of a Cosmos DB container. *CosmosContainer
+// implements it against Azure; mocks.MockCosmosContainer is an in-memory
+// implementation for unit tests.
+type Container interface {
+	Create(ctx context.Context, partitionKey string, item interface{}) error
+	Read(ctx context.Context, partitionKey, id string, result interface{}) error
+	ReadWithETag(ctx context.Context, partitionKey, id string, result interface{}) (string, error)
+	Replace(ctx context.Context, partitionKey, id string, item interface{}, opts ...ItemOption) error
+	ReplaceIfMatch(ctx context.Context, partitionKey, id, etag string, item interface{}) (string, error)
+	Upsert(ctx context.Context, partitionKey string, item interface{}, opts ...ItemOption) error
+	Delete(ctx context.Context, partitionKey, id string, opts ...ItemOption) error
+	Query(ctx context.Context, partitionKey, query string, params []QueryParam, results interface{}) error
+	QueryCrossPartition(ctx context.Context, query string, params []QueryParam, results interface{}) error
+	QueryPage(ctx context.Context, partitionKey, query string, params []QueryParam, pageSize int, continuation string, results interface{}) (string, error)
 }
 
+var _ Container = (*CosmosContainer)(nil)
+
 // CosmosContainer wraps a Cosmos DB container.
 type CosmosContainer struct {
 	container *azcosmos.ContainerClient
+	name      string
+	observer  *cosmosObserver
 }
 
 // Create creates a new item in the container.
@@ -99,57 +134,97 @@ func (c *CosmosContainer) Create(ctx context.Context, partitionKey string, item
 		return fmt.Errorf("failed to marshal item: %w", err)
 	}
 
-	_, err = c.container.CreateItem(ctx, pk, data, nil)
+	start := time.Now()
+	resp, err := c.container.CreateItem(ctx, pk, data, nil)
+	c.observe(ctx, "create", start, &resp.Response, err)
 	if err != nil {
-		var respErr *azcore.ResponseError
-		if errors.As(err, &respErr) && respErr.StatusCode == 409 {
-			return ErrConflict
-		}
-		return fmt.Errorf("failed to create item: %w", err)
+		return mapCosmosError("create item", err)
 	}
 
 	return nil
 }
 
 // Read reads an item from the container.
+// Returns ErrNotFound if the item does not exist.
 func (c *CosmosContainer) Read(ctx context.Context, partitionKey, id string, result interface{}) error {
+	_, err := c.ReadWithETag(ctx, partitionKey, id, result)
+	return err
+}
+
+// ReadWithETag reads an item and returns its ETag for a later conditional write.
+func (c *CosmosContainer) ReadWithETag(ctx context.Context, partitionKey, id string, result interface{}) (string, error) {
 	pk := azcosmos.NewPartitionKeyString(partitionKey)
 
+	start := time.Now()
 	resp, err := c.container.ReadItem(ctx, pk, id, nil)
+	c.observe(ctx, "read", start, &resp.Response, err)
 	if err != nil {
-		var respErr *azcore.ResponseError
-		if errors.As(err, &respErr) && respErr.StatusCode == 404 {
-			return ErrNotFound
-		}
-		return fmt.Errorf("failed to read item: %w", err)
+		return "", mapCosmosError("read item", err)
 	}
 
 	if err := json.Unmarshal(resp.Value, result); err != nil {
-		return fmt.Errorf("failed to unmarshal item: %w", err)
+		return "", fmt.Errorf("failed to unmarshal item: %w", err)
 	}
 
-	return nil
+	return string(resp.ETag), nil
+}
+
+// ItemOption configures a single item write.
+type ItemOption func(*azcosmos.ItemOptions)
+
+// IfMatch makes a write conditional on the item's ETag. The write fails with
+// ErrPreconditionFailed if the item changed since the ETag was read.
+func IfMatch(etag string) ItemOption {
+	return func(o *azcosmos.ItemOptions) {
+		match := azcore.ETag(etag)
+		o.IfMatchEtag = &match
+	}
+}
+
+func itemOptions(opts []ItemOption) *azcosmos.ItemOptions {
+	if len(opts) == 0 {
+		return nil
+	}
+	o := &azcosmos.ItemOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
 }
 
 // Replace replaces an item in the container.
-func (c *CosmosContainer) Replace(ctx context.Context, partitionKey, id string, item interface{}) error {
+func (c *CosmosContainer) Replace(ctx context.Context, partitionKey, id string, item interface{}, opts ...ItemOption) error {
+	_, err := c.replace(ctx, partitionKey, id, item, itemOptions(opts))
+	return err
+}
+
+// ReplaceIfMatch replaces an item only if its ETag still matches and returns
+// the new ETag. Returns ErrPreconditionFailed if the item changed since it was read.
+func (c *CosmosContainer) ReplaceIfMatch(ctx context.Context, partitionKey, id, etag string, item interface{}) (string, error) {
+	return c.replace(ctx, partitionKey, id, item, itemOptions([]ItemOption{IfMatch(etag)}))
+}
+
+func (c *CosmosContainer) replace(ctx context.Context, partitionKey, id string, item interface{}, options *azcosmos.ItemOptions) (string, error) {
 	pk := azcosmos.NewPartitionKeyString(partitionKey)
 
 	data, err := json.Marshal(item)
 	if err != nil {
-		return fmt.Errorf("failed to marshal item: %w", err)
+		return "", fmt.Errorf("failed to marshal item: %w", err)
 	}
 
-	_, err = c.container.ReplaceItem(ctx, pk, id, data, nil)
+	start := time.Now()
+	resp, err := c.container.ReplaceItem(ctx, pk, id, data, options)
+	c.observe(ctx, "replace", start, &resp.Response, err)
 	if err != nil {
-		return fmt.Errorf("failed to replace item: %w", err)
+		return "", mapCosmosError("replace item", err)
 	}
 
-	return nil
+	return string(resp.ETag), nil
 }
 
 // Upsert creates or replaces an item in the container.
-func (c *CosmosContainer) Upsert(ctx context.Context, partitionKey string, item interface{}) error {
+// With IfMatch, the item must exist with a matching ETag.
+func (c *CosmosContainer) Upsert(ctx context.Context, partitionKey string, item interface{}, opts ...ItemOption) error {
 	pk := azcosmos.NewPartitionKeyString(partitionKey)
 
 	data, err := json.Marshal(item)
@@ -157,49 +232,89 @@ func (c *CosmosContainer) Upsert(ctx context.Context, partitionKey string, item
 		return fmt.Errorf("failed to marshal item: %w", err)
 	}
 
-	_, err = c.container.UpsertItem(ctx, pk, data, nil)
+	start := time.Now()
+	resp, err := c.container.UpsertItem(ctx, pk, data, itemOptions(opts))
+	c.observe(ctx, "upsert", start, &resp.Response, err)
 	if err != nil {
-		return fmt.Errorf("failed to upsert item: %w", err)
+		return mapCosmosError("upsert item", err)
 	}
 
 	return nil
 }
 
 // Delete deletes an item from the container.
-func (c *CosmosContainer) Delete(ctx context.Context, partitionKey, id string) error {
+// Deleting an item that does not exist is not an error.
+func (c *CosmosContainer) Delete(ctx context.Context, partitionKey, id string, opts ...ItemOption) error {
 	pk := azcosmos.NewPartitionKeyString(partitionKey)
 
-	_, err := c.container.DeleteItem(ctx, pk, id, nil)
+	start := time.Now()
+	resp, err := c.container.DeleteItem(ctx, pk, id, itemOptions(opts))
+	c.observe(ctx, "delete", start, &resp.Response, err)
 	if err != nil {
-		var respErr *azcore.ResponseError
-		if errors.As(err, &respErr) && respErr.StatusCode == 404 {
+		if statusCode(err) == http.StatusNotFound {
 			return nil // Item already doesn't exist
 		}
-		return fmt.Errorf("failed to delete item: %w", err)
+		return mapCosmosError("delete item", err)
 	}
 
 	return nil
 }
 
+// ReadModifyWrite reads an item, applies modify and replaces it conditioned on
+// the ETag it read. On a concurrent modification the item is read again and
+// modify re-applied, up to maxAttempts times; an error from modify aborts.
+func ReadModifyWrite[T any](ctx context.Context, c Container, partitionKey, id string, maxAttempts int, modify func(item *T) error) (*T, error) {
+	config := DefaultRetryConfig()
+	config.InitialDelay = 20 * time.Millisecond
+	config.MaxDelay = time.Second
+
+	for attempt := 0; ; attempt++ {
+		var item T
+		etag, err := c.ReadWithETag(ctx, partitionKey, id, &item)
+		if err != nil {
+			return nil, err
+		}
+		if err := modify(&item); err != nil {
+			return nil, err
+		}
+
+		_, err = c.ReplaceIfMatch(ctx, partitionKey, id, etag, &item)
+		if err == nil {
+			return &item, nil
+		}
+		if !errors.Is(err, ErrPreconditionFailed) || attempt+1 >= maxAttempts {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("retry cancelled: %w", ctx.Err())
+		case <-time.After(calculateDelay(config, attempt)):
+		}
+	}
+}
+
 // Query executes a query against the container.
 func (c *CosmosContainer) Query(ctx context.Context, partitionKey, query string, params []QueryParam, results interface{}) error {
-	pk := azcosmos.NewPartitionKeyString(partitionKey)
+	return c.query(ctx, azcosmos.NewPartitionKeyString(partitionKey), query, params, results)
+}
 
-	queryOptions := &azcosmos.QueryOptions{}
-	for _, p := range params {
-		queryOptions.QueryParameters = append(queryOptions.QueryParameters, azcosmos.QueryParameter{
-			Name:  p.Name,
-			Value: p.Value,
-		})
-	}
+// QueryCrossPartition executes a query across all partitions of the container.
+func (c *CosmosContainer) QueryCrossPartition(ctx context.Context, query string, params []QueryParam, results interface{}) error {
+	// An empty partition key makes the SDK fan the query out to all partitions
+	return c.query(ctx, azcosmos.NewPartitionKey(), query, params, results)
+}
 
-	pager := c.container.NewQueryItemsPager(query, pk, queryOptions)
+func (c *CosmosContainer) query(ctx context.Context, pk azcosmos.PartitionKey, query string, params []QueryParam, results interface{}) error {
+	pager := c.container.NewQueryItemsPager(query, pk, queryOptions(params))
 
 	var items []json.RawMessage
 	for pager.More() {
+		start := time.Now()
 		resp, err := pager.NextPage(ctx)
+		c.observe(ctx, "query", start, &resp.Response, err)
 		if err != nil {
-			return fmt.Errorf("failed to get query results: %w", err)
+			return mapCosmosError("get query results", err)
 		}
 		for _, item := range resp.Items {
 			items = append(items, item)
@@ -219,59 +334,15 @@ func (c *CosmosContainer) Query(ctx context.Context, partitionKey, query string,
 	return nil
 }
 
-// QueryCrossPartition executes a cross-partition query.
-// With azcosmos SDK v1.x, we can use NewQueryItemsPager without a partition key
-// for true cross-partition queries.
-func (c *CosmosContainer) QueryCrossPartition(ctx context.Context, query string, params []QueryParam, results interface{}) error {
-	queryOptions := &azcosmos.QueryOptions{}
+func queryOptions(params []QueryParam) *azcosmos.QueryOptions {
+	options := &azcosmos.QueryOptions{}
 	for _, p := range params {
-		queryOptions.QueryParameters = append(queryOptions.QueryParameters, azcosmos.QueryParameter{
+		options.QueryParameters = append(options.QueryParameters, azcosmos.QueryParameter{
 			Name:  p.Name,
 			Value: p.Value,
 		})
 	}
-
-	// Try to extract partition key value from common query parameters
-	var partitionKeyValue string
-	for _, p := range params {
-		if p.Name == "@email" || p.Name == "@user_id" || p.Name == "@userId" || p.Name == "@id" {
-			if v, ok := p.Value.(string); ok && v != "" {
-				partitionKeyValue = v
-				break
-			}
-		}
-	}
-
-	// If we found a partition key value, use partition-scoped query for efficiency
-	if partitionKeyValue != "" {
-		return c.Query(ctx, partitionKeyValue, query, params, results)
-	}
-
-	// For cross-partition queries, use NewPartitionKey() which creates an empty partition key
-	// This signals to the SDK to do a cross-partition query
-	pager := c.container.NewQueryItemsPager(query, azcosmos.NewPartitionKey(), queryOptions)
-
-	var items []json.RawMessage
-	for pager.More() {
-		resp, err := pager.NextPage(ctx)
-		if err != nil {
-			return fmt.Errorf("failed to get query results: %w", err)
-		}
-		for _, item := range resp.Items {
-			items = append(items, item)
-		}
-	}
-
-	data, err := json.Marshal(items)
-	if err != nil {
-		return fmt.Errorf("failed to marshal items: %w", err)
-	}
-
-	if err := json.Unmarshal(data, results); err != nil {
-		return fmt.Errorf("failed to unmarshal results: %w", err)
-	}
-
-	return nil
+	return options
 }
 
 // QueryParam represents a query parameter.
@@ -288,18 +359,23 @@ type CosmosDocument struct {
 	TTL       *int      `json:"ttl,omitempty"` // Time to live in seconds
 }
 
-// Common errors.
+// Common errors. Operations return them wrapped in an *errors.AppError with
+// the matching code, so both errors.Is and errors.Code can be used.
 var (
 	ErrNotFound = errors.New("item not found")
 	ErrConflict = errors.New("item already exists")
+	// ErrPreconditionFailed means the item was modified since its ETag was read.
+	ErrPreconditionFailed = errors.New("item was modified concurrently")
 )
 
 // Ping checks if the connection is healthy.
 func (c *CosmosClient) Ping(ctx context.Context) error {
 	// Try to read database properties as a health check
-	_, err := c.database.Read(ctx, nil)
+	start := time.Now()
+	resp, err := c.database.Read(ctx, nil)
+	c.observer.observe(ctx, "", "ping", start, &resp.Response, err)
 	if err != nil {
-		return fmt.Errorf("cosmos health check failed: %w", err)
+		return mapCosmosError("check cosmos health", err)
 	}
 	return nil
 }
@@ -327,23 +403,23 @@ func (c *CosmosContainer) ReadWithRetry(ctx context.Context, partitionKey, id st
 }
 
 // ReplaceWithRetry replaces an item with retry logic.
-func (c *CosmosContainer) ReplaceWithRetry(ctx context.Context, partitionKey, id string, item interface{}) error {
+func (c *CosmosContainer) ReplaceWithRetry(ctx context.Context, partitionKey, id string, item interface{}, opts ...ItemOption) error {
 	return RetryCosmosOperation(ctx, func() error {
-		return c.Replace(ctx, partitionKey, id, item)
+		return c.Replace(ctx, partitionKey, id, item, opts...)
 	})
 }
 
 // UpsertWithRetry creates or replaces an item with retry logic.
-func (c *CosmosContainer) UpsertWithRetry(ctx context.Context, partitionKey string, item interface{}) error {
+func (c *CosmosContainer) UpsertWithRetry(ctx context.Context, partitionKey string, item interface{}, opts ...ItemOption) error {
 	return RetryCosmosOperation(ctx, func() error {
-		return c.Upsert(ctx, partitionKey, item)
+		return c.Upsert(ctx, partitionKey, item, opts...)
 	})
 }
 
 // DeleteWithRetry deletes an item with retry logic.
-func (c *CosmosContainer) DeleteWithRetry(ctx context.Context, partitionKey, id string) error {
+func (c *CosmosContainer) DeleteWithRetry(ctx context.Context, partitionKey, id string, opts ...ItemOption) error {
 	return RetryCosmosOperation(ctx, func() error {
-		return c.Delete(ctx, partitionKey, id)
+		return c.Delete(ctx, partitionKey, id, opts...)
 	})
 }
 
@@ -361,7 +437,9 @@ func (c *CosmosContainer) QueryCrossPartitionWithRetry(ctx context.Context, quer
 	})
 }
 
-// Convenience methods for backward compatibility and easier usage
+// Convenience methods for backward compatibility and easier usage. They go
+// through the same container operations as CosmosContainer; the database
+// parameter is ignored in favour of CosmosConfig.DatabaseName.
 
 // GetItem retrieves a single item by ID.
 func (c *CosmosClient) GetItem(ctx context.Context, database, containerName, partitionKey, id string, result interface{}) error {
@@ -390,8 +468,9 @@ func (c *CosmosClient) UpsertItem(ctx context.Context, database, containerName,
 	return container.Upsert(ctx, partitionKey, item)
 }
 
-// QueryItems executes a parameterized query against a container.
-// This is the SAFE way to query - always use parameterized queries.
+// QueryItems executes a parameterized cross-partition query against a container.
+// This is the SAFE way to query - always use parameterized queries. Prefer
+// QueryItemsWithPartition when the partition key is known.
 // Example:
 //
 //	query := "SELECT * FROM c WHERE c.user_id = @userId"
@@ -402,141 +481,18 @@ func (c *CosmosClient) QueryItems(ctx context.Context, database, containerName,
 	if err != nil {
 		return err
 	}
-
-	// Use QueryCrossPartition which handles both single-partition and cross-partition queries
-	// It will automatically use partition-scoped query if a partition key parameter is found
 	return container.QueryCrossPartition(ctx, query, params, results)
 }
 
-// QueryAllPartitions executes a true cross-partition query using the REST API.
-// This bypasses the SDK's limitation of requiring a partition key for queries.
-// Note: This only works when using key-based authentication (not managed identity).
+// QueryAllPartitions executes a cross-partition query.
+//
+// Deprecated: use QueryItems, which is equivalent.
 func (c *CosmosClient) QueryAllPartitions(ctx context.Context, containerName, query string, params []QueryParam, results interface{}) error {
-	if c.config.Key == "" {
-		return fmt.Errorf("QueryAllPartitions requires key-based authentication")
-	}
-
-	// Build the query body
-	queryBody := map[string]interface{}{
-		"query": query,
-	}
-
-	if len(params) > 0 {
-		parameters := make([]map[string]interface{}, 0, len(params))
-		for _, p := range params {
-			parameters = append(parameters, map[string]interface{}{
-				"name":  p.Name,
-				"value": p.Value,
-			})
-		}
-		queryBody["parameters"] = parameters
-	}
-
-	bodyBytes, err := json.Marshal(queryBody)
-	if err != nil {
-		return fmt.Errorf("failed to marshal query body: %w", err)
-	}
-
-	// Build the URL
-	resourceLink := fmt.Sprintf("dbs/%s/colls/%s", c.config.DatabaseName, containerName)
-	queryURL := fmt.Sprintf("%s/%s/docs", c.config.Endpoint, resourceLink)
-
-	var allDocuments []json.RawMessage
-	var continuationToken string
-
-	// Paginate through all results
-	for {
-		req, err := http.NewRequestWithContext(ctx, "POST", queryURL, bytes.NewReader(bodyBytes))
-		if err != nil {
-			return fmt.Errorf("failed to create request: %w", err)
-		}
-
-		// Generate auth header
-		dateStr := time.Now().UTC().Format(http.TimeFormat)
-		authHeader := c.generateAuthHeader("POST", "docs", resourceLink, dateStr)
-
-		// Set required headers
-		req.Header.Set("Authorization", authHeader)
-		req.Header.Set("x-ms-date", dateStr)
-		req.Header.Set("x-ms-version", "2020-07-15")
-		req.Header.Set("Content-Type", "application/query+json")
-		req.Header.Set("x-ms-documentdb-isquery", "true")
-		req.Header.Set("x-ms-documentdb-query-enablecrosspartition", "true")
-		req.Header.Set("x-ms-max-item-count", "-1")
-
-		if continuationToken != "" {
-			req.Header.Set("x-ms-continuation", continuationToken)
-		}
-
-		// Execute request
-		client := &http.Client{Timeout: 30 * time.Second}
-		resp, err := client.Do(req)
-		if err != nil {
-			return fmt.Errorf("failed to execute query: %w", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			bodyBytes, _ := io.ReadAll(resp.Body)
-			return fmt.Errorf("query failed with status %d: %s", resp.StatusCode, string(bodyBytes))
-		}
-
-		// Parse response
-		var queryResp struct {
-			Documents []json.RawMessage `json:"Documents"`
-			Count     int               `json:"_count"`
-		}
-
-		if err := json.NewDecoder(resp.Body).Decode(&queryResp); err != nil {
-			return fmt.Errorf("failed to decode response: %w", err)
-		}
-
-		allDocuments = append(allDocuments, queryResp.Documents...)
-
-		// Check for continuation
-		continuationToken = resp.Header.Get("x-ms-continuation")
-		if continuationToken == "" {
-			break
-		}
-	}
-
-	// Marshal all documents into the results
-	data, err := json.Marshal(allDocuments)
-	if err != nil {
-		return fmt.Errorf("failed to marshal results: %w", err)
-	}
-
-	if err := json.Unmarshal(data, results); err != nil {
-		return fmt.Errorf("failed to unmarshal results: %w", err)
-	}
-
-	return nil
-}
-
-// generateAuthHeader generates the Cosmos DB authorization header.
-func (c *CosmosClient) generateAuthHeader(verb, resourceType, resourceLink, dateStr string) string {
-	// Decode the master key
-	key, err := base64.StdEncoding.DecodeString(c.config.Key)
+	container, err := c.Container(containerName)
 	if err != nil {
-		return ""
+		return err
 	}
-
-	// Build the string to sign
-	stringToSign := fmt.Sprintf("%s\n%s\n%s\n%s\n%s\n",
-		strings.ToLower(verb),
-		strings.ToLower(resourceType),
-		resourceLink,
-		strings.ToLower(dateStr),
-		"",
-	)
-
-	// Generate HMAC signature
-	h := hmac.New(sha256.New, key)
-	_, _ = h.Write([]byte(stringToSign))
-	signature := base64.StdEncoding.EncodeToString(h.Sum(nil))
-
-	// Build auth header
-	return url.QueryEscape(fmt.Sprintf("type=master&ver=1.0&sig=%s", signature))
+	return container.QueryCrossPartition(ctx, query, params, results)
 }
 
 // QueryItemsWithPartition executes a parameterized query within a specific partition.
@@ -0,0 +1,175 @@
+// Package database provides database client utilities.
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
+)
+
+// MaxBatchOperations is the Cosmos DB limit of operations per transactional batch.
+const MaxBatchOperations = 100
+
+// ErrBatchFailed means a transactional batch was rolled back.
+var ErrBatchFailed = errors.New("transactional batch failed")
+
+// Batch collects operations that are executed atomically within one
+// partition key: either all succeed or none is applied.
+type Batch struct {
+	partitionKey string
+	batch        azcosmos.TransactionalBatch
+	ops          int
+	err          error
+}
+
+// NewBatch starts a transactional batch for a partition key.
+func (c *CosmosContainer) NewBatch(partitionKey string) *Batch {
+	return &Batch{
+		partitionKey: partitionKey,
+		batch:        c.container.NewTransactionalBatch(azcosmos.NewPartitionKeyString(partitionKey)),
+	}
+}
+
+// Create adds an item creation.
+func (b *Batch) Create(item interface{}) *Batch {
+	if data, ok := b.marshal(item); ok {
+		b.batch.CreateItem(data, nil)
+		b.ops++
+	}
+	return b
+}
+
+// Upsert adds an item creation or replacement.
+func (b *Batch) Upsert(item interface{}, opts ...ItemOption) *Batch {
+	if data, ok := b.marshal(item); ok {
+		b.batch.UpsertItem(data, batchItemOptions(opts))
+		b.ops++
+	}
+	return b
+}
+
+// Replace adds an item replacement.
+func (b *Batch) Replace(id string, item interface{}, opts ...ItemOption) *Batch {
+	if data, ok := b.marshal(item); ok {
+		b.batch.ReplaceItem(id, data, batchItemOptions(opts))
+		b.ops++
+	}
+	return b
+}
+
+// Delete adds an item deletion.
+func (b *Batch) Delete(id string, opts ...ItemOption) *Batch {
+	b.batch.DeleteItem(id, batchItemOptions(opts))
+	b.ops++
+	return b
+}
+
+// Read adds an item read; its body is returned in the operation result.
+func (b *Batch) Read(id string) *Batch {
+	b.batch.ReadItem(id, nil)
+	b.ops++
+	return b
+}
+
+// Len returns the number of operations in the batch.
+func (b *Batch) Len() int {
+	return b.ops
+}
+
+func (b *Batch) marshal(item interface{}) ([]byte, bool) {
+	data, err := json.Marshal(item)
+	if err != nil {
+		if b.err == nil {
+			b.err = fmt.Errorf("failed to marshal batch item %d: %w", b.ops, err)
+		}
+		return nil, false
+	}
+	return data, true
+}
+
+func batchItemOptions(opts []ItemOption) *azcosmos.TransactionalBatchItemOptions {
+	o := itemOptions(opts)
+	if o == nil {
+		return nil
+	}
+	return &azcosmos.TransactionalBatchItemOptions{IfMatchETag: o.IfMatchEtag}
+}
+
+// BatchResult is the result of a transactional batch.
+type BatchResult struct {
+	Success       bool                   `json:"success"`
+	RequestCharge float64                `json:"request_charge"`
+	ActivityID    string                 `json:"activity_id,omitempty"`
+	Operations    []BatchOperationResult `json:"operations"`
+}
+
+// BatchOperationResult is the result of one operation in a batch.
+type BatchOperationResult struct {
+	StatusCode    int             `json:"status_code"`
+	RequestCharge float64         `json:"request_charge"`
+	ETag          string          `json:"etag,omitempty"`
+	Body          json.RawMessage `json:"body,omitempty"`
+}
+
+// ExecuteBatch executes a transactional batch. If any operation fails the
+// batch is rolled back and the error wraps ErrBatchFailed and, for the failing
+// operation, ErrConflict, ErrNotFound or ErrPreconditionFailed; the result is
+// returned alongside with per-operation status codes.
+func (c *CosmosContainer) ExecuteBatch(ctx context.Context, b *Batch) (*BatchResult, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if b.ops == 0 {
+		return &BatchResult{Success: true}, nil
+	}
+	if b.ops > MaxBatchOperations {
+		return nil, fmt.Errorf("batch has %d operations, the limit is %d", b.ops, MaxBatchOperations)
+	}
+
+	start := time.Now()
+	resp, err := c.container.ExecuteTransactionalBatch(ctx, b.batch, nil)
+	c.observe(ctx, "batch", start, &resp.Response, err)
+	if err != nil {
+		return nil, mapCosmosError("execute batch", err)
+	}
+
+	result := &BatchResult{
+		Success:       resp.Success,
+		RequestCharge: float64(resp.RequestCharge),
+		ActivityID:    resp.ActivityID,
+		Operations:    make([]BatchOperationResult, len(resp.OperationResults)),
+	}
+	for i, op := range resp.OperationResults {
+		result.Operations[i] = BatchOperationResult{
+			StatusCode:    int(op.StatusCode),
+			RequestCharge: float64(op.RequestCharge),
+			ETag:          string(op.ETag),
+			Body:          op.ResourceBody,
+		}
+	}
+
+	if !result.Success {
+		return result, batchError(result)
+	}
+	return result, nil
+}
+
+// batchError reports the operation that caused the rollback; the others
+// fail with 424 Failed Dependency.
+func batchError(result *BatchResult) error {
+	for i, op := range result.Operations {
+		if op.StatusCode < 300 || op.StatusCode == http.StatusFailedDependency {
+			continue
+		}
+		if cause := statusError(op.StatusCode); cause != nil {
+			return fmt.Errorf("%w: operation %d: %w", ErrBatchFailed, i, cause)
+		}
+		return fmt.Errorf("%w: operation %d failed with status %d", ErrBatchFailed, i, op.StatusCode)
+	}
+	return ErrBatchFailed
+}
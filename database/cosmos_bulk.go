@@ -0,0 +1,298 @@
+// Package database provides database client utilities.
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
+)
+
+// BulkOperationType is the kind of a bulk operation.
+type BulkOperationType string
+
+const (
+	BulkCreate  BulkOperationType = "create"
+	BulkUpsert  BulkOperationType = "upsert"
+	BulkReplace BulkOperationType = "replace"
+	BulkDelete  BulkOperationType = "delete"
+)
+
+// BulkOperation is a single item write executed by a BulkExecutor.
+type BulkOperation struct {
+	Type         BulkOperationType
+	PartitionKey string
+	// ID is required for replace and delete.
+	ID   string
+	Item interface{}
+	// IfMatch makes the write conditional on the item's ETag.
+	IfMatch string
+}
+
+// BulkItemResult is the outcome of one bulk operation.
+type BulkItemResult struct {
+	// Index is the position of the operation in the input.
+	Index         int     `json:"index"`
+	ID            string  `json:"id,omitempty"`
+	StatusCode    int     `json:"status_code"`
+	RequestCharge float64 `json:"request_charge"`
+	// Attempts includes retries after throttling.
+	Attempts int   `json:"attempts"`
+	Err      error `json:"-"`
+}
+
+// BulkResult summarizes a bulk execution.
+type BulkResult struct {
+	Succeeded     int              `json:"succeeded"`
+	Failed        int              `json:"failed"`
+	Throttled     int              `json:"throttled"`
+	RequestCharge float64          `json:"request_charge"`
+	Duration      time.Duration    `json:"duration"`
+	Items         []BulkItemResult `json:"items"`
+}
+
+// Errors returns the failed items.
+func (r *BulkResult) Errors() []BulkItemResult {
+	var failed []BulkItemResult
+	for _, item := range r.Items {
+		if item.Err != nil {
+			failed = append(failed, item)
+		}
+	}
+	return failed
+}
+
+// BulkConfig holds bulk executor configuration.
+type BulkConfig struct {
+	// Concurrency is the number of operations in flight.
+	Concurrency int
+	// MaxThrottleRetries is how often an operation is retried after a 429.
+	MaxThrottleRetries int
+	// Backoff is used when a 429 carries no retry-after hint.
+	Backoff RetryConfig
+}
+
+// DefaultBulkConfig returns sensible defaults.
+func DefaultBulkConfig() BulkConfig {
+	return BulkConfig{
+		Concurrency:        16,
+		MaxThrottleRetries: 9,
+		Backoff: RetryConfig{
+			InitialDelay: 100 * time.Millisecond,
+			MaxDelay:     5 * time.Second,
+			Multiplier:   2.0,
+			Jitter:       0.2,
+		},
+	}
+}
+
+// bulkResponse is what a single bulk write reports back.
+type bulkResponse struct {
+	statusCode    int
+	requestCharge float64
+}
+
+// BulkExecutor runs many independent item writes concurrently. When Cosmos DB
+// throttles a request (429), all workers pause for the advertised retry-after
+// so the executor settles at the container's provisioned RU/s instead of
+// hammering it.
+type BulkExecutor struct {
+	config BulkConfig
+	do     func(ctx context.Context, op BulkOperation) (bulkResponse, error)
+	// pausedUntil is the UnixNano time until which workers hold off after a 429
+	pausedUntil atomic.Int64
+}
+
+// NewBulkExecutor creates a bulk executor for a container.
+func NewBulkExecutor(container *CosmosContainer, config BulkConfig) *BulkExecutor {
+	return newBulkExecutor(container.bulkWrite, config)
+}
+
+func newBulkExecutor(do func(ctx context.Context, op BulkOperation) (bulkResponse, error), config BulkConfig) *BulkExecutor {
+	if config.Concurrency <= 0 {
+		config.Concurrency = 1
+	}
+	return &BulkExecutor{config: config, do: do}
+}
+
+// Execute runs the operations and reports a result per operation. Failed
+// operations do not stop the others; the returned error is only set when ctx
+// ends before all operations were dispatched.
+func (e *BulkExecutor) Execute(ctx context.Context, ops []BulkOperation) (*BulkResult, error) {
+	start := time.Now()
+	result := &BulkResult{Items: make([]BulkItemResult, len(ops))}
+
+	var throttled atomic.Int64
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < e.config.Concurrency && w < len(ops); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				item, retries := e.run(ctx, ops[i])
+				item.Index = i
+				result.Items[i] = item
+				throttled.Add(int64(retries))
+			}
+		}()
+	}
+
+	var err error
+feed:
+	for i := range ops {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			break feed
+		case indexes <- i:
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	for i := range result.Items {
+		item := &result.Items[i]
+		if item.Attempts == 0 && item.Err == nil {
+			// Never dispatched
+			item.Index = i
+			item.ID = ops[i].ID
+			item.Err = err
+		}
+		result.RequestCharge += item.RequestCharge
+		if item.Err == nil {
+			result.Succeeded++
+		} else {
+			result.Failed++
+		}
+	}
+	result.Throttled = int(throttled.Load())
+	result.Duration = time.Since(start)
+	return result, err
+}
+
+// run executes one operation, retrying while throttled.
+func (e *BulkExecutor) run(ctx context.Context, op BulkOperation) (BulkItemResult, int) {
+	item := BulkItemResult{ID: op.ID}
+	throttles := 0
+	for {
+		if err := e.waitForPause(ctx); err != nil {
+			item.Err = err
+			return item, throttles
+		}
+
+		item.Attempts++
+		resp, err := e.do(ctx, op)
+		item.StatusCode = resp.statusCode
+		item.RequestCharge += resp.requestCharge
+		if err == nil {
+			item.Err = nil
+			return item, throttles
+		}
+		item.Err = err
+
+		retryAfter, ok := throttledFor(err)
+		if !ok || throttles >= e.config.MaxThrottleRetries {
+			return item, throttles
+		}
+		if retryAfter <= 0 {
+			retryAfter = calculateDelay(e.config.Backoff, throttles)
+		}
+		throttles++
+		e.pause(retryAfter)
+	}
+}
+
+// pause holds off all workers for d, extending any pause already in effect.
+func (e *BulkExecutor) pause(d time.Duration) {
+	until := time.Now().Add(d).UnixNano()
+	for {
+		current := e.pausedUntil.Load()
+		if current >= until || e.pausedUntil.CompareAndSwap(current, until) {
+			return
+		}
+	}
+}
+
+func (e *BulkExecutor) waitForPause(ctx context.Context) error {
+	for {
+		wait := time.Until(time.Unix(0, e.pausedUntil.Load()))
+		if wait <= 0 {
+			return ctx.Err()
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// throttledFor reports whether err is a 429 and the retry-after it carries.
+func throttledFor(err error) (time.Duration, bool) {
+	var respErr *azcore.ResponseError
+	if !errors.As(err, &respErr) || respErr.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	if respErr.RawResponse != nil {
+		if ms, err := strconv.ParseFloat(respErr.RawResponse.Header.Get("x-ms-retry-after-ms"), 64); err == nil {
+			return time.Duration(ms * float64(time.Millisecond)), true
+		}
+	}
+	return 0, true
+}
+
+// bulkWrite executes one bulk operation against the container.
+func (c *CosmosContainer) bulkWrite(ctx context.Context, op BulkOperation) (bulkResponse, error) {
+	pk := azcosmos.NewPartitionKeyString(op.PartitionKey)
+	var options *azcosmos.ItemOptions
+	if op.IfMatch != "" {
+		options = itemOptions([]ItemOption{IfMatch(op.IfMatch)})
+	}
+
+	var data []byte
+	if op.Type != BulkDelete {
+		var err error
+		if data, err = json.Marshal(op.Item); err != nil {
+			return bulkResponse{}, fmt.Errorf("failed to marshal item: %w", err)
+		}
+	}
+
+	var resp azcosmos.ItemResponse
+	var err error
+	start := time.Now()
+	switch op.Type {
+	case BulkCreate:
+		resp, err = c.container.CreateItem(ctx, pk, data, options)
+	case BulkUpsert:
+		resp, err = c.container.UpsertItem(ctx, pk, data, options)
+	case BulkReplace:
+		resp, err = c.container.ReplaceItem(ctx, pk, op.ID, data, options)
+	case BulkDelete:
+		resp, err = c.container.DeleteItem(ctx, pk, op.ID, options)
+	default:
+		return bulkResponse{}, fmt.Errorf("unknown bulk operation %q", op.Type)
+	}
+	c.observe(ctx, "bulk_"+string(op.Type), start, &resp.Response, err)
+
+	if err != nil {
+		return bulkResponse{statusCode: statusCode(err)}, mapCosmosError(string(op.Type)+" item", err)
+	}
+
+	out := bulkResponse{requestCharge: float64(resp.RequestCharge)}
+	if resp.RawResponse != nil {
+		out.statusCode = resp.RawResponse.StatusCode
+	}
+	return out, nil
+}
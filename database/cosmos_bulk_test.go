@@ -0,0 +1,165 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+func throttleError(retryAfter string) error {
+	header := http.Header{}
+	if retryAfter != "" {
+		header.Set("x-ms-retry-after-ms", retryAfter)
+	}
+	return &azcore.ResponseError{
+		StatusCode:  http.StatusTooManyRequests,
+		RawResponse: &http.Response{StatusCode: http.StatusTooManyRequests, Header: header},
+	}
+}
+
+func bulkOps(n int) []BulkOperation {
+	ops := make([]BulkOperation, n)
+	for i := range ops {
+		ops[i] = BulkOperation{Type: BulkUpsert, PartitionKey: "pk", ID: fmt.Sprintf("item-%d", i)}
+	}
+	return ops
+}
+
+func TestBulkExecutor_RetriesThrottled(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	do := func(_ context.Context, op BulkOperation) (bulkResponse, error) {
+		mu.Lock()
+		calls[op.ID]++
+		n := calls[op.ID]
+		mu.Unlock()
+		if op.ID == "item-3" && n <= 2 {
+			return bulkResponse{statusCode: 429, requestCharge: 0.5}, throttleError("5")
+		}
+		return bulkResponse{statusCode: 200, requestCharge: 10}, nil
+	}
+
+	executor := newBulkExecutor(do, DefaultBulkConfig())
+	result, err := executor.Execute(context.Background(), bulkOps(10))
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	if result.Succeeded != 10 || result.Failed != 0 {
+		t.Errorf("expected 10 succeeded, got %+v", result)
+	}
+	if result.Throttled != 2 {
+		t.Errorf("expected 2 throttled retries, got %d", result.Throttled)
+	}
+	if result.RequestCharge != 101 {
+		t.Errorf("expected 101 RU, got %f", result.RequestCharge)
+	}
+	item := result.Items[3]
+	if item.Index != 3 || item.Attempts != 3 || item.StatusCode != 200 || item.Err != nil {
+		t.Errorf("unexpected item result: %+v", item)
+	}
+}
+
+func TestBulkExecutor_ReportsFailures(t *testing.T) {
+	do := func(_ context.Context, op BulkOperation) (bulkResponse, error) {
+		switch op.ID {
+		case "item-1":
+			return bulkResponse{statusCode: 409}, ErrConflict
+		case "item-2":
+			return bulkResponse{statusCode: 429}, throttleError("1")
+		}
+		return bulkResponse{statusCode: 201}, nil
+	}
+
+	config := DefaultBulkConfig()
+	config.MaxThrottleRetries = 2
+	result, err := newBulkExecutor(do, config).Execute(context.Background(), bulkOps(5))
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	if result.Succeeded != 3 || result.Failed != 2 {
+		t.Errorf("expected 3 succeeded and 2 failed, got %d/%d", result.Succeeded, result.Failed)
+	}
+	failed := result.Errors()
+	if len(failed) != 2 || !errors.Is(failed[0].Err, ErrConflict) || failed[1].Attempts != 3 {
+		t.Errorf("unexpected failures: %+v", failed)
+	}
+}
+
+func TestBulkExecutor_BoundsConcurrency(t *testing.T) {
+	var inFlight, peak atomic.Int64
+	do := func(_ context.Context, _ BulkOperation) (bulkResponse, error) {
+		n := inFlight.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		inFlight.Add(-1)
+		return bulkResponse{statusCode: 200}, nil
+	}
+
+	config := DefaultBulkConfig()
+	config.Concurrency = 4
+	if _, err := newBulkExecutor(do, config).Execute(context.Background(), bulkOps(50)); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if peak.Load() > 4 {
+		t.Errorf("expected at most 4 operations in flight, got %d", peak.Load())
+	}
+}
+
+func TestBulkExecutor_ContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	do := func(_ context.Context, op BulkOperation) (bulkResponse, error) {
+		if op.ID == "item-0" {
+			cancel()
+		}
+		return bulkResponse{statusCode: 200}, nil
+	}
+
+	config := DefaultBulkConfig()
+	config.Concurrency = 1
+	result, err := newBulkExecutor(do, config).Execute(ctx, bulkOps(20))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if result.Failed == 0 || result.Succeeded+result.Failed != 20 {
+		t.Errorf("expected undispatched operations reported as failed, got %+v", result)
+	}
+}
+
+func TestThrottledFor(t *testing.T) {
+	if d, ok := throttledFor(throttleError("250")); !ok || d != 250*time.Millisecond {
+		t.Errorf("expected 250ms, got %v, %v", d, ok)
+	}
+	if d, ok := throttledFor(throttleError("")); !ok || d != 0 {
+		t.Errorf("expected throttled without hint, got %v, %v", d, ok)
+	}
+	if _, ok := throttledFor(&azcore.ResponseError{StatusCode: 503}); ok {
+		t.Error("503 should not count as throttled")
+	}
+}
+
+func TestBatchError(t *testing.T) {
+	result := &BatchResult{Operations: []BatchOperationResult{
+		{StatusCode: http.StatusFailedDependency},
+		{StatusCode: http.StatusPreconditionFailed},
+		{StatusCode: http.StatusFailedDependency},
+	}}
+
+	err := batchError(result)
+	if !errors.Is(err, ErrBatchFailed) || !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("expected batch and precondition errors, got %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+// Package database provides database client utilities.
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
+	apperrors "github.com/mycobrun/cobrun-shared/errors"
+	"github.com/mycobrun/cobrun-shared/telemetry"
+)
+
+// CosmosDiagnostics describes a Cosmos DB request.
+type CosmosDiagnostics struct {
+	Operation string `json:"operation"`
+	Container string `json:"container,omitempty"`
+	// StatusCode is the HTTP status, 0 if no response was received.
+	StatusCode    int           `json:"status_code"`
+	RequestCharge float64       `json:"request_charge"`
+	ActivityID    string        `json:"activity_id,omitempty"`
+	Latency       time.Duration `json:"latency"`
+	Err           error         `json:"-"`
+}
+
+type cosmosDiagnosticsKey struct{}
+
+// WithCosmosDiagnostics returns a context that captures the diagnostics of
+// the Cosmos DB requests made with it. RequestCharge and Latency are totals
+// over all requests, e.g. all pages of a query; the other fields describe the
+// last request.
+//
+//	var diag database.CosmosDiagnostics
+//	err := container.Read(database.WithCosmosDiagnostics(ctx, &diag), pk, id, &trip)
+//	log.Printf("read cost %.2f RU", diag.RequestCharge)
+func WithCosmosDiagnostics(ctx context.Context, diag *CosmosDiagnostics) context.Context {
+	return context.WithValue(ctx, cosmosDiagnosticsKey{}, diag)
+}
+
+// cosmosObserver receives the diagnostics of every request made by a client
+// and the containers obtained from it.
+type cosmosObserver struct {
+	metrics     *telemetry.DatabaseMetrics
+	onOperation func(ctx context.Context, diag CosmosDiagnostics)
+}
+
+func (c *CosmosContainer) observe(ctx context.Context, operation string, start time.Time, resp *azcosmos.Response, err error) {
+	c.observer.observe(ctx, c.name, operation, start, resp, err)
+}
+
+// observe records a completed request. When the request failed, the request
+// charge and activity ID are taken from the error response.
+func (o *cosmosObserver) observe(ctx context.Context, container, operation string, start time.Time, resp *azcosmos.Response, err error) {
+	diag := CosmosDiagnostics{
+		Operation: operation,
+		Container: container,
+		Latency:   time.Since(start),
+		Err:       err,
+	}
+
+	var raw *http.Response
+	if resp != nil && resp.RawResponse != nil {
+		raw = resp.RawResponse
+		diag.RequestCharge = float64(resp.RequestCharge)
+		diag.ActivityID = resp.ActivityID
+	} else {
+		var respErr *azcore.ResponseError
+		if errors.As(err, &respErr) && respErr.RawResponse != nil {
+			raw = respErr.RawResponse
+			diag.RequestCharge, _ = strconv.ParseFloat(raw.Header.Get("x-ms-request-charge"), 64)
+			diag.ActivityID = raw.Header.Get("x-ms-activity-id")
+		}
+	}
+	if raw != nil {
+		diag.StatusCode = raw.StatusCode
+	} else {
+		diag.StatusCode = statusCode(err)
+	}
+
+	if captured, ok := ctx.Value(cosmosDiagnosticsKey{}).(*CosmosDiagnostics); ok && captured != nil {
+		charge, latency := captured.RequestCharge, captured.Latency
+		*captured = diag
+		captured.RequestCharge += charge
+		captured.Latency += latency
+	}
+
+	if o == nil {
+		return
+	}
+	if o.metrics != nil {
+		name := operation
+		if container != "" {
+			name = container + "." + operation
+		}
+		o.metrics.RecordOperation(ctx, name, diag.Latency, err)
+		o.metrics.RecordRequestCharge(ctx, name, diag.RequestCharge)
+	}
+	if o.onOperation != nil {
+		o.onOperation(ctx, diag)
+	}
+}
+
+// statusCode returns the HTTP status of a Cosmos DB error, or 0.
+func statusCode(err error) int {
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.StatusCode
+	}
+	return 0
+}
+
+// statusError maps a Cosmos DB status code to the package's sentinel errors,
+// wrapped in an *errors.AppError.
+func statusError(statusCode int) error {
+	switch statusCode {
+	case http.StatusNotFound:
+		return apperrors.Wrap(ErrNotFound, apperrors.CodeNotFound, "cosmos item not found")
+	case http.StatusConflict:
+		return apperrors.Wrap(ErrConflict, apperrors.CodeConflict, "cosmos item conflict")
+	case http.StatusPreconditionFailed:
+		return apperrors.Wrap(ErrPreconditionFailed, apperrors.CodeConflict, "cosmos precondition failed")
+	}
+	return nil
+}
+
+// mapCosmosError converts an SDK error into an *errors.AppError. 404, 409 and
+// 412 wrap ErrNotFound, ErrConflict and ErrPreconditionFailed; other errors
+// keep the SDK error in the chain so Retry can still classify it.
+func mapCosmosError(action string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return apperrors.Wrap(err, apperrors.CodeTimeout, "failed to "+action)
+	}
+
+	status := statusCode(err)
+	if mapped := statusError(status); mapped != nil {
+		return mapped
+	}
+
+	code := apperrors.CodeInternal
+	switch status {
+	case 0:
+		return apperrors.Wrap(err, code, "failed to "+action)
+	case http.StatusBadRequest:
+		code = apperrors.CodeBadRequest
+	case http.StatusUnauthorized:
+		code = apperrors.CodeUnauthorized
+	case http.StatusForbidden:
+		code = apperrors.CodeForbidden
+	case http.StatusRequestTimeout:
+		code = apperrors.CodeTimeout
+	case http.StatusTooManyRequests:
+		code = apperrors.CodeRateLimited
+	case http.StatusServiceUnavailable:
+		code = apperrors.CodeUnavailable
+	}
+	return apperrors.Wrap(err, code, fmt.Sprintf("failed to %s (status %d)", action, status))
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
+	apperrors "github.com/mycobrun/cobrun-shared/errors"
+)
+
+func cosmosError(status int, charge, activityID string) error {
+	header := http.Header{}
+	header.Set("x-ms-request-charge", charge)
+	header.Set("x-ms-activity-id", activityID)
+	return &azcore.ResponseError{
+		StatusCode:  status,
+		RawResponse: &http.Response{StatusCode: status, Header: header},
+	}
+}
+
+func TestMapCosmosError(t *testing.T) {
+	tests := []struct {
+		status    int
+		code      string
+		sentinel  error
+		retryable bool
+	}{
+		{http.StatusNotFound, apperrors.CodeNotFound, ErrNotFound, false},
+		{http.StatusConflict, apperrors.CodeConflict, ErrConflict, false},
+		{http.StatusPreconditionFailed, apperrors.CodeConflict, ErrPreconditionFailed, false},
+		{http.StatusBadRequest, apperrors.CodeBadRequest, nil, false},
+		{http.StatusForbidden, apperrors.CodeForbidden, nil, false},
+		{http.StatusTooManyRequests, apperrors.CodeRateLimited, nil, true},
+		{http.StatusServiceUnavailable, apperrors.CodeUnavailable, nil, true},
+		{http.StatusInternalServerError, apperrors.CodeInternal, nil, true},
+	}
+
+	for _, tt := range tests {
+		err := mapCosmosError("read item", cosmosError(tt.status, "1", ""))
+		if apperrors.Code(err) != tt.code {
+			t.Errorf("status %d: expected code %s, got %q", tt.status, tt.code, apperrors.Code(err))
+		}
+		if tt.sentinel != nil && !errors.Is(err, tt.sentinel) {
+			t.Errorf("status %d: expected %v in chain", tt.status, tt.sentinel)
+		}
+		if isRetryable(err) != tt.retryable {
+			t.Errorf("status %d: expected retryable=%v", tt.status, tt.retryable)
+		}
+	}
+
+	if _, ok := throttledFor(mapCosmosError("read item", throttleError("10"))); !ok {
+		t.Error("mapped 429 should still be recognized as throttled")
+	}
+	if err := mapCosmosError("read item", context.Canceled); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled in chain, got %v", err)
+	}
+	if mapCosmosError("read item", nil) != nil {
+		t.Error("nil error should stay nil")
+	}
+}
+
+func TestCosmosObserver(t *testing.T) {
+	var hooked []CosmosDiagnostics
+	container := &CosmosContainer{name: "trips", observer: &cosmosObserver{
+		onOperation: func(_ context.Context, diag CosmosDiagnostics) {
+			hooked = append(hooked, diag)
+		},
+	}}
+
+	var diag CosmosDiagnostics
+	ctx := WithCosmosDiagnostics(context.Background(), &diag)
+
+	resp := azcosmos.Response{
+		RawResponse:   &http.Response{StatusCode: http.StatusOK},
+		RequestCharge: 2.5,
+		ActivityID:    "first",
+	}
+	container.observe(ctx, "query", time.Now(), &resp, nil)
+	container.observe(ctx, "query", time.Now(), &azcosmos.Response{}, cosmosError(http.StatusTooManyRequests, "0.5", "second"))
+
+	if diag.RequestCharge != 3 {
+		t.Errorf("expected 3 RU in total, got %f", diag.RequestCharge)
+	}
+	if diag.ActivityID != "second" || diag.StatusCode != http.StatusTooManyRequests || diag.Err == nil {
+		t.Errorf("expected the last request to be described, got %+v", diag)
+	}
+
+	if len(hooked) != 2 {
+		t.Fatalf("expected 2 hook calls, got %d", len(hooked))
+	}
+	if hooked[0].Container != "trips" || hooked[0].RequestCharge != 2.5 || hooked[0].ActivityID != "first" {
+		t.Errorf("unexpected diagnostics: %+v", hooked[0])
+	}
+}
+
+func TestCosmosObserver_Nil(t *testing.T) {
+	container := &CosmosContainer{name: "trips"}
+	// Must not panic without an observer
+	container.observe(context.Background(), "read", time.Now(), &azcosmos.Response{}, nil)
+}
@@ -0,0 +1,226 @@
+// Package database provides database client utilities.
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// cosmosFieldPattern matches property paths such as status or pickup_location.type.
+var cosmosFieldPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// cosmosReservedWords are keywords that cannot follow a dot in a property
+// path; such properties are referenced in bracket notation, e.g. c["value"].
+var cosmosReservedWords = map[string]bool{
+	"AND": true, "ARRAY": true, "AS": true, "ASC": true, "BETWEEN": true, "BY": true,
+	"CASE": true, "CAST": true, "CONVERT": true, "CROSS": true, "DESC": true, "DISTINCT": true,
+	"ELSE": true, "END": true, "ESCAPE": true, "EXISTS": true, "FALSE": true, "FOR": true,
+	"FROM": true, "GROUP": true, "HAVING": true, "IN": true, "INNER": true, "INSERT": true,
+	"INTO": true, "IS": true, "JOIN": true, "LEFT": true, "LIKE": true, "LIMIT": true,
+	"NOT": true, "NULL": true, "OFFSET": true, "ON": true, "OR": true, "ORDER": true,
+	"OUTER": true, "OVER": true, "RIGHT": true, "SELECT": true, "SET": true, "THEN": true,
+	"TOP": true, "TRUE": true, "UDF": true, "UNDEFINED": true, "UPDATE": true, "VALUE": true,
+	"WHEN": true, "WHERE": true, "WITH": true,
+}
+
+// cosmosOperators are the comparison operators accepted by Query.Where.
+var cosmosOperators = map[string]bool{
+	"=": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true,
+}
+
+// Query builds parameterized Cosmos DB SQL. Values are always passed as
+// parameters and field names are validated, so user input cannot alter the
+// query text. Fields are relative to the document, e.g. "status" or
+// "pickup_location.type".
+//
+//	text, params, err := NewQuery().
+//		Where("status", "=", "completed").
+//		Where("created_at", ">=", since).
+//		OrderByDesc("created_at").
+//		Limit(20).
+//		Build()
+type Query struct {
+	fields  []string
+	where   []string
+	params  []QueryParam
+	orderBy []string
+	offset  int
+	limit   int
+	err     error
+}
+
+// NewQuery creates an empty query selecting whole documents.
+func NewQuery() *Query {
+	return &Query{limit: -1}
+}
+
+// Select projects the given fields instead of whole documents.
+func (q *Query) Select(fields ...string) *Query {
+	for _, f := range fields {
+		if path, ok := q.field(f); ok {
+			q.fields = append(q.fields, path)
+		}
+	}
+	return q
+}
+
+// Where adds a comparison filter; op is one of =, !=, <, <=, >, >=.
+func (q *Query) Where(field, op string, value interface{}) *Query {
+	path, ok := q.field(field)
+	if !ok {
+		return q
+	}
+	if !cosmosOperators[op] {
+		q.setErr(fmt.Errorf("invalid operator %q", op))
+		return q
+	}
+	q.where = append(q.where, fmt.Sprintf("%s %s %s", path, op, q.param(value)))
+	return q
+}
+
+// WhereIn filters on the field matching any of the values.
+func (q *Query) WhereIn(field string, values ...interface{}) *Query {
+	path, ok := q.field(field)
+	if !ok {
+		return q
+	}
+	q.where = append(q.where, fmt.Sprintf("ARRAY_CONTAINS(%s, %s)", q.param(values), path))
+	return q
+}
+
+// WhereContains filters on the string field containing the substring.
+func (q *Query) WhereContains(field, substring string) *Query {
+	return q.whereFunc("CONTAINS", field, substring)
+}
+
+// WhereStartsWith filters on the string field starting with the prefix.
+func (q *Query) WhereStartsWith(field, prefix string) *Query {
+	return q.whereFunc("STARTSWITH", field, prefix)
+}
+
+// WhereDefined filters on the field being present (or absent when defined is false).
+func (q *Query) WhereDefined(field string, defined bool) *Query {
+	path, ok := q.field(field)
+	if !ok {
+		return q
+	}
+	clause := fmt.Sprintf("IS_DEFINED(%s)", path)
+	if !defined {
+		clause = "NOT " + clause
+	}
+	q.where = append(q.where, clause)
+	return q
+}
+
+func (q *Query) whereFunc(fn, field string, value interface{}) *Query {
+	path, ok := q.field(field)
+	if !ok {
+		return q
+	}
+	q.where = append(q.where, fmt.Sprintf("%s(%s, %s)", fn, path, q.param(value)))
+	return q
+}
+
+// OrderBy sorts ascending by the field.
+func (q *Query) OrderBy(field string) *Query {
+	if path, ok := q.field(field); ok {
+		q.orderBy = append(q.orderBy, path+" ASC")
+	}
+	return q
+}
+
+// OrderByDesc sorts descending by the field.
+func (q *Query) OrderByDesc(field string) *Query {
+	if path, ok := q.field(field); ok {
+		q.orderBy = append(q.orderBy, path+" DESC")
+	}
+	return q
+}
+
+// Offset skips the first n results. Prefer cursor paging for deep pages,
+// since Cosmos DB charges for skipped documents.
+func (q *Query) Offset(n int) *Query {
+	q.offset = n
+	if q.limit < 0 {
+		q.limit = 0
+	}
+	return q
+}
+
+// Limit caps the number of results.
+func (q *Query) Limit(n int) *Query {
+	q.limit = n
+	return q
+}
+
+// Build returns the query text and its parameters.
+func (q *Query) Build() (string, []QueryParam, error) {
+	if q.err != nil {
+		return "", nil, q.err
+	}
+	if q.offset < 0 || (q.offset > 0 && q.limit <= 0) {
+		return "", nil, fmt.Errorf("offset requires a positive limit")
+	}
+
+	var b strings.Builder
+	b.WriteString("SELECT ")
+	if len(q.fields) == 0 {
+		b.WriteString("*")
+	} else {
+		b.WriteString(strings.Join(q.fields, ", "))
+	}
+	b.WriteString(" FROM c")
+
+	if len(q.where) > 0 {
+		b.WriteString(" WHERE ")
+		b.WriteString(strings.Join(q.where, " AND "))
+	}
+	if len(q.orderBy) > 0 {
+		b.WriteString(" ORDER BY ")
+		b.WriteString(strings.Join(q.orderBy, ", "))
+	}
+	if q.limit >= 0 {
+		fmt.Fprintf(&b, " OFFSET %d LIMIT %d", q.offset, q.limit)
+	}
+
+	return b.String(), q.params, nil
+}
+
+func (q *Query) field(name string) (string, bool) {
+	if !cosmosFieldPattern.MatchString(name) {
+		q.setErr(fmt.Errorf("invalid field name %q", name))
+		return "", false
+	}
+	var b strings.Builder
+	b.WriteString("c")
+	for _, segment := range strings.Split(name, ".") {
+		if cosmosReservedWords[strings.ToUpper(segment)] {
+			fmt.Fprintf(&b, "[%q]", segment)
+		} else {
+			b.WriteString("." + segment)
+		}
+	}
+	return b.String(), true
+}
+
+func (q *Query) clone() *Query {
+	c := *q
+	c.fields = append([]string(nil), q.fields...)
+	c.where = append([]string(nil), q.where...)
+	c.params = append([]QueryParam(nil), q.params...)
+	c.orderBy = append([]string(nil), q.orderBy...)
+	return &c
+}
+
+func (q *Query) param(value interface{}) string {
+	name := fmt.Sprintf("@p%d", len(q.params))
+	q.params = append(q.params, QueryParam{Name: name, Value: value})
+	return name
+}
+
+func (q *Query) setErr(err error) {
+	if q.err == nil {
+		q.err = err
+	}
+}
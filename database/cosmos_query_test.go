@@ -0,0 +1,121 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestQuery_Build(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  *Query
+		text   string
+		params int
+	}{
+		{
+			name:  "all documents",
+			query: NewQuery(),
+			text:  "SELECT * FROM c",
+		},
+		{
+			name:   "filters and order",
+			query:  NewQuery().Where("status", "=", "completed").Where("fare", ">=", 10.5).OrderByDesc("created_at"),
+			text:   "SELECT * FROM c WHERE c.status = @p0 AND c.fare >= @p1 ORDER BY c.created_at DESC",
+			params: 2,
+		},
+		{
+			name:   "projection and paging",
+			query:  NewQuery().Select("id", "pickup_location.type").WhereIn("ride_type", "xl", "premium").Offset(40).Limit(20),
+			text:   "SELECT c.id, c.pickup_location.type FROM c WHERE ARRAY_CONTAINS(@p0, c.ride_type) OFFSET 40 LIMIT 20",
+			params: 1,
+		},
+		{
+			name:   "functions",
+			query:  NewQuery().WhereStartsWith("email", "a").WhereContains("name", "li").WhereDefined("deleted_at", false),
+			text:   "SELECT * FROM c WHERE STARTSWITH(c.email, @p0) AND CONTAINS(c.name, @p1) AND NOT IS_DEFINED(c.deleted_at)",
+			params: 2,
+		},
+		{
+			name:   "reserved words",
+			query:  NewQuery().Select("value", "fare.order").OrderBy("select"),
+			text:   `SELECT c["value"], c.fare["order"] FROM c ORDER BY c["select"] ASC`,
+			params: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, params, err := tt.query.Build()
+			if err != nil {
+				t.Fatalf("Build failed: %v", err)
+			}
+			if text != tt.text {
+				t.Errorf("expected %q, got %q", tt.text, text)
+			}
+			if len(params) != tt.params {
+				t.Errorf("expected %d params, got %d", tt.params, len(params))
+			}
+		})
+	}
+}
+
+func TestQuery_BuildRejectsInjection(t *testing.T) {
+	tests := []struct {
+		name  string
+		query *Query
+	}{
+		{"field", NewQuery().Where("status = 'x' OR 1=1 --", "=", "completed")},
+		{"operator", NewQuery().Where("status", "= 1 OR c.status", "completed")},
+		{"order", NewQuery().OrderBy("created_at; DROP")},
+		{"projection", NewQuery().Select("*")},
+		{"offset without limit", NewQuery().Offset(10)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := tt.query.Build(); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestQuery_ParamsCarryValues(t *testing.T) {
+	_, params, err := NewQuery().Where("rider_id", "=", "rider-1").WhereIn("status", "requested", "accepted").Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if params[0].Name != "@p0" || params[0].Value != "rider-1" {
+		t.Errorf("unexpected first param: %+v", params[0])
+	}
+	values, ok := params[1].Value.([]interface{})
+	if !ok || len(values) != 2 {
+		t.Errorf("expected IN values as a slice, got %+v", params[1].Value)
+	}
+}
+
+func TestQueryCursor(t *testing.T) {
+	query := "SELECT * FROM c WHERE c.status = @p0"
+	cursor := encodeCursor("token-1", "rider-1", query)
+
+	continuation, err := decodeCursor(cursor, "rider-1", query)
+	if err != nil {
+		t.Fatalf("decodeCursor failed: %v", err)
+	}
+	if continuation != "token-1" {
+		t.Errorf("expected token-1, got %q", continuation)
+	}
+
+	if c, err := decodeCursor("", "rider-1", query); err != nil || c != "" {
+		t.Errorf("expected empty cursor to start from the first page, got %q, %v", c, err)
+	}
+	if _, err := decodeCursor(cursor, "rider-2", query); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("expected ErrInvalidCursor for another partition, got %v", err)
+	}
+	if _, err := decodeCursor(cursor, "rider-1", query+" ORDER BY c.id"); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("expected ErrInvalidCursor for another query, got %v", err)
+	}
+	if _, err := decodeCursor("not a cursor!", "rider-1", query); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("expected ErrInvalidCursor for garbage, got %v", err)
+	}
+}
@@ -0,0 +1,245 @@
+// Package database provides database client utilities.
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
+	apperrors "github.com/mycobrun/cobrun-shared/errors"
+)
+
+// ErrInvalidCursor means a cursor is malformed or belongs to another query.
+// It is returned wrapped in an *errors.AppError with CodeBadRequest.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+func invalidCursor() error {
+	return apperrors.Wrap(ErrInvalidCursor, apperrors.CodeBadRequest, "invalid cursor")
+}
+
+// DefaultPageSize is the page size used when none is given.
+const DefaultPageSize = 20
+
+// QueryPage executes one page of a query. An empty partitionKey runs a
+// cross-partition query. continuation is the token returned by the previous
+// page ("" for the first); the returned token is "" after the last page.
+func (c *CosmosContainer) QueryPage(ctx context.Context, partitionKey, query string, params []QueryParam, pageSize int, continuation string, results interface{}) (string, error) {
+	pk := azcosmos.NewPartitionKey()
+	if partitionKey != "" {
+		pk = azcosmos.NewPartitionKeyString(partitionKey)
+	}
+
+	options := queryOptions(params)
+	options.PageSizeHint = int32(pageSize)
+	if continuation != "" {
+		options.ContinuationToken = &continuation
+	}
+
+	pager := c.container.NewQueryItemsPager(query, pk, options)
+	start := time.Now()
+	resp, err := pager.NextPage(ctx)
+	c.observe(ctx, "query", start, &resp.Response, err)
+	if err != nil {
+		return "", mapCosmosError("get query results", err)
+	}
+
+	data, err := json.Marshal(resp.Items)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal items: %w", err)
+	}
+	if err := json.Unmarshal(data, results); err != nil {
+		return "", fmt.Errorf("failed to unmarshal results: %w", err)
+	}
+
+	if resp.ContinuationToken == nil {
+		return "", nil
+	}
+	return *resp.ContinuationToken, nil
+}
+
+// Page is one page of query results.
+type Page[T any] struct {
+	Items []T
+	// NextCursor is the opaque cursor for the next page, "" after the last
+	// page. It can be passed as http.APIPaginated's nextCursor.
+	NextCursor string
+}
+
+// Repository provides typed access to documents of one type in a container.
+type Repository[T any] struct {
+	container    Container
+	partitionKey func(item *T) string
+	id           func(item *T) string
+}
+
+// NewRepository creates a typed repository. partitionKey and id extract the
+// partition key and ID of a document.
+func NewRepository[T any](container Container, partitionKey, id func(item *T) string) *Repository[T] {
+	return &Repository[T]{container: container, partitionKey: partitionKey, id: id}
+}
+
+// Get reads a document. Returns ErrNotFound if it does not exist.
+func (r *Repository[T]) Get(ctx context.Context, partitionKey, id string) (*T, error) {
+	var item T
+	if err := r.container.Read(ctx, partitionKey, id, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// GetWithETag reads a document and its ETag.
+func (r *Repository[T]) GetWithETag(ctx context.Context, partitionKey, id string) (*T, string, error) {
+	var item T
+	etag, err := r.container.ReadWithETag(ctx, partitionKey, id, &item)
+	if err != nil {
+		return nil, "", err
+	}
+	return &item, etag, nil
+}
+
+// Create creates a document. Returns ErrConflict if it already exists.
+func (r *Repository[T]) Create(ctx context.Context, item *T) error {
+	return r.container.Create(ctx, r.partitionKey(item), item)
+}
+
+// Update replaces an existing document; use IfMatch for optimistic concurrency.
+func (r *Repository[T]) Update(ctx context.Context, item *T, opts ...ItemOption) error {
+	return r.container.Replace(ctx, r.partitionKey(item), r.id(item), item, opts...)
+}
+
+// Upsert creates or replaces a document.
+func (r *Repository[T]) Upsert(ctx context.Context, item *T, opts ...ItemOption) error {
+	return r.container.Upsert(ctx, r.partitionKey(item), item, opts...)
+}
+
+// Modify applies modify to a document with ReadModifyWrite.
+func (r *Repository[T]) Modify(ctx context.Context, partitionKey, id string, modify func(item *T) error) (*T, error) {
+	return ReadModifyWrite(ctx, r.container, partitionKey, id, 3, modify)
+}
+
+// Delete deletes a document.
+func (r *Repository[T]) Delete(ctx context.Context, partitionKey, id string, opts ...ItemOption) error {
+	return r.container.Delete(ctx, partitionKey, id, opts...)
+}
+
+// Find returns all documents matching the query. An empty partitionKey
+// queries across partitions.
+func (r *Repository[T]) Find(ctx context.Context, partitionKey string, q *Query) ([]T, error) {
+	text, params, err := q.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	var items []T
+	if partitionKey == "" {
+		err = r.container.QueryCrossPartition(ctx, text, params, &items)
+	} else {
+		err = r.container.Query(ctx, partitionKey, text, params, &items)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// FindOne returns the first document matching the query, or ErrNotFound.
+// Within a partition the query is limited to 1; across partitions it is read
+// page by page until a document is found, since partitions may return empty
+// pages.
+func (r *Repository[T]) FindOne(ctx context.Context, partitionKey string, q *Query) (*T, error) {
+	one := q.clone()
+	if partitionKey != "" {
+		one.Limit(1)
+	} else if one.offset == 0 {
+		one.limit = -1
+	}
+	text, params, err := one.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	continuation := ""
+	for {
+		var items []T
+		continuation, err = r.container.QueryPage(ctx, partitionKey, text, params, 1, continuation, &items)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) > 0 {
+			return &items[0], nil
+		}
+		if continuation == "" {
+			return nil, apperrors.Wrap(ErrNotFound, apperrors.CodeNotFound, "no matching cosmos item")
+		}
+	}
+}
+
+// FindPage returns one page of documents matching the query. cursor is the
+// NextCursor of the previous page ("" for the first page) and must come from
+// the same query.
+func (r *Repository[T]) FindPage(ctx context.Context, partitionKey string, q *Query, pageSize int, cursor string) (*Page[T], error) {
+	text, params, err := q.Build()
+	if err != nil {
+		return nil, err
+	}
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+
+	continuation, err := decodeCursor(cursor, partitionKey, text)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []T
+	next, err := r.container.QueryPage(ctx, partitionKey, text, params, pageSize, continuation, &items)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &Page[T]{Items: items}
+	if next != "" {
+		page.NextCursor = encodeCursor(next, partitionKey, text)
+	}
+	return page, nil
+}
+
+// queryCursor is the decoded form of an opaque page cursor.
+type queryCursor struct {
+	Continuation string `json:"c"`
+	// Query is a hash of the partition key and query text the cursor belongs to
+	Query string `json:"q"`
+}
+
+func cursorQueryHash(partitionKey, query string) string {
+	sum := sha256.Sum256([]byte(partitionKey + "\x00" + query))
+	return base64.RawURLEncoding.EncodeToString(sum[:8])
+}
+
+func encodeCursor(continuation, partitionKey, query string) string {
+	data, _ := json.Marshal(queryCursor{Continuation: continuation, Query: cursorQueryHash(partitionKey, query)})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(cursor, partitionKey, query string) (string, error) {
+	if cursor == "" {
+		return "", nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", invalidCursor()
+	}
+	var c queryCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Continuation == "" {
+		return "", invalidCursor()
+	}
+	if c.Query != cursorQueryHash(partitionKey, query) {
+		return "", invalidCursor()
+	}
+	return c.Continuation, nil
+}
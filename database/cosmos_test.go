@@ -3,10 +3,13 @@ package database
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
 	"testing"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
+	apperrors "github.com/mycobrun/cobrun-shared/errors"
 )
 
 func TestCosmosDocument(t *testing.T) {
@@ -311,6 +314,34 @@ func TestCosmosErrors(t *testing.T) {
 	})
 }
 
+func TestErrPreconditionFailed(t *testing.T) {
+	wrapped := fmt.Errorf("failed to save trip: %w", statusError(http.StatusPreconditionFailed))
+
+	if !errors.Is(wrapped, ErrPreconditionFailed) {
+		t.Error("should be able to check with errors.Is")
+	}
+	if apperrors.Code(wrapped) != apperrors.CodeConflict {
+		t.Errorf("expected %s code, got %q", apperrors.CodeConflict, apperrors.Code(wrapped))
+	}
+	if isRetryable(wrapped) {
+		t.Error("precondition failures should not be retried blindly")
+	}
+	if errors.Is(wrapped, ErrConflict) {
+		t.Error("precondition failure should not match ErrConflict")
+	}
+}
+
+func TestItemOptions(t *testing.T) {
+	if itemOptions(nil) != nil {
+		t.Error("expected nil options without ItemOption")
+	}
+
+	opts := itemOptions([]ItemOption{IfMatch("etag-1")})
+	if opts == nil || opts.IfMatchEtag == nil || string(*opts.IfMatchEtag) != "etag-1" {
+		t.Errorf("expected IfMatchEtag etag-1, got %+v", opts)
+	}
+}
+
 func TestCosmosConfig_Validation(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -80,6 +80,9 @@ type Trip struct {
 
 	// TTL (optional, -1 means no expiry)
 	TTL int32 `json:"ttl,omitempty"`
+
+	// ETag is the Cosmos system ETag, used for optimistic concurrency
+	ETag string `json:"_etag,omitempty"`
 }
 
 // FareBreakdown represents the breakdown of a trip fare.
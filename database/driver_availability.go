@@ -0,0 +1,646 @@
+// Package database provides database client utilities.
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mycobrun/cobrun-shared/geo"
+	"github.com/mycobrun/cobrun-shared/telemetry"
+	"github.com/redis/go-redis/v9"
+)
+
+// DriverState is a driver's availability state.
+type DriverState string
+
+const (
+	DriverStateOffline DriverState = "offline"
+	DriverStateOnline  DriverState = "online"
+	DriverStateOffered DriverState = "offered"
+	DriverStateEnRoute DriverState = "en_route"
+	DriverStateOnTrip  DriverState = "on_trip"
+)
+
+// driverTransitions lists the states each state may move to.
+var driverTransitions = map[DriverState][]DriverState{
+	DriverStateOffline: {DriverStateOnline},
+	DriverStateOnline:  {DriverStateOffered, DriverStateOffline},
+	DriverStateOffered: {DriverStateEnRoute, DriverStateOnline, DriverStateOffline}, // accepted, declined/expired, logged off
+	DriverStateEnRoute: {DriverStateOnTrip, DriverStateOnline},                      // picked up, cancelled
+	DriverStateOnTrip:  {DriverStateOnline},                                         // completed
+}
+
+// CanTransition reports whether a driver may move from one state to another.
+func (s DriverState) CanTransition(to DriverState) bool {
+	for _, allowed := range driverTransitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrInvalidDriverTransition is returned when a transition is not allowed
+// from the driver's current state.
+var ErrInvalidDriverTransition = errors.New("invalid driver state transition")
+
+// ErrDriverNotOnline is returned for a location update from a driver who is
+// not online; the update is ignored.
+var ErrDriverNotOnline = errors.New("driver is not online")
+
+// DriverTransition is a recorded driver state change.
+type DriverTransition struct {
+	DriverID string      `json:"driver_id"`
+	City     string      `json:"city"`
+	From     DriverState `json:"from"`
+	To       DriverState `json:"to"`
+	TripID   string      `json:"trip_id,omitempty"`
+	Reason   string      `json:"reason,omitempty"`
+	Location *geo.Point  `json:"location,omitempty"`
+	At       time.Time   `json:"at"`
+}
+
+// DriverTransitionRequest describes a requested state change.
+type DriverTransitionRequest struct {
+	DriverID string
+	City     string
+	To       DriverState
+	// TripID is stored while en route or on trip.
+	TripID string
+	Reason string
+	// Location, when set, also updates the driver's position.
+	Location *geo.Point
+}
+
+// driverTransitionScript applies a transition if the current state allows it,
+// keeping the status hash, online set and geo set consistent.
+// KEYS: status hash, online set, geo set, history list, last-seen set, H3 cell hash
+// ARGV: driver ID, allowed from states, to, trip ID, lng, lat, now,
+// history entry JSON, history max length, history TTL (ms), offline status TTL (ms), now (ms),
+// H3 cell or ""
+var driverTransitionScript = redis.NewScript(`
+local from = redis.call("HGET", KEYS[1], "status")
+if not from or from == "" then
+	from = "offline"
+end
+if from == ARGV[3] then
+	return {2, from}
+end
+
+local allowed = false
+for state in string.gmatch(ARGV[2], "%S+") do
+	if state == from then
+		allowed = true
+		break
+	end
+end
+if not allowed then
+	return {0, from}
+end
+
+redis.call("HSET", KEYS[1], "status", ARGV[3], "trip_id", ARGV[4], "updated_at", ARGV[7])
+if ARGV[3] == "offline" then
+	redis.call("SREM", KEYS[2], ARGV[1])
+	redis.call("ZREM", KEYS[3], ARGV[1])
+	redis.call("ZREM", KEYS[5], ARGV[1])
+	redis.call("HDEL", KEYS[6], ARGV[1])
+	redis.call("PEXPIRE", KEYS[1], ARGV[11])
+else
+	redis.call("PERSIST", KEYS[1])
+	redis.call("SADD", KEYS[2], ARGV[1])
+	if ARGV[5] ~= "" then
+		redis.call("GEOADD", KEYS[3], ARGV[5], ARGV[6], ARGV[1])
+		redis.call("ZADD", KEYS[5], ARGV[12], ARGV[1])
+		redis.call("HSET", KEYS[1], "last_location", ARGV[6] .. "," .. ARGV[5])
+		if ARGV[13] ~= "" then
+			redis.call("HSET", KEYS[6], ARGV[1], ARGV[13])
+		end
+	end
+end
+
+local entry = cjson.decode(ARGV[8])
+entry["from"] = from
+redis.call("LPUSH", KEYS[4], cjson.encode(entry))
+redis.call("LTRIM", KEYS[4], 0, tonumber(ARGV[9]) - 1)
+redis.call("PEXPIRE", KEYS[4], ARGV[10])
+return {1, from}
+`)
+
+// driverLocationScript moves a driver in the geo set unless they are not
+// online or a newer location was already recorded.
+// KEYS: status hash, geo set, last-seen set, H3 cell hash
+// ARGV: driver ID, lng, lat, timestamp (ms), H3 cell or ""
+var driverLocationScript = redis.NewScript(`
+local status = redis.call("HGET", KEYS[1], "status")
+if not status or status == "" or status == "offline" then
+	return 0
+end
+local seen = redis.call("ZSCORE", KEYS[3], ARGV[1])
+if seen and tonumber(seen) > tonumber(ARGV[4]) then
+	return 0
+end
+redis.call("GEOADD", KEYS[2], ARGV[2], ARGV[3], ARGV[1])
+redis.call("ZADD", KEYS[3], ARGV[4], ARGV[1])
+redis.call("HSET", KEYS[1], "last_location", ARGV[3] .. "," .. ARGV[2])
+if ARGV[5] ~= "" then
+	redis.call("HSET", KEYS[4], ARGV[1], ARGV[5])
+end
+return 1
+`)
+
+// driverStatusScript is the first step of a transition in a cluster: it
+// applies the transition to the driver's status hash if the current state
+// allows it, and stamps it with a revision that orders the index updates
+// applied by driverIndexScript. Repeating the current state returns the last
+// stored location so that the indexes can be repaired.
+// KEYS: status hash, history list
+// ARGV: allowed from states, to, trip ID, lng, lat, now, history entry JSON,
+// history max length, history TTL (ms), offline status TTL (ms), now (ms)
+var driverStatusScript = redis.NewScript(`
+local from = redis.call("HGET", KEYS[1], "status")
+if not from or from == "" then
+	from = "offline"
+end
+local rev = redis.call("HGET", KEYS[1], "rev") or ""
+if from == ARGV[2] then
+	return {2, from, rev, redis.call("HGET", KEYS[1], "last_location") or ""}
+end
+
+local allowed = false
+for state in string.gmatch(ARGV[1], "%S+") do
+	if state == from then
+		allowed = true
+		break
+	end
+end
+if not allowed then
+	return {0, from, rev, ""}
+end
+
+local revision = tonumber(ARGV[11])
+if rev ~= "" and tonumber(rev) >= revision then
+	revision = tonumber(rev) + 1
+end
+rev = string.format("%.0f", revision)
+
+redis.call("HSET", KEYS[1], "status", ARGV[2], "trip_id", ARGV[3], "updated_at", ARGV[6], "rev", rev)
+if ARGV[2] == "offline" then
+	redis.call("PEXPIRE", KEYS[1], ARGV[10])
+else
+	redis.call("PERSIST", KEYS[1])
+	if ARGV[4] ~= "" then
+		redis.call("HSET", KEYS[1], "last_location", ARGV[5] .. "," .. ARGV[4])
+	end
+end
+
+local entry = cjson.decode(ARGV[7])
+entry["from"] = from
+redis.call("LPUSH", KEYS[2], cjson.encode(entry))
+redis.call("LTRIM", KEYS[2], 0, tonumber(ARGV[8]) - 1)
+redis.call("PEXPIRE", KEYS[2], ARGV[9])
+return {1, from, rev, ""}
+`)
+
+// driverStatusLocationScript is the first step of a location update in a
+// cluster: it records the location unless the driver is offline, returning
+// the revision of their state for driverIndexScript.
+// KEYS: status hash
+// ARGV: lng, lat
+var driverStatusLocationScript = redis.NewScript(`
+local state = redis.call("HMGET", KEYS[1], "status", "rev")
+if not state[1] or state[1] == "" or state[1] == "offline" then
+	return {0, ""}
+end
+redis.call("HSET", KEYS[1], "last_location", ARGV[2] .. "," .. ARGV[1])
+return {1, state[2] or ""}
+`)
+
+// driverIndexScript is the second step in a cluster: it adds a driver to or
+// removes them from a city's online, geo and last-seen sets, unless a later
+// revision was already applied. Online drivers keep their revision in the
+// revision hash; offline drivers keep it as a tombstone in the offline set
+// for the retention period, so the hash does not grow with every driver ever
+// seen. An empty revision, from a status seeded without a transition, always
+// applies. The geo set only moves for a location newer than the last seen.
+// Returns 1 if applied, 2 if the location was older than the last seen and
+// 0 if a later revision was applied.
+// KEYS: online set, geo set, last-seen set, revision hash, offline set, H3 cell hash
+// ARGV: driver ID, revision, online ("1" or "0"), lng, lat, timestamp (ms),
+// tombstone retention (ms), H3 cell or ""
+var driverIndexScript = redis.NewScript(`
+if ARGV[2] ~= "" then
+	local last = redis.call("HGET", KEYS[4], ARGV[1]) or redis.call("ZSCORE", KEYS[5], ARGV[1])
+	if last and tonumber(last) > tonumber(ARGV[2]) then
+		return 0
+	end
+end
+redis.call("ZREMRANGEBYSCORE", KEYS[5], "-inf", tonumber(ARGV[6]) - tonumber(ARGV[7]))
+
+if ARGV[3] == "0" then
+	redis.call("HDEL", KEYS[4], ARGV[1])
+	if ARGV[2] ~= "" then
+		redis.call("ZADD", KEYS[5], ARGV[2], ARGV[1])
+	end
+	redis.call("SREM", KEYS[1], ARGV[1])
+	redis.call("ZREM", KEYS[2], ARGV[1])
+	redis.call("ZREM", KEYS[3], ARGV[1])
+	redis.call("HDEL", KEYS[6], ARGV[1])
+	return 1
+end
+
+if ARGV[2] ~= "" then
+	redis.call("HSET", KEYS[4], ARGV[1], ARGV[2])
+	redis.call("ZREM", KEYS[5], ARGV[1])
+end
+redis.call("SADD", KEYS[1], ARGV[1])
+if ARGV[4] ~= "" then
+	local seen = redis.call("ZSCORE", KEYS[3], ARGV[1])
+	if seen and tonumber(seen) > tonumber(ARGV[6]) then
+		return 2
+	end
+	redis.call("GEOADD", KEYS[2], ARGV[4], ARGV[5], ARGV[1])
+	redis.call("ZADD", KEYS[3], ARGV[6], ARGV[1])
+	if ARGV[8] ~= "" then
+		redis.call("HSET", KEYS[6], ARGV[1], ARGV[8])
+	end
+end
+return 1
+`)
+
+// DriverAvailability enforces the driver availability state machine
+// (offline → online → offered → en_route → on_trip → online) in Redis.
+type DriverAvailability struct {
+	client     *RedisClient
+	metrics    *telemetry.BusinessMetrics
+	h3         *geo.H3Index
+	historyLen int
+}
+
+// NewDriverAvailability creates a new driver availability state machine.
+// Driver H3 cells are stored at block resolution.
+func NewDriverAvailability(client *RedisClient) *DriverAvailability {
+	return &DriverAvailability{client: client, h3: geo.NewH3Index(geo.H3ResolutionBlock), historyLen: 100}
+}
+
+// WithH3Resolution sets the resolution of the H3 cell stored with each
+// driver location (0 disables them).
+func (a *DriverAvailability) WithH3Resolution(resolution geo.H3Resolution) *DriverAvailability {
+	a.h3 = nil
+	if resolution > 0 {
+		a.h3 = geo.NewH3Index(resolution)
+	}
+	return a
+}
+
+// WithMetrics records drivers going online and offline.
+func (a *DriverAvailability) WithMetrics(metrics *telemetry.BusinessMetrics) *DriverAvailability {
+	a.metrics = metrics
+	return a
+}
+
+// Transition atomically applies a state change, keeping the city's online
+// and geo sets consistent with it. Requesting the current state is a no-op
+// and returns a transition with From equal to To. Disallowed transitions
+// return ErrInvalidDriverTransition.
+//
+// In a cluster the status hash and the city's sets are on different slots,
+// so the sets are updated in a second step; requesting the current state
+// then repairs them in case that step failed.
+func (a *DriverAvailability) Transition(ctx context.Context, req DriverTransitionRequest) (*DriverTransition, error) {
+	var allowed []string
+	for from, targets := range driverTransitions {
+		for _, to := range targets {
+			if to == req.To {
+				allowed = append(allowed, string(from))
+			}
+		}
+	}
+	if len(allowed) == 0 {
+		return nil, fmt.Errorf("%w: unknown state %q", ErrInvalidDriverTransition, req.To)
+	}
+
+	transition := &DriverTransition{
+		DriverID: req.DriverID,
+		City:     req.City,
+		To:       req.To,
+		TripID:   req.TripID,
+		Reason:   req.Reason,
+		Location: req.Location,
+		At:       time.Now().UTC(),
+	}
+	entry, err := json.Marshal(transition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal transition: %w", err)
+	}
+
+	var lng, lat string
+	if req.Location != nil {
+		lng = strconv.FormatFloat(req.Location.Lng, 'f', -1, 64)
+		lat = strconv.FormatFloat(req.Location.Lat, 'f', -1, 64)
+	}
+
+	args := []interface{}{
+		strings.Join(allowed, " "), string(req.To), req.TripID, lng, lat,
+		transition.At.Format(time.RFC3339Nano), entry, a.historyLen,
+		RedisTTLs.DriverStatusHistory.Milliseconds(), RedisTTLs.DriverStatus.Milliseconds(),
+		transition.At.UnixMilli(),
+	}
+	if req.Location != nil {
+		args = append(args, a.cell(lng, lat))
+	} else {
+		args = append(args, "")
+	}
+	var applied int64
+	var from string
+	if a.client.Mode() == RedisModeCluster {
+		applied, from, err = a.transitionInSteps(ctx, req, lng, lat, transition.At, args)
+	} else {
+		keys := []string{
+			a.client.Key(RedisKeyPatterns.DriverStatus, req.DriverID),
+			a.client.Key(RedisKeyPatterns.OnlineDrivers, req.City),
+			a.client.Key(RedisKeyPatterns.DriverLocations, req.City),
+			a.client.Key(RedisKeyPatterns.DriverStatusHistory, req.DriverID),
+			a.client.Key(RedisKeyPatterns.DriverLastSeen, req.City),
+			a.client.Key(RedisKeyPatterns.DriverH3Cells, req.City),
+		}
+		var res []interface{}
+		res, err = driverTransitionScript.Run(ctx, a.client.client, keys, append([]interface{}{req.DriverID}, args...)...).Slice()
+		if err == nil {
+			applied, _ = res[0].(int64)
+			from, _ = res[1].(string)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to transition driver: %w", err)
+	}
+	transition.From = DriverState(from)
+
+	switch applied {
+	case 0:
+		return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidDriverTransition, transition.From, req.To)
+	case 2:
+		return transition, nil
+	}
+
+	if a.metrics != nil {
+		if transition.From == DriverStateOffline {
+			a.metrics.RecordDriverOnline(ctx, req.City)
+		} else if transition.To == DriverStateOffline {
+			a.metrics.RecordDriverOffline(ctx, req.City)
+		}
+	}
+
+	return transition, nil
+}
+
+// transitionInSteps applies a transition to the status hash, then to the
+// city's sets. A repeated transition re-adds the driver at their last stored
+// location, in case the sets were not updated after the original one.
+func (a *DriverAvailability) transitionInSteps(ctx context.Context, req DriverTransitionRequest, lng, lat string, at time.Time, args []interface{}) (int64, string, error) {
+	keys := []string{
+		a.client.Key(RedisKeyPatterns.DriverStatus, req.DriverID),
+		a.client.Key(RedisKeyPatterns.DriverStatusHistory, req.DriverID),
+	}
+	res, err := driverStatusScript.Run(ctx, a.client.client, keys, args...).Slice()
+	if err != nil {
+		return 0, "", err
+	}
+
+	applied, _ := res[0].(int64)
+	from, _ := res[1].(string)
+	revision, _ := res[2].(string)
+	if applied == 0 {
+		return applied, from, nil
+	}
+	if applied == 2 {
+		lng, lat = "", ""
+		// last_location is stored as "lat,lng"
+		if lastLocation, _ := res[3].(string); lastLocation != "" {
+			lat, lng, _ = strings.Cut(lastLocation, ",")
+		}
+	}
+
+	if err := a.index(ctx, req.DriverID, req.City, revision, req.To != DriverStateOffline, lng, lat, at); err != nil {
+		return 0, "", err
+	}
+	return applied, from, nil
+}
+
+// GoOnline moves an offline driver online at the given location.
+func (a *DriverAvailability) GoOnline(ctx context.Context, driverID, city string, location geo.Point) (*DriverTransition, error) {
+	return a.Transition(ctx, DriverTransitionRequest{DriverID: driverID, City: city, To: DriverStateOnline, Location: &location})
+}
+
+// GoOffline moves a driver offline, removing them from the online and geo sets.
+func (a *DriverAvailability) GoOffline(ctx context.Context, driverID, city, reason string) (*DriverTransition, error) {
+	return a.Transition(ctx, DriverTransitionRequest{DriverID: driverID, City: city, To: DriverStateOffline, Reason: reason})
+}
+
+// MarkOffered reserves an online driver for an offer.
+func (a *DriverAvailability) MarkOffered(ctx context.Context, driverID, city string) (*DriverTransition, error) {
+	return a.Transition(ctx, DriverTransitionRequest{DriverID: driverID, City: city, To: DriverStateOffered})
+}
+
+// MarkEnRoute moves an offered driver en route to the pickup.
+func (a *DriverAvailability) MarkEnRoute(ctx context.Context, driverID, city, tripID string) (*DriverTransition, error) {
+	return a.Transition(ctx, DriverTransitionRequest{DriverID: driverID, City: city, To: DriverStateEnRoute, TripID: tripID})
+}
+
+// MarkOnTrip moves a driver on trip after pickup.
+func (a *DriverAvailability) MarkOnTrip(ctx context.Context, driverID, city, tripID string) (*DriverTransition, error) {
+	return a.Transition(ctx, DriverTransitionRequest{DriverID: driverID, City: city, To: DriverStateOnTrip, TripID: tripID})
+}
+
+// MarkAvailable returns a driver to online after a declined or expired offer,
+// a cancellation or a completed trip.
+func (a *DriverAvailability) MarkAvailable(ctx context.Context, driverID, city, reason string) (*DriverTransition, error) {
+	return a.Transition(ctx, DriverTransitionRequest{DriverID: driverID, City: city, To: DriverStateOnline, Reason: reason})
+}
+
+// UpdateLocation moves a driver in the geo set. Location updates from drivers
+// who are not online are ignored so a late update cannot resurrect them; the
+// returned bool reports whether the update was applied.
+func (a *DriverAvailability) UpdateLocation(ctx context.Context, driverID, city string, location geo.Point) (bool, error) {
+	applied, err := a.UpdateLocations(ctx, []DriverLocationUpdate{{
+		DriverID:  driverID,
+		City:      city,
+		Lat:       location.Lat,
+		Lng:       location.Lng,
+		Timestamp: time.Now(),
+	}})
+	if err != nil {
+		return false, err
+	}
+	return applied[0], nil
+}
+
+// UpdateLocations applies location updates like UpdateLocation, pipelined
+// into one round trip (two in a cluster), and reports which were applied.
+// Updates older than a driver's last-seen time are ignored too.
+func (a *DriverAvailability) UpdateLocations(ctx context.Context, updates []DriverLocationUpdate) ([]bool, error) {
+	applied := make([]bool, len(updates))
+	if len(updates) == 0 {
+		return applied, nil
+	}
+	coords := make([][2]string, len(updates))
+	for i, u := range updates {
+		coords[i] = [2]string{strconv.FormatFloat(u.Lng, 'f', -1, 64), strconv.FormatFloat(u.Lat, 'f', -1, 64)}
+	}
+
+	if a.client.Mode() != RedisModeCluster {
+		cmds, err := a.runScripts(ctx, driverLocationScript, func(pipe redis.Pipeliner) {
+			for i, u := range updates {
+				keys := []string{
+					a.client.Key(RedisKeyPatterns.DriverStatus, u.DriverID),
+					a.client.Key(RedisKeyPatterns.DriverLocations, u.City),
+					a.client.Key(RedisKeyPatterns.DriverLastSeen, u.City),
+					a.client.Key(RedisKeyPatterns.DriverH3Cells, u.City),
+				}
+				driverLocationScript.EvalSha(ctx, pipe, keys, u.DriverID, coords[i][0], coords[i][1],
+					u.Timestamp.UnixMilli(), a.cell(coords[i][0], coords[i][1]))
+			}
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to update driver locations: %w", err)
+		}
+		for i, cmd := range cmds {
+			n, _ := cmd.(*redis.Cmd).Int64()
+			applied[i] = n == 1
+		}
+		return applied, nil
+	}
+
+	// In a cluster, record the location in each status hash first, then
+	// index the drivers that are online.
+	cmds, err := a.runScripts(ctx, driverStatusLocationScript, func(pipe redis.Pipeliner) {
+		for i, u := range updates {
+			driverStatusLocationScript.EvalSha(ctx, pipe,
+				[]string{a.client.Key(RedisKeyPatterns.DriverStatus, u.DriverID)}, coords[i][0], coords[i][1])
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update driver locations: %w", err)
+	}
+	revisions := make(map[int]string, len(updates))
+	for i, cmd := range cmds {
+		res, _ := cmd.(*redis.Cmd).Slice()
+		if n, _ := res[0].(int64); n == 1 {
+			revisions[i], _ = res[1].(string)
+		}
+	}
+	if len(revisions) == 0 {
+		return applied, nil
+	}
+
+	indexed := make(map[int]*redis.Cmd, len(revisions))
+	_, err = a.runScripts(ctx, driverIndexScript, func(pipe redis.Pipeliner) {
+		for i, revision := range revisions {
+			u := updates[i]
+			indexed[i] = driverIndexScript.EvalSha(ctx, pipe, a.indexKeys(u.City), u.DriverID, revision, "1",
+				coords[i][0], coords[i][1], u.Timestamp.UnixMilli(), RedisTTLs.DriverStatus.Milliseconds(),
+				a.cell(coords[i][0], coords[i][1]))
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to index drivers: %w", err)
+	}
+	for i, cmd := range indexed {
+		n, _ := cmd.Int64()
+		applied[i] = n == 1
+	}
+	return applied, nil
+}
+
+// runScripts runs the EvalSha calls queued by queue in one pipeline, loading
+// script and retrying once if Redis does not have it cached.
+func (a *DriverAvailability) runScripts(ctx context.Context, script *redis.Script, queue func(pipe redis.Pipeliner)) ([]redis.Cmder, error) {
+	pipe := a.client.client.Pipeline()
+	queue(pipe)
+	cmds, err := pipe.Exec(ctx)
+	if err != nil && redis.HasErrorPrefix(err, "NOSCRIPT") {
+		if err := script.Load(ctx, a.client.client).Err(); err != nil {
+			return nil, err
+		}
+		pipe = a.client.client.Pipeline()
+		queue(pipe)
+		cmds, err = pipe.Exec(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cmds, nil
+}
+
+// cell returns the H3 cell stored for a location, or "" if disabled.
+func (a *DriverAvailability) cell(lng, lat string) string {
+	if a.h3 == nil || lng == "" {
+		return ""
+	}
+	lngValue, _ := strconv.ParseFloat(lng, 64)
+	latValue, _ := strconv.ParseFloat(lat, 64)
+	return a.h3.GetCellString(geo.NewPoint(latValue, lngValue))
+}
+
+// index updates the city's online, geo and last-seen sets after a change to
+// the driver's status hash in a cluster. The two are on different slots, so
+// they are updated by separate scripts ordered by the status revision: an
+// index update racing with a later transition is dropped instead of undoing it.
+func (a *DriverAvailability) index(ctx context.Context, driverID, city, revision string, online bool, lng, lat string, at time.Time) error {
+	flag := "0"
+	if online {
+		flag = "1"
+	}
+	err := driverIndexScript.Run(ctx, a.client.client, a.indexKeys(city), driverID, revision, flag, lng, lat,
+		at.UnixMilli(), RedisTTLs.DriverStatus.Milliseconds(), a.cell(lng, lat)).Err()
+	if err != nil {
+		return fmt.Errorf("failed to index driver: %w", err)
+	}
+	return nil
+}
+
+func (a *DriverAvailability) indexKeys(city string) []string {
+	return []string{
+		a.client.Key(RedisKeyPatterns.OnlineDrivers, city),
+		a.client.Key(RedisKeyPatterns.DriverLocations, city),
+		a.client.Key(RedisKeyPatterns.DriverLastSeen, city),
+		a.client.Key(RedisKeyPatterns.DriverRevisions, city),
+		a.client.Key(RedisKeyPatterns.DriverOfflineRevisions, city),
+		a.client.Key(RedisKeyPatterns.DriverH3Cells, city),
+	}
+}
+
+// State returns a driver's current state; drivers without status are offline.
+func (a *DriverAvailability) State(ctx context.Context, driverID string) (DriverState, error) {
+	status, err := a.client.HGet(ctx, a.client.Key(RedisKeyPatterns.DriverStatus, driverID), "status")
+	if errors.Is(err, ErrKeyNotFound) || (err == nil && status == "") {
+		return DriverStateOffline, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return DriverState(status), nil
+}
+
+// History returns up to limit recent transitions, newest first.
+func (a *DriverAvailability) History(ctx context.Context, driverID string, limit int64) ([]DriverTransition, error) {
+	key := a.client.Key(RedisKeyPatterns.DriverStatusHistory, driverID)
+	entries, err := a.client.client.LRange(ctx, key, 0, limit-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get driver history: %w", err)
+	}
+
+	history := make([]DriverTransition, 0, len(entries))
+	for _, entry := range entries {
+		var t DriverTransition
+		if err := json.Unmarshal([]byte(entry), &t); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal transition: %w", err)
+		}
+		history = append(history, t)
+	}
+	return history, nil
+}
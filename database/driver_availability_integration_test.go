@@ -0,0 +1,137 @@
+//go:build integration
+
+// Package database provides database client utilities.
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mycobrun/cobrun-shared/geo"
+)
+
+func TestDriverAvailability_Integration(t *testing.T) {
+	ctx, client := newIntegrationRedisClient(t)
+	availability := NewDriverAvailability(client)
+
+	city := "seattle"
+	driverID := "driver-sm-1"
+	location := geo.NewPoint(47.6062, -122.3321)
+	geoKey := fmt.Sprintf(RedisKeyPatterns.DriverLocations, city)
+	onlineKey := fmt.Sprintf(RedisKeyPatterns.OnlineDrivers, city)
+
+	t.Run("FullCycle", func(t *testing.T) {
+		steps := []func() (*DriverTransition, error){
+			func() (*DriverTransition, error) { return availability.GoOnline(ctx, driverID, city, location) },
+			func() (*DriverTransition, error) { return availability.MarkOffered(ctx, driverID, city) },
+			func() (*DriverTransition, error) { return availability.MarkEnRoute(ctx, driverID, city, "trip-1") },
+			func() (*DriverTransition, error) { return availability.MarkOnTrip(ctx, driverID, city, "trip-1") },
+			func() (*DriverTransition, error) { return availability.MarkAvailable(ctx, driverID, city, "completed") },
+		}
+		for i, step := range steps {
+			if _, err := step(); err != nil {
+				t.Fatalf("step %d failed: %v", i, err)
+			}
+		}
+
+		state, err := availability.State(ctx, driverID)
+		if err != nil || state != DriverStateOnline {
+			t.Fatalf("expected online, got %s (%v)", state, err)
+		}
+
+		history, err := availability.History(ctx, driverID, 10)
+		if err != nil {
+			t.Fatalf("History failed: %v", err)
+		}
+		if len(history) != 5 || history[0].From != DriverStateOnTrip || history[4].From != DriverStateOffline {
+			t.Errorf("unexpected history %+v", history)
+		}
+	})
+
+	t.Run("InvalidTransition", func(t *testing.T) {
+		_, err := availability.MarkOnTrip(ctx, driverID, city, "trip-2")
+		if !errors.Is(err, ErrInvalidDriverTransition) {
+			t.Errorf("expected ErrInvalidDriverTransition, got %v", err)
+		}
+	})
+
+	t.Run("OfflineClearsSets", func(t *testing.T) {
+		if _, err := availability.GoOffline(ctx, driverID, city, "logged_out"); err != nil {
+			t.Fatalf("GoOffline failed: %v", err)
+		}
+
+		member, _ := client.SIsMember(ctx, onlineKey, driverID)
+		if member {
+			t.Error("expected driver removed from online set")
+		}
+		pos, _ := client.GeoPos(ctx, geoKey, driverID)
+		if len(pos) != 1 || pos[0] != nil {
+			t.Error("expected driver removed from geo set")
+		}
+
+		// A late location update must not resurrect the driver
+		applied, err := availability.UpdateLocation(ctx, driverID, city, location)
+		if err != nil {
+			t.Fatalf("UpdateLocation failed: %v", err)
+		}
+		if applied {
+			t.Error("expected location update for offline driver to be ignored")
+		}
+	})
+	t.Run("LocationService", func(t *testing.T) {
+		locations := NewDriverLocationService(client)
+		id := "driver-sm-2"
+
+		if err := locations.UpdateLocation(ctx, id, city, location.Lat, location.Lng); !errors.Is(err, ErrDriverNotOnline) {
+			t.Errorf("expected ErrDriverNotOnline before going online, got %v", err)
+		}
+		if err := locations.SetDriverOnline(ctx, id, city); err != nil {
+			t.Fatalf("SetDriverOnline failed: %v", err)
+		}
+		if err := locations.UpdateLocation(ctx, id, city, location.Lat, location.Lng); err != nil {
+			t.Fatalf("UpdateLocation failed: %v", err)
+		}
+		nearby, err := locations.GetNearbyDrivers(ctx, city, location.Lat, location.Lng, 1)
+		if err != nil || len(nearby) != 1 || nearby[0].Name != id {
+			t.Errorf("expected the driver nearby, got %+v, %v", nearby, err)
+		}
+
+		// Seeding details leaves the state alone
+		if err := locations.SetDriverStatus(ctx, id, &DriverStatusData{VehicleID: "vehicle-1", Rating: 4.9}); err != nil {
+			t.Fatalf("SetDriverStatus failed: %v", err)
+		}
+		if state, _ := availability.State(ctx, id); state != DriverStateOnline {
+			t.Errorf("expected the driver still online, got %s", state)
+		}
+
+		if err := locations.SetDriverOffline(ctx, id, city); err != nil {
+			t.Fatalf("SetDriverOffline failed: %v", err)
+		}
+		if state, _ := availability.State(ctx, id); state != DriverStateOffline {
+			t.Errorf("expected the driver offline, got %s", state)
+		}
+	})
+	t.Run("StaleIndexUpdateDropped", func(t *testing.T) {
+		revisionsKey := fmt.Sprintf(RedisKeyPatterns.DriverRevisions, city)
+		rev := time.Now().UnixMilli()
+		if err := availability.index(ctx, driverID, city, fmt.Sprint(rev), true, "-122.3321", "47.6062", time.Now()); err != nil {
+			t.Fatalf("index failed: %v", err)
+		}
+		if err := availability.index(ctx, driverID, city, fmt.Sprint(rev+2), false, "", "", time.Now()); err != nil {
+			t.Fatalf("index failed: %v", err)
+		}
+		if _, err := client.HGet(ctx, revisionsKey, driverID); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("expected the revision removed once offline, got %v", err)
+		}
+
+		// The index update of an earlier transition online arrives late
+		if err := availability.index(ctx, driverID, city, fmt.Sprint(rev+1), true, "-122.3321", "47.6062", time.Now()); err != nil {
+			t.Fatalf("index failed: %v", err)
+		}
+		if member, _ := client.SIsMember(ctx, onlineKey, driverID); member {
+			t.Error("a stale index update should not put the driver back online")
+		}
+	})
+}
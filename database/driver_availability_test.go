@@ -0,0 +1,27 @@
+package database
+
+import "testing"
+
+func TestDriverState_CanTransition(t *testing.T) {
+	tests := []struct {
+		from, to DriverState
+		want     bool
+	}{
+		{DriverStateOffline, DriverStateOnline, true},
+		{DriverStateOffline, DriverStateOffered, false},
+		{DriverStateOnline, DriverStateOffered, true},
+		{DriverStateOnline, DriverStateOnTrip, false},
+		{DriverStateOffered, DriverStateEnRoute, true},
+		{DriverStateOffered, DriverStateOnline, true},
+		{DriverStateEnRoute, DriverStateOnTrip, true},
+		{DriverStateEnRoute, DriverStateOffline, false},
+		{DriverStateOnTrip, DriverStateOnline, true},
+		{DriverStateOnTrip, DriverStateOffline, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.CanTransition(tt.to); got != tt.want {
+			t.Errorf("%s -> %s: expected %v, got %v", tt.from, tt.to, tt.want, got)
+		}
+	}
+}
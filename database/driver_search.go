@@ -0,0 +1,415 @@
+// Package database provides database client utilities.
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/mycobrun/cobrun-shared/database/cosmosdb"
+	"github.com/mycobrun/cobrun-shared/geo"
+	"github.com/mycobrun/cobrun-shared/vehicle"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrSearchExhausted is returned when every search step ran without a match.
+var ErrSearchExhausted = errors.New("driver search exhausted")
+
+// SearchStep is one widening step of a driver search.
+type SearchStep struct {
+	// RadiusKm is used by radius-based candidate sources.
+	RadiusKm float64
+	// KRings is used by H3-based candidate sources.
+	KRings int
+	// After is the delay since the previous step before this one runs.
+	After time.Duration
+}
+
+// DriverCandidate is a driver found during a search.
+type DriverCandidate struct {
+	DriverID     string        `json:"driver_id"`
+	Location     geo.Point     `json:"location"`
+	DistanceKm   float64       `json:"distance_km"`
+	VehicleClass vehicle.Class `json:"vehicle_class,omitempty"`
+	Rating       float64       `json:"rating,omitempty"`
+	// Score ranks candidates; lower is better.
+	Score float64 `json:"score"`
+}
+
+// DriverInfo is the dispatch-relevant state of a driver.
+type DriverInfo struct {
+	Online       bool
+	Status       string
+	VehicleClass vehicle.Class
+	Rating       float64
+}
+
+// DriverCandidateSource finds drivers around a pickup for one search step.
+type DriverCandidateSource interface {
+	FindDrivers(ctx context.Context, city string, pickup geo.Point, step SearchStep) ([]DriverCandidate, error)
+}
+
+// DriverInfoProvider looks up the state of candidate drivers.
+// Drivers missing from the returned map are treated as unavailable.
+type DriverInfoProvider interface {
+	GetDriverInfo(ctx context.Context, city string, driverIDs []string) (map[string]DriverInfo, error)
+}
+
+// DriverSearchConfig holds driver search configuration.
+type DriverSearchConfig struct {
+	// Steps is the widening schedule; the last step is the widest.
+	Steps []SearchStep
+	// MaxCandidates limits the ranked candidates returned per attempt.
+	MaxCandidates int
+	// AvailableStatuses are the driver statuses that may receive offers.
+	// Empty means any status of an online driver.
+	AvailableStatuses []string
+	// DistanceWeight and RatingWeight balance the ranking score.
+	DistanceWeight float64
+	RatingWeight   float64
+	// UpgradePenalty is added for drivers of a higher class than requested,
+	// so an exact match is preferred at similar distance.
+	UpgradePenalty float64
+}
+
+// DefaultDriverSearchConfig returns sensible defaults.
+func DefaultDriverSearchConfig() DriverSearchConfig {
+	return DriverSearchConfig{
+		Steps: []SearchStep{
+			{RadiusKm: 1, KRings: 1},
+			{RadiusKm: 2, KRings: 2, After: 15 * time.Second},
+			{RadiusKm: 3.5, KRings: 3, After: 15 * time.Second},
+			{RadiusKm: 5, KRings: 5, After: 20 * time.Second},
+			{RadiusKm: 8, KRings: 8, After: 30 * time.Second},
+		},
+		MaxCandidates:     10,
+		AvailableStatuses: []string{"online", "available"},
+		DistanceWeight:    0.7,
+		RatingWeight:      0.3,
+		UpgradePenalty:    0.1,
+	}
+}
+
+// SearchOptions holds per-request search options.
+type SearchOptions struct {
+	City string
+	// VehicleClass defaults to the request's ride type.
+	VehicleClass vehicle.Class
+	// ExcludeDriverIDs skips drivers who already declined or timed out.
+	ExcludeDriverIDs []string
+}
+
+// SearchResult is the outcome of one search attempt.
+type SearchResult struct {
+	Attempt    int               `json:"attempt"`
+	Step       SearchStep        `json:"step"`
+	Candidates []DriverCandidate `json:"candidates"`
+	// Exhausted is set when this attempt used the widest step.
+	Exhausted bool `json:"exhausted"`
+}
+
+// DriverSearch runs expanding-radius driver searches for ride requests.
+type DriverSearch struct {
+	source   DriverCandidateSource
+	info     DriverInfoProvider
+	config   DriverSearchConfig
+	recorder func(ctx context.Context, req *cosmosdb.RideRequest) error
+}
+
+// NewDriverSearch creates a new driver search.
+func NewDriverSearch(source DriverCandidateSource, info DriverInfoProvider, config DriverSearchConfig) *DriverSearch {
+	if len(config.Steps) == 0 {
+		config.Steps = DefaultDriverSearchConfig().Steps
+	}
+	return &DriverSearch{
+		source: source,
+		info:   info,
+		config: config,
+	}
+}
+
+// WithRequestRecorder persists the request after each attempt updates
+// SearchAttempts and CurrentRadius (e.g. a Cosmos upsert).
+func (s *DriverSearch) WithRequestRecorder(recorder func(ctx context.Context, req *cosmosdb.RideRequest) error) *DriverSearch {
+	s.recorder = recorder
+	return s
+}
+
+// Search runs the next attempt for the request, based on req.SearchAttempts,
+// and records the attempt on the request.
+func (s *DriverSearch) Search(ctx context.Context, req *cosmosdb.RideRequest, opts SearchOptions) (*SearchResult, error) {
+	attempt := req.SearchAttempts
+	index := attempt
+	if index >= len(s.config.Steps) {
+		index = len(s.config.Steps) - 1
+	}
+	step := s.config.Steps[index]
+
+	candidates, err := s.findCandidates(ctx, req, opts, step)
+	if err != nil {
+		return nil, err
+	}
+
+	req.SearchAttempts = attempt + 1
+	req.CurrentRadius = step.RadiusKm
+	req.UpdatedAt = time.Now().UTC()
+	if s.recorder != nil {
+		if err := s.recorder(ctx, req); err != nil {
+			return nil, fmt.Errorf("failed to record search attempt: %w", err)
+		}
+	}
+
+	return &SearchResult{
+		Attempt:    attempt + 1,
+		Step:       step,
+		Candidates: candidates,
+		Exhausted:  index == len(s.config.Steps)-1,
+	}, nil
+}
+
+// SearchUntil runs attempts on the configured schedule until accept returns
+// true, the schedule is exhausted or ctx is done. accept is called for every
+// attempt, including ones without candidates, so callers can offer the ride
+// and add decliners to opts.ExcludeDriverIDs between attempts.
+func (s *DriverSearch) SearchUntil(ctx context.Context, req *cosmosdb.RideRequest, opts *SearchOptions, accept func(ctx context.Context, result *SearchResult) (bool, error)) (*SearchResult, error) {
+	for {
+		result, err := s.Search(ctx, req, *opts)
+		if err != nil {
+			return nil, err
+		}
+
+		done, err := accept(ctx, result)
+		if err != nil {
+			return result, err
+		}
+		if done {
+			return result, nil
+		}
+		if result.Exhausted {
+			return result, ErrSearchExhausted
+		}
+
+		next := s.config.Steps[req.SearchAttempts]
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		case <-time.After(next.After):
+		}
+	}
+}
+
+func (s *DriverSearch) findCandidates(ctx context.Context, req *cosmosdb.RideRequest, opts SearchOptions, step SearchStep) ([]DriverCandidate, error) {
+	pickup := geo.NewPoint(req.PickupLocation.Lat(), req.PickupLocation.Lng())
+
+	found, err := s.source.FindDrivers(ctx, opts.City, pickup, step)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find drivers: %w", err)
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+
+	excluded := make(map[string]bool, len(opts.ExcludeDriverIDs))
+	for _, id := range opts.ExcludeDriverIDs {
+		excluded[id] = true
+	}
+
+	ids := make([]string, 0, len(found))
+	for _, c := range found {
+		if !excluded[c.DriverID] {
+			ids = append(ids, c.DriverID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	info, err := s.info.GetDriverInfo(ctx, opts.City, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get driver info: %w", err)
+	}
+
+	class := opts.VehicleClass
+	if class == "" {
+		class = vehicle.Class(req.RideType)
+	}
+
+	candidates := make([]DriverCandidate, 0, len(ids))
+	for _, c := range found {
+		if excluded[c.DriverID] {
+			continue
+		}
+		di, ok := info[c.DriverID]
+		if !ok || !di.Online || !s.statusAvailable(di.Status) {
+			continue
+		}
+		if class.IsValid() && !di.VehicleClass.CanFulfill(class) {
+			continue
+		}
+
+		c.VehicleClass = di.VehicleClass
+		c.Rating = di.Rating
+		c.Score = s.score(c, class, step)
+		candidates = append(candidates, c)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score < candidates[j].Score
+	})
+	if s.config.MaxCandidates > 0 && len(candidates) > s.config.MaxCandidates {
+		candidates = candidates[:s.config.MaxCandidates]
+	}
+
+	return candidates, nil
+}
+
+func (s *DriverSearch) statusAvailable(status string) bool {
+	if len(s.config.AvailableStatuses) == 0 {
+		return true
+	}
+	for _, st := range s.config.AvailableStatuses {
+		if st == status {
+			return true
+		}
+	}
+	return false
+}
+
+// score ranks a candidate: distance relative to the search radius and rating
+// shortfall from 5 stars, both normalised to 0-1. Lower is better.
+func (s *DriverSearch) score(c DriverCandidate, class vehicle.Class, step SearchStep) float64 {
+	radius := step.RadiusKm
+	if radius <= 0 {
+		radius = 1
+	}
+	distance := math.Min(c.DistanceKm/radius, 1)
+
+	rating := 0.0
+	if c.Rating > 0 {
+		rating = (5 - math.Min(c.Rating, 5)) / 5
+	}
+
+	score := s.config.DistanceWeight*distance + s.config.RatingWeight*rating
+	if class.IsValid() && c.VehicleClass != class {
+		score += s.config.UpgradePenalty
+	}
+	return score
+}
+
+// RedisCandidateSource finds drivers with a Redis GEO radius query.
+type RedisCandidateSource struct {
+	locations *DriverLocationService
+}
+
+// NewRedisCandidateSource creates a candidate source backed by driver locations in Redis.
+func NewRedisCandidateSource(locations *DriverLocationService) *RedisCandidateSource {
+	return &RedisCandidateSource{locations: locations}
+}
+
+// FindDrivers returns drivers within the step radius.
+func (s *RedisCandidateSource) FindDrivers(ctx context.Context, city string, pickup geo.Point, step SearchStep) ([]DriverCandidate, error) {
+	locations, err := s.locations.GetNearbyDrivers(ctx, city, pickup.Lat, pickup.Lng, step.RadiusKm)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]DriverCandidate, len(locations))
+	for i, loc := range locations {
+		candidates[i] = DriverCandidate{
+			DriverID:   loc.Name,
+			Location:   geo.NewPoint(loc.Latitude, loc.Longitude),
+			DistanceKm: loc.Dist,
+		}
+	}
+	return candidates, nil
+}
+
+// H3CandidateSource finds drivers in an in-memory H3 index by k-ring.
+// Distances are measured to the center of the driver's cell.
+type H3CandidateSource struct {
+	index *geo.H3DriverIndex
+	h3    *geo.H3Index
+}
+
+// NewH3CandidateSource creates a candidate source backed by an H3 driver index
+// built at the given resolution.
+func NewH3CandidateSource(index *geo.H3DriverIndex, resolution geo.H3Resolution) *H3CandidateSource {
+	return &H3CandidateSource{index: index, h3: geo.NewH3Index(resolution)}
+}
+
+// FindDrivers returns drivers within the step's k-rings of the pickup cell.
+func (s *H3CandidateSource) FindDrivers(ctx context.Context, city string, pickup geo.Point, step SearchStep) ([]DriverCandidate, error) {
+	ids := s.index.GetDriversNearby(pickup, step.KRings)
+
+	candidates := make([]DriverCandidate, 0, len(ids))
+	for _, id := range ids {
+		cellStr, ok := s.index.GetDriverCell(id)
+		if !ok {
+			continue
+		}
+		cell, err := s.h3.StringToCell(cellStr)
+		if err != nil {
+			continue
+		}
+		center := s.h3.CellToLatLng(cell)
+		candidates = append(candidates, DriverCandidate{
+			DriverID:   id,
+			Location:   center,
+			DistanceKm: geo.HaversineDistance(pickup, center),
+		})
+	}
+	return candidates, nil
+}
+
+// RedisDriverInfoProvider reads driver state from the online set and the
+// driver status hash.
+type RedisDriverInfoProvider struct {
+	client *RedisClient
+}
+
+// NewRedisDriverInfoProvider creates a new Redis-backed driver info provider.
+func NewRedisDriverInfoProvider(client *RedisClient) *RedisDriverInfoProvider {
+	return &RedisDriverInfoProvider{client: client}
+}
+
+// GetDriverInfo looks up all drivers in a single pipeline.
+func (p *RedisDriverInfoProvider) GetDriverInfo(ctx context.Context, city string, driverIDs []string) (map[string]DriverInfo, error) {
+	if len(driverIDs) == 0 {
+		return map[string]DriverInfo{}, nil
+	}
+
+	members := make([]interface{}, len(driverIDs))
+	for i, id := range driverIDs {
+		members[i] = id
+	}
+
+	pipe := p.client.client.Pipeline()
+	online := pipe.SMIsMember(ctx, p.client.Key(RedisKeyPatterns.OnlineDrivers, city), members...)
+	statuses := make([]*redis.MapStringStringCmd, len(driverIDs))
+	for i, id := range driverIDs {
+		statuses[i] = pipe.HGetAll(ctx, p.client.Key(RedisKeyPatterns.DriverStatus, id))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to load driver info: %w", err)
+	}
+
+	flags := online.Val()
+	result := make(map[string]DriverInfo, len(driverIDs))
+	for i, id := range driverIDs {
+		fields := statuses[i].Val()
+		if len(fields) == 0 {
+			continue
+		}
+		status := parseDriverStatus(fields)
+		result[id] = DriverInfo{
+			Online:       i < len(flags) && flags[i],
+			Status:       status.Status,
+			VehicleClass: vehicle.Class(status.VehicleClass),
+			Rating:       status.Rating,
+		}
+	}
+	return result, nil
+}
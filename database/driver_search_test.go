@@ -0,0 +1,186 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mycobrun/cobrun-shared/database/cosmosdb"
+	"github.com/mycobrun/cobrun-shared/geo"
+	"github.com/mycobrun/cobrun-shared/vehicle"
+)
+
+// fakeCandidateSource returns the drivers within the step radius.
+type fakeCandidateSource struct {
+	drivers []DriverCandidate
+}
+
+func (f *fakeCandidateSource) FindDrivers(ctx context.Context, city string, pickup geo.Point, step SearchStep) ([]DriverCandidate, error) {
+	var found []DriverCandidate
+	for _, d := range f.drivers {
+		if d.DistanceKm <= step.RadiusKm {
+			found = append(found, d)
+		}
+	}
+	return found, nil
+}
+
+type fakeDriverInfo map[string]DriverInfo
+
+func (f fakeDriverInfo) GetDriverInfo(ctx context.Context, city string, driverIDs []string) (map[string]DriverInfo, error) {
+	result := make(map[string]DriverInfo)
+	for _, id := range driverIDs {
+		if info, ok := f[id]; ok {
+			result[id] = info
+		}
+	}
+	return result, nil
+}
+
+func newSearchRequest(rideType vehicle.Class) *cosmosdb.RideRequest {
+	return &cosmosdb.RideRequest{
+		ID:             "req-1",
+		PickupLocation: cosmosdb.NewGeoPoint(37.7749, -122.4194),
+		RideType:       string(rideType),
+	}
+}
+
+func TestDriverSearch_FiltersAndRanks(t *testing.T) {
+	source := &fakeCandidateSource{drivers: []DriverCandidate{
+		{DriverID: "near-premium", DistanceKm: 0.2},
+		{DriverID: "near-standard", DistanceKm: 0.3},
+		{DriverID: "far-standard", DistanceKm: 0.9},
+		{DriverID: "offline", DistanceKm: 0.1},
+		{DriverID: "on-trip", DistanceKm: 0.1},
+		{DriverID: "xl", DistanceKm: 0.5},
+		{DriverID: "declined", DistanceKm: 0.1},
+	}}
+	info := fakeDriverInfo{
+		"near-premium":  {Online: true, Status: "available", VehicleClass: vehicle.ClassPremium, Rating: 4.9},
+		"near-standard": {Online: true, Status: "available", VehicleClass: vehicle.ClassStandard, Rating: 4.9},
+		"far-standard":  {Online: true, Status: "available", VehicleClass: vehicle.ClassStandard, Rating: 5},
+		"offline":       {Online: false, Status: "available", VehicleClass: vehicle.ClassStandard},
+		"on-trip":       {Online: true, Status: "on_trip", VehicleClass: vehicle.ClassStandard},
+		"xl":            {Online: true, Status: "available", VehicleClass: vehicle.ClassXL, Rating: 4.8},
+		"declined":      {Online: true, Status: "available", VehicleClass: vehicle.ClassStandard},
+	}
+
+	search := NewDriverSearch(source, info, DefaultDriverSearchConfig())
+	req := newSearchRequest(vehicle.ClassComfort)
+
+	result, err := search.Search(context.Background(), req, SearchOptions{
+		City:             "sanfrancisco",
+		ExcludeDriverIDs: []string{"declined"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Only premium can fulfill comfort
+	if len(result.Candidates) != 1 || result.Candidates[0].DriverID != "near-premium" {
+		t.Fatalf("expected only near-premium, got %+v", result.Candidates)
+	}
+
+	req = newSearchRequest(vehicle.ClassStandard)
+	result, err = search.Search(context.Background(), req, SearchOptions{
+		City:             "sanfrancisco",
+		ExcludeDriverIDs: []string{"declined"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var ids []string
+	for _, c := range result.Candidates {
+		ids = append(ids, c.DriverID)
+	}
+	// Exact class wins over the slightly closer upgrade; the far driver is last
+	want := []string{"near-standard", "near-premium", "xl", "far-standard"}
+	if len(ids) != len(want) {
+		t.Fatalf("expected %v, got %v", want, ids)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, ids)
+		}
+	}
+}
+
+func TestDriverSearch_RecordsAttempts(t *testing.T) {
+	source := &fakeCandidateSource{}
+	search := NewDriverSearch(source, fakeDriverInfo{}, DefaultDriverSearchConfig())
+
+	var recorded []float64
+	search.WithRequestRecorder(func(ctx context.Context, req *cosmosdb.RideRequest) error {
+		recorded = append(recorded, req.CurrentRadius)
+		return nil
+	})
+
+	req := newSearchRequest(vehicle.ClassStandard)
+	for i := 0; i < 7; i++ {
+		if _, err := search.Search(context.Background(), req, SearchOptions{City: "sanfrancisco"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if req.SearchAttempts != 7 {
+		t.Errorf("expected 7 attempts, got %d", req.SearchAttempts)
+	}
+	// The radius stops widening at the last step
+	want := []float64{1, 2, 3.5, 5, 8, 8, 8}
+	for i, r := range want {
+		if recorded[i] != r {
+			t.Fatalf("expected radii %v, got %v", want, recorded)
+		}
+	}
+}
+
+func TestDriverSearch_SearchUntil(t *testing.T) {
+	source := &fakeCandidateSource{drivers: []DriverCandidate{
+		{DriverID: "driver-1", DistanceKm: 1.5},
+		{DriverID: "driver-2", DistanceKm: 3},
+	}}
+	info := fakeDriverInfo{
+		"driver-1": {Online: true, Status: "available", VehicleClass: vehicle.ClassStandard},
+		"driver-2": {Online: true, Status: "available", VehicleClass: vehicle.ClassStandard},
+	}
+
+	config := DefaultDriverSearchConfig()
+	for i := range config.Steps {
+		config.Steps[i].After = time.Millisecond
+	}
+	search := NewDriverSearch(source, info, config)
+
+	req := newSearchRequest(vehicle.ClassStandard)
+	opts := &SearchOptions{City: "sanfrancisco"}
+
+	// driver-1 declines, driver-2 accepts
+	result, err := search.SearchUntil(context.Background(), req, opts, func(ctx context.Context, r *SearchResult) (bool, error) {
+		for _, c := range r.Candidates {
+			if c.DriverID == "driver-2" {
+				return true, nil
+			}
+			opts.ExcludeDriverIDs = append(opts.ExcludeDriverIDs, c.DriverID)
+		}
+		return false, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Attempt != 3 || req.CurrentRadius != 3.5 {
+		t.Errorf("expected match on attempt 3 at 3.5km, got attempt %d at %.1fkm", result.Attempt, req.CurrentRadius)
+	}
+
+	// Nobody accepts
+	req = newSearchRequest(vehicle.ClassStandard)
+	_, err = search.SearchUntil(context.Background(), req, &SearchOptions{City: "sanfrancisco"}, func(ctx context.Context, r *SearchResult) (bool, error) {
+		return false, nil
+	})
+	if !errors.Is(err, ErrSearchExhausted) {
+		t.Errorf("expected ErrSearchExhausted, got %v", err)
+	}
+	if req.SearchAttempts != len(config.Steps) {
+		t.Errorf("expected %d attempts, got %d", len(config.Steps), req.SearchAttempts)
+	}
+}
@@ -0,0 +1,241 @@
+// Package database provides database client utilities.
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// Lease is a held, exclusive lease on a name.
+type Lease interface {
+	// Extend keeps the lease for ttl more. Returns ErrLockLost if it is no
+	// longer held.
+	Extend(ctx context.Context, ttl time.Duration) error
+	// Release gives the lease up so that another replica can take it.
+	Release(ctx context.Context) error
+}
+
+// LeaseBackend grants leases for leader election.
+type LeaseBackend interface {
+	// TryAcquire takes the lease on name for ttl, returning
+	// ErrLockNotAcquired if another replica holds it.
+	TryAcquire(ctx context.Context, name string, ttl time.Duration) (Lease, error)
+}
+
+// RedisLeaseBackend grants leases as Redis locks on "leader:<name>".
+type RedisLeaseBackend struct {
+	client *RedisClient
+}
+
+// NewRedisLeaseBackend creates a lease backend on Redis.
+func NewRedisLeaseBackend(client *RedisClient) *RedisLeaseBackend {
+	return &RedisLeaseBackend{client: client}
+}
+
+// TryAcquire takes the lease on name for ttl.
+func (b *RedisLeaseBackend) TryAcquire(ctx context.Context, name string, ttl time.Duration) (Lease, error) {
+	lock, err := b.client.AcquireLock(ctx, "leader:"+name, ttl)
+	if err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+var _ Lease = (*Lock)(nil)
+
+// LeaderElectionConfig holds leader election configuration.
+type LeaderElectionConfig struct {
+	// Name identifies the singleton, e.g. "stale_driver_sweeper".
+	Name string
+	// LeaseTTL is how long a lease outlives a leader that stopped renewing
+	// it, e.g. after a crash.
+	LeaseTTL time.Duration
+	// RenewInterval is the time between renewals. A leader that could not
+	// renew for LeaseTTL minus RenewInterval steps down, before the lease
+	// can expire and be taken by another replica.
+	RenewInterval time.Duration
+	// RetryInterval is the time between attempts to take the lease.
+	RetryInterval time.Duration
+	// ReleaseTimeout bounds releasing the lease when stepping down.
+	ReleaseTimeout time.Duration
+}
+
+// DefaultLeaderElectionConfig returns sensible defaults.
+func DefaultLeaderElectionConfig(name string) LeaderElectionConfig {
+	return LeaderElectionConfig{
+		Name:           name,
+		LeaseTTL:       15 * time.Second,
+		RenewInterval:  5 * time.Second,
+		RetryInterval:  2 * time.Second,
+		ReleaseTimeout: 5 * time.Second,
+	}
+}
+
+// LeaderElection runs a singleton background worker, such as a sweeper or
+// an outbox relay, on exactly one replica at a time.
+//
+// Every replica runs the election; the one holding the lease is the leader
+// and runs the OnElected callback with a context that is cancelled when it
+// stops being leader. On shutdown the leader waits for the callback to
+// return and releases the lease, so another replica takes over without
+// waiting for it to expire.
+//
+//	election, err := database.NewLeaderElection(database.NewRedisLeaseBackend(redis),
+//		database.DefaultLeaderElectionConfig("stale_driver_sweeper"))
+//	if err != nil {
+//		return err
+//	}
+//	election.OnElected(func(ctx context.Context) { _ = sweeper.Run(ctx) })
+//	go election.Run(ctx)
+type LeaderElection struct {
+	backend LeaseBackend
+	config  LeaderElectionConfig
+
+	onElected func(ctx context.Context)
+	onRevoked func()
+	leader    atomic.Bool
+}
+
+// NewLeaderElection creates a leader election on backend. Zero durations in
+// config take their default values; RenewInterval must be shorter than
+// LeaseTTL so that the lease is renewed before it expires.
+func NewLeaderElection(backend LeaseBackend, config LeaderElectionConfig) (*LeaderElection, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("leader election name is required")
+	}
+	defaults := DefaultLeaderElectionConfig(config.Name)
+	if config.LeaseTTL <= 0 {
+		config.LeaseTTL = defaults.LeaseTTL
+	}
+	if config.RenewInterval <= 0 {
+		config.RenewInterval = defaults.RenewInterval
+	}
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = defaults.RetryInterval
+	}
+	if config.ReleaseTimeout <= 0 {
+		config.ReleaseTimeout = defaults.ReleaseTimeout
+	}
+	if config.RenewInterval >= config.LeaseTTL {
+		return nil, fmt.Errorf("leader election %s: renew interval %v must be shorter than lease TTL %v",
+			config.Name, config.RenewInterval, config.LeaseTTL)
+	}
+	return &LeaderElection{backend: backend, config: config}, nil
+}
+
+// OnElected sets the function run while this replica is leader. It must
+// return once ctx is cancelled. If it returns earlier, the lease is released
+// and the replica campaigns again.
+func (e *LeaderElection) OnElected(fn func(ctx context.Context)) *LeaderElection {
+	e.onElected = fn
+	return e
+}
+
+// OnRevoked sets a function called after this replica stops being leader and
+// the OnElected function has returned.
+func (e *LeaderElection) OnRevoked(fn func()) *LeaderElection {
+	e.onRevoked = fn
+	return e
+}
+
+// IsLeader reports whether this replica is currently leader.
+func (e *LeaderElection) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Run campaigns for leadership until ctx is done, and hands leadership off
+// before returning ctx.Err().
+func (e *LeaderElection) Run(ctx context.Context) error {
+	for {
+		lease, err := e.backend.TryAcquire(ctx, e.config.Name, e.config.LeaseTTL)
+		switch {
+		case err == nil:
+			e.lead(ctx, lease)
+		case !errors.Is(err, ErrLockNotAcquired) && ctx.Err() == nil:
+			log.Printf("leader election %s: failed to acquire lease: %v", e.config.Name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(e.config.RetryInterval):
+		}
+	}
+}
+
+// lead runs the OnElected function while renewing the lease, until the
+// lease is lost, the function returns or ctx is done.
+func (e *LeaderElection) lead(ctx context.Context, lease Lease) {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	e.leader.Store(true)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if e.onElected != nil {
+			e.onElected(leaderCtx)
+		} else {
+			<-leaderCtx.Done()
+		}
+	}()
+
+	lost := e.renew(ctx, lease, done)
+
+	cancel()
+	<-done
+	e.leader.Store(false)
+
+	if !lost {
+		releaseCtx, cancelRelease := context.WithTimeout(context.WithoutCancel(ctx), e.config.ReleaseTimeout)
+		if err := lease.Release(releaseCtx); err != nil && !errors.Is(err, ErrLockLost) {
+			log.Printf("leader election %s: failed to release lease: %v", e.config.Name, err)
+		}
+		cancelRelease()
+	}
+	if e.onRevoked != nil {
+		e.onRevoked()
+	}
+}
+
+// renew extends the lease until it is lost, done is closed or ctx is done,
+// and reports whether it was lost.
+func (e *LeaderElection) renew(ctx context.Context, lease Lease, done <-chan struct{}) bool {
+	ticker := time.NewTicker(e.config.RenewInterval)
+	defer ticker.Stop()
+	renewedAt := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-done:
+			return false
+		case <-ticker.C:
+		}
+
+		start := time.Now()
+		renewCtx, cancel := context.WithTimeout(ctx, e.config.RenewInterval)
+		err := lease.Extend(renewCtx, e.config.LeaseTTL)
+		cancel()
+		switch {
+		case err == nil:
+			renewedAt = start
+		case errors.Is(err, ErrLockLost):
+			log.Printf("leader election %s: lease lost", e.config.Name)
+			return true
+		case ctx.Err() != nil:
+			return false
+		case time.Since(renewedAt) >= e.config.LeaseTTL-e.config.RenewInterval:
+			// Step down before the lease can expire under us
+			log.Printf("leader election %s: stepping down, failed to renew lease: %v", e.config.Name, err)
+			return true
+		default:
+			log.Printf("leader election %s: failed to renew lease, retrying: %v", e.config.Name, err)
+		}
+	}
+}
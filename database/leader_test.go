@@ -0,0 +1,214 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memoryLeaseBackend grants leases held in memory, without expiry.
+type memoryLeaseBackend struct {
+	mu      sync.Mutex
+	holders map[string]*memoryLease
+}
+
+type memoryLease struct {
+	backend  *memoryLeaseBackend
+	name     string
+	released atomic.Bool
+}
+
+func newMemoryLeaseBackend() *memoryLeaseBackend {
+	return &memoryLeaseBackend{holders: map[string]*memoryLease{}}
+}
+
+func (b *memoryLeaseBackend) TryAcquire(_ context.Context, name string, _ time.Duration) (Lease, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.holders[name] != nil {
+		return nil, ErrLockNotAcquired
+	}
+	lease := &memoryLease{backend: b, name: name}
+	b.holders[name] = lease
+	return lease, nil
+}
+
+// steal hands the lease on name to someone else, as if it had expired.
+func (b *memoryLeaseBackend) steal(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.holders[name] = &memoryLease{backend: b, name: name}
+}
+
+func (b *memoryLeaseBackend) free(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.holders, name)
+}
+
+func (l *memoryLease) Extend(context.Context, time.Duration) error {
+	l.backend.mu.Lock()
+	defer l.backend.mu.Unlock()
+	if l.backend.holders[l.name] != l {
+		return ErrLockLost
+	}
+	return nil
+}
+
+func (l *memoryLease) Release(context.Context) error {
+	l.backend.mu.Lock()
+	defer l.backend.mu.Unlock()
+	if l.backend.holders[l.name] != l {
+		return ErrLockLost
+	}
+	delete(l.backend.holders, l.name)
+	l.released.Store(true)
+	return nil
+}
+
+func testLeaderElectionConfig() LeaderElectionConfig {
+	config := DefaultLeaderElectionConfig("sweeper")
+	config.LeaseTTL = 300 * time.Millisecond
+	config.RenewInterval = 10 * time.Millisecond
+	config.RetryInterval = 10 * time.Millisecond
+	return config
+}
+
+func newTestLeaderElection(t *testing.T, backend LeaseBackend) *LeaderElection {
+	t.Helper()
+	election, err := NewLeaderElection(backend, testLeaderElectionConfig())
+	if err != nil {
+		t.Fatalf("NewLeaderElection failed: %v", err)
+	}
+	return election
+}
+
+func TestNewLeaderElection(t *testing.T) {
+	backend := newMemoryLeaseBackend()
+	election, err := NewLeaderElection(backend, LeaderElectionConfig{Name: "sweeper", LeaseTTL: time.Minute})
+	if err != nil {
+		t.Fatalf("NewLeaderElection failed: %v", err)
+	}
+	defaults := DefaultLeaderElectionConfig("sweeper")
+	if election.config.LeaseTTL != time.Minute || election.config.RenewInterval != defaults.RenewInterval ||
+		election.config.RetryInterval != defaults.RetryInterval || election.config.ReleaseTimeout != defaults.ReleaseTimeout {
+		t.Errorf("expected zero fields defaulted, got %+v", election.config)
+	}
+
+	invalid := []LeaderElectionConfig{
+		{LeaseTTL: time.Minute},
+		{Name: "sweeper", LeaseTTL: time.Second, RenewInterval: time.Second},
+		{Name: "sweeper", LeaseTTL: time.Second},
+	}
+	for _, config := range invalid {
+		if _, err := NewLeaderElection(backend, config); err == nil {
+			t.Errorf("expected an error for %+v", config)
+		}
+	}
+}
+
+func waitUntil(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting until %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestLeaderElection_Handoff(t *testing.T) {
+	backend := newMemoryLeaseBackend()
+	var running atomic.Int32
+
+	newReplica := func() (*LeaderElection, *atomic.Int32) {
+		revoked := &atomic.Int32{}
+		election := newTestLeaderElection(t, backend).
+			OnElected(func(ctx context.Context) {
+				if running.Add(1) > 1 {
+					t.Error("two replicas are leader at once")
+				}
+				<-ctx.Done()
+				running.Add(-1)
+			}).
+			OnRevoked(func() { revoked.Add(1) })
+		return election, revoked
+	}
+
+	first, firstRevoked := newReplica()
+	firstCtx, stopFirst := context.WithCancel(context.Background())
+	firstDone := make(chan error)
+	go func() { firstDone <- first.Run(firstCtx) }()
+	waitUntil(t, "the first replica leads", first.IsLeader)
+
+	second, _ := newReplica()
+	secondCtx, stopSecond := context.WithCancel(context.Background())
+	defer stopSecond()
+	go second.Run(secondCtx)
+	time.Sleep(50 * time.Millisecond)
+	if second.IsLeader() {
+		t.Fatal("the second replica should follow while the first leads")
+	}
+
+	// Shutting the leader down hands leadership over
+	stopFirst()
+	<-firstDone
+	if first.IsLeader() || firstRevoked.Load() != 1 {
+		t.Errorf("expected the first replica to step down once, leader %v, revoked %d", first.IsLeader(), firstRevoked.Load())
+	}
+	waitUntil(t, "the second replica leads", second.IsLeader)
+}
+
+func TestLeaderElection_LeaseLost(t *testing.T) {
+	backend := newMemoryLeaseBackend()
+	var elected, revoked atomic.Int32
+	election := newTestLeaderElection(t, backend).
+		OnElected(func(ctx context.Context) {
+			elected.Add(1)
+			<-ctx.Done()
+		}).
+		OnRevoked(func() { revoked.Add(1) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go election.Run(ctx)
+	waitUntil(t, "elected", election.IsLeader)
+
+	backend.steal("sweeper")
+	waitUntil(t, "revoked", func() bool { return revoked.Load() == 1 })
+	if election.IsLeader() {
+		t.Error("expected the replica to step down")
+	}
+
+	// It campaigns again once the lease is free
+	backend.free("sweeper")
+	waitUntil(t, "re-elected", func() bool { return elected.Load() == 2 })
+}
+
+func TestLeaderElection_CallbackReturns(t *testing.T) {
+	backend := newMemoryLeaseBackend()
+	leases := make(chan *memoryLease, 1)
+	var runs atomic.Int32
+	election := newTestLeaderElection(t, backend).
+		OnElected(func(ctx context.Context) {
+			if runs.Add(1) == 1 {
+				backend.mu.Lock()
+				leases <- backend.holders["sweeper"]
+				backend.mu.Unlock()
+				return // the work failed
+			}
+			<-ctx.Done()
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go election.Run(ctx)
+
+	waitUntil(t, "elected again", func() bool { return runs.Load() == 2 })
+	if lease := <-leases; !lease.released.Load() {
+		t.Error("expected the lease to be released when the callback returned")
+	}
+}
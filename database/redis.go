@@ -11,6 +11,20 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// RedisMode selects how RedisClient connects.
+type RedisMode string
+
+const (
+	// RedisModeStandalone connects to a single node.
+	RedisModeStandalone RedisMode = "standalone"
+	// RedisModeCluster connects to a Redis Cluster, such as a clustered
+	// Azure Cache for Redis.
+	RedisModeCluster RedisMode = "cluster"
+	// RedisModeSentinel connects to the primary of a Sentinel-managed
+	// group and follows it across failovers.
+	RedisModeSentinel RedisMode = "sentinel"
+)
+
 // RedisConfig holds Redis configuration.
 type RedisConfig struct {
 	Host        string
@@ -20,6 +34,23 @@ type RedisConfig struct {
 	TLSEnabled  bool
 	PoolSize    int
 	MinIdleConn int
+
+	// Mode defaults to RedisModeStandalone.
+	Mode RedisMode
+	// Addrs are the "host:port" seed nodes in cluster mode, or the
+	// sentinels in sentinel mode. Host and Port are used when empty.
+	Addrs []string
+	// MasterName is the name of the Sentinel-managed group.
+	MasterName       string
+	SentinelPassword string
+
+	// ReadOnly sends read-only commands to replicas. In sentinel mode it
+	// behaves like RouteRandomly.
+	ReadOnly bool
+	// RouteByLatency sends read-only commands to the closest node.
+	RouteByLatency bool
+	// RouteRandomly sends read-only commands to a random node.
+	RouteRandomly bool
 }
 
 // DefaultRedisConfig returns sensible defaults.
@@ -30,37 +61,26 @@ func DefaultRedisConfig() RedisConfig {
 		TLSEnabled:  true,
 		PoolSize:    100,
 		MinIdleConn: 10,
+		Mode:        RedisModeStandalone,
 	}
 }
 
 // RedisClient wraps the Redis client.
 type RedisClient struct {
-	client *redis.Client
+	client redis.UniversalClient
 	config RedisConfig
 }
 
 // NewRedisClient creates a new Redis client.
 func NewRedisClient(ctx context.Context, config RedisConfig) (*RedisClient, error) {
-	addr := fmt.Sprintf("%s:%d", config.Host, config.Port)
-
-	opts := &redis.Options{
-		Addr:         addr,
-		Password:     config.Password,
-		DB:           config.DB,
-		PoolSize:     config.PoolSize,
-		MinIdleConns: config.MinIdleConn,
-	}
-
-	if config.TLSEnabled {
-		opts.TLSConfig = &tls.Config{
-			MinVersion: tls.VersionTLS12,
-		}
+	client, err := newUniversalClient(config)
+	if err != nil {
+		return nil, err
 	}
 
-	client := redis.NewClient(opts)
-
 	// Verify connection
 	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
 		return nil, fmt.Errorf("failed to connect to redis: %w", err)
 	}
 
@@ -70,11 +90,92 @@ func NewRedisClient(ctx context.Context, config RedisConfig) (*RedisClient, erro
 	}, nil
 }
 
-// Client returns the underlying redis client.
-func (r *RedisClient) Client() *redis.Client {
+// newUniversalClient creates the client for config.Mode. Unlike
+// redis.NewUniversalClient it does not guess the mode from the number of
+// addresses, since a clustered Azure Cache for Redis has a single endpoint.
+func newUniversalClient(config RedisConfig) (redis.UniversalClient, error) {
+	opts, err := universalOptions(config)
+	if err != nil {
+		return nil, err
+	}
+
+	switch config.Mode {
+	case RedisModeCluster:
+		return redis.NewClusterClient(opts.Cluster()), nil
+	case RedisModeSentinel:
+		failover := opts.Failover()
+		if config.ReadOnly || config.RouteByLatency || config.RouteRandomly {
+			failover.RouteByLatency = config.RouteByLatency
+			failover.RouteRandomly = config.RouteRandomly || !config.RouteByLatency
+			return redis.NewFailoverClusterClient(failover), nil
+		}
+		return redis.NewFailoverClient(failover), nil
+	default:
+		return redis.NewClient(opts.Simple()), nil
+	}
+}
+
+// universalOptions validates config and converts it to client options.
+func universalOptions(config RedisConfig) (*redis.UniversalOptions, error) {
+	addrs := config.Addrs
+	if len(addrs) == 0 {
+		addrs = []string{fmt.Sprintf("%s:%d", config.Host, config.Port)}
+	}
+
+	opts := &redis.UniversalOptions{
+		Addrs:          addrs,
+		Password:       config.Password,
+		DB:             config.DB,
+		PoolSize:       config.PoolSize,
+		MinIdleConns:   config.MinIdleConn,
+		ReadOnly:       config.ReadOnly,
+		RouteByLatency: config.RouteByLatency,
+		RouteRandomly:  config.RouteRandomly,
+	}
+
+	switch config.Mode {
+	case "", RedisModeStandalone:
+		if len(addrs) > 1 {
+			return nil, fmt.Errorf("redis standalone mode takes one address, got %d", len(addrs))
+		}
+	case RedisModeCluster:
+		if config.DB != 0 {
+			return nil, fmt.Errorf("redis cluster mode only supports DB 0")
+		}
+	case RedisModeSentinel:
+		if config.MasterName == "" {
+			return nil, fmt.Errorf("redis sentinel mode requires a master name")
+		}
+		opts.MasterName = config.MasterName
+		opts.SentinelPassword = config.SentinelPassword
+	default:
+		return nil, fmt.Errorf("unknown redis mode %q", config.Mode)
+	}
+
+	if config.TLSEnabled {
+		opts.TLSConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	return opts, nil
+}
+
+// Client returns the underlying redis client: a *redis.Client in standalone
+// and sentinel mode, or a *redis.ClusterClient in cluster mode and for
+// sentinel replica reads.
+func (r *RedisClient) Client() redis.UniversalClient {
 	return r.client
 }
 
+// Mode returns the mode the client connects in.
+func (r *RedisClient) Mode() RedisMode {
+	if r.config.Mode == "" {
+		return RedisModeStandalone
+	}
+	return r.config.Mode
+}
+
 // Ping checks the connection.
 func (r *RedisClient) Ping(ctx context.Context) error {
 	return r.client.Ping(ctx).Err()
@@ -346,61 +447,6 @@ func (r *RedisClient) SRem(ctx context.Context, key string, members ...interface
 // Common errors
 var ErrKeyNotFound = fmt.Errorf("key not found")
 
-// Distributed Lock
-
-// Lock represents a distributed lock.
-type Lock struct {
-	client *RedisClient
-	key    string
-	value  string
-	ttl    time.Duration
-}
-
-// AcquireLock attempts to acquire a distributed lock.
-func (r *RedisClient) AcquireLock(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
-	value := fmt.Sprintf("%d", time.Now().UnixNano())
-	acquired, err := r.SetNX(ctx, key, value, ttl)
-	if err != nil {
-		return nil, err
-	}
-	if !acquired {
-		return nil, ErrLockNotAcquired
-	}
-	return &Lock{
-		client: r,
-		key:    key,
-		value:  value,
-		ttl:    ttl,
-	}, nil
-}
-
-// Release releases the lock.
-func (l *Lock) Release(ctx context.Context) error {
-	// Only release if we still hold the lock
-	script := `
-		if redis.call("get", KEYS[1]) == ARGV[1] then
-			return redis.call("del", KEYS[1])
-		else
-			return 0
-		end
-	`
-	return l.client.client.Eval(ctx, script, []string{l.key}, l.value).Err()
-}
-
-// Extend extends the lock TTL.
-func (l *Lock) Extend(ctx context.Context, ttl time.Duration) error {
-	script := `
-		if redis.call("get", KEYS[1]) == ARGV[1] then
-			return redis.call("pexpire", KEYS[1], ARGV[2])
-		else
-			return 0
-		end
-	`
-	return l.client.client.Eval(ctx, script, []string{l.key}, l.value, int64(ttl.Milliseconds())).Err()
-}
-
-var ErrLockNotAcquired = fmt.Errorf("lock not acquired")
-
 // Retry-enabled operations for production resilience
 
 // GetWithRetry retrieves a string value with retry logic.
@@ -0,0 +1,256 @@
+// Package database provides database client utilities.
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	apperrors "github.com/mycobrun/cobrun-shared/errors"
+)
+
+// CacheStore holds the entries of a Cache. RedisClient implements it.
+type CacheStore interface {
+	// Get returns ErrKeyNotFound for a missing key.
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key, value string, expiration time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// CacheConfig configures a Cache.
+type CacheConfig struct {
+	// TTL is how long a loaded value is served without reloading.
+	TTL time.Duration
+	// StaleTTL is how long past TTL a value is kept, and served if reloading
+	// it fails.
+	StaleTTL time.Duration
+	// NegativeTTL is how long a not found result is cached; 0 disables it.
+	NegativeTTL time.Duration
+	// EarlyRefresh scales the probability of refreshing a value in the
+	// background before it expires, weighted by how long it takes to load,
+	// so that popular keys rarely expire at all. 0 disables it.
+	EarlyRefresh float64
+	// LoadTimeout bounds a load, which is shared by all concurrent callers
+	// and so does not stop when one of them gives up.
+	LoadTimeout time.Duration
+}
+
+// DefaultCacheConfig returns sensible defaults for values fresh for ttl,
+// typically one of RedisTTLs.
+func DefaultCacheConfig(ttl time.Duration) CacheConfig {
+	return CacheConfig{
+		TTL:          ttl,
+		StaleTTL:     ttl,
+		NegativeTTL:  min(ttl/10, time.Minute),
+		EarlyRefresh: 1.0,
+		LoadTimeout:  10 * time.Second,
+	}
+}
+
+// Cache is a cache-aside helper over a CacheStore for values of type T,
+// stored as JSON.
+//
+// Concurrent misses for a key in the process share one load; values are
+// refreshed in the background shortly before they expire; not found results
+// are cached briefly; and if loading fails an expired value is served for
+// up to StaleTTL instead of the error.
+//
+//	users := database.NewCache[User](redis, database.DefaultCacheConfig(database.RedisTTLs.UserCache))
+//	user, err := users.Get(ctx, fmt.Sprintf(database.RedisKeyPatterns.UserCache, id), func(ctx context.Context) (User, error) {
+//		return repo.FindByID(ctx, id)
+//	})
+type Cache[T any] struct {
+	store  CacheStore
+	config CacheConfig
+
+	mu      sync.Mutex
+	flights map[string]*cacheFlight[T]
+
+	now        func() time.Time
+	random     func() float64
+	refreshing sync.WaitGroup
+}
+
+// NewCache creates a cache over store.
+func NewCache[T any](store CacheStore, config CacheConfig) *Cache[T] {
+	return &Cache[T]{
+		store:   store,
+		config:  config,
+		flights: make(map[string]*cacheFlight[T]),
+		now:     time.Now,
+		random:  rand.Float64,
+	}
+}
+
+// cacheEntry is the stored form of a cached value.
+type cacheEntry[T any] struct {
+	Value     T     `json:"v"`
+	NotFound  bool  `json:"nf,omitempty"`
+	ExpiresAt int64 `json:"exp"` // unix milliseconds
+	LoadTime  int64 `json:"lt"`  // milliseconds
+}
+
+// cacheFlight is a load shared by concurrent callers.
+type cacheFlight[T any] struct {
+	done  chan struct{}
+	entry *cacheEntry[T]
+	err   error
+}
+
+// Get returns the value for key, calling load on a miss. A load error that
+// is ErrNotFound or an apperrors not found error is cached for NegativeTTL.
+func (c *Cache[T]) Get(ctx context.Context, key string, load func(context.Context) (T, error)) (T, error) {
+	var zero T
+
+	// A store error is treated as a miss: the cache must not take the
+	// service down with it.
+	entry, _ := c.read(ctx, key)
+	if entry != nil {
+		now := c.now()
+		if now.UnixMilli() < entry.ExpiresAt {
+			if c.refreshEarly(entry, now) {
+				c.refresh(ctx, key, load)
+			}
+			return entry.result()
+		}
+	}
+
+	loaded, err := c.load(ctx, key, load)
+	if err != nil {
+		if entry != nil && !entry.NotFound && !isNotFound(err) {
+			// Serve the stale value while the backend is failing
+			return entry.Value, nil
+		}
+		return zero, err
+	}
+	return loaded.result()
+}
+
+// Set stores value under key, e.g. after writing it to the database.
+func (c *Cache[T]) Set(ctx context.Context, key string, value T) error {
+	return c.write(ctx, key, &cacheEntry[T]{Value: value}, c.config.TTL)
+}
+
+// Invalidate removes keys, so that the next Get loads them again.
+func (c *Cache[T]) Invalidate(ctx context.Context, keys ...string) error {
+	return c.store.Delete(ctx, keys...)
+}
+
+func (e *cacheEntry[T]) result() (T, error) {
+	if e.NotFound {
+		var zero T
+		return zero, apperrors.Wrap(ErrNotFound, apperrors.CodeNotFound, "not found (cached)")
+	}
+	return e.Value, nil
+}
+
+func (c *Cache[T]) read(ctx context.Context, key string) (*cacheEntry[T], error) {
+	data, err := c.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	var entry cacheEntry[T]
+	if err := json.Unmarshal([]byte(data), &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (c *Cache[T]) write(ctx context.Context, key string, entry *cacheEntry[T], ttl time.Duration) error {
+	entry.ExpiresAt = c.now().Add(ttl).UnixMilli()
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	keep := ttl
+	if !entry.NotFound {
+		keep += c.config.StaleTTL
+	}
+	return c.store.Set(ctx, key, string(data), keep)
+}
+
+// refreshEarly decides whether to refresh entry before it expires, with a
+// probability that grows as expiry nears and with the time it takes to load
+// ("optimal probabilistic cache stampede prevention", XFetch).
+func (c *Cache[T]) refreshEarly(entry *cacheEntry[T], now time.Time) bool {
+	if c.config.EarlyRefresh <= 0 || entry.NotFound {
+		return false
+	}
+	gap := -float64(entry.LoadTime) * c.config.EarlyRefresh * math.Log(c.random())
+	return float64(now.UnixMilli())+gap >= float64(entry.ExpiresAt)
+}
+
+// refresh reloads key in the background, unless a load is already running.
+func (c *Cache[T]) refresh(ctx context.Context, key string, load func(context.Context) (T, error)) {
+	c.mu.Lock()
+	_, running := c.flights[key]
+	c.mu.Unlock()
+	if running {
+		return
+	}
+
+	c.refreshing.Add(1)
+	go func() {
+		defer c.refreshing.Done()
+		_, _ = c.load(context.WithoutCancel(ctx), key, load)
+	}()
+}
+
+// load runs load once for all concurrent callers and stores its result.
+func (c *Cache[T]) load(ctx context.Context, key string, load func(context.Context) (T, error)) (*cacheEntry[T], error) {
+	c.mu.Lock()
+	flight, ok := c.flights[key]
+	if !ok {
+		flight = &cacheFlight[T]{done: make(chan struct{})}
+		c.flights[key] = flight
+		go c.run(context.WithoutCancel(ctx), key, flight, load)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-flight.done:
+		return flight.entry, flight.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *Cache[T]) run(ctx context.Context, key string, flight *cacheFlight[T], load func(context.Context) (T, error)) {
+	defer func() {
+		c.mu.Lock()
+		delete(c.flights, key)
+		c.mu.Unlock()
+		close(flight.done)
+	}()
+
+	if c.config.LoadTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.LoadTimeout)
+		defer cancel()
+	}
+
+	start := c.now()
+	value, err := load(ctx)
+	flight.err = err
+	entry := &cacheEntry[T]{Value: value, LoadTime: c.now().Sub(start).Milliseconds()}
+	ttl := c.config.TTL
+	if err != nil {
+		if c.config.NegativeTTL <= 0 || !isNotFound(err) {
+			return
+		}
+		entry = &cacheEntry[T]{NotFound: true}
+		ttl = c.config.NegativeTTL
+	}
+	flight.entry = entry
+
+	// Failing to store only costs a reload.
+	_ = c.write(ctx, key, entry, ttl)
+}
+
+func isNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound) || errors.Is(err, ErrKeyNotFound) || apperrors.IsNotFound(err)
+}
@@ -0,0 +1,245 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	apperrors "github.com/mycobrun/cobrun-shared/errors"
+)
+
+// memoryCacheStore is a CacheStore kept in memory, expiring keys by clock.
+type memoryCacheStore struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	values  map[string]string
+	expires map[string]time.Time
+	failing error
+}
+
+func newMemoryCacheStore(now func() time.Time) *memoryCacheStore {
+	return &memoryCacheStore{now: now, values: map[string]string{}, expires: map[string]time.Time{}}
+}
+
+func (s *memoryCacheStore) Get(_ context.Context, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failing != nil {
+		return "", s.failing
+	}
+	value, ok := s.values[key]
+	if !ok || !s.now().Before(s.expires[key]) {
+		return "", ErrKeyNotFound
+	}
+	return value, nil
+}
+
+func (s *memoryCacheStore) Set(_ context.Context, key, value string, expiration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failing != nil {
+		return s.failing
+	}
+	s.values[key] = value
+	s.expires[key] = s.now().Add(expiration)
+	return nil
+}
+
+func (s *memoryCacheStore) Delete(_ context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		delete(s.values, key)
+	}
+	return nil
+}
+
+type cachedUser struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// testClock is a clock tests can advance.
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func newTestCache(t *testing.T) (*Cache[cachedUser], *memoryCacheStore, *testClock) {
+	t.Helper()
+	clock := &testClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store := newMemoryCacheStore(clock.Now)
+	config := DefaultCacheConfig(time.Minute)
+	config.StaleTTL = 5 * time.Minute
+	config.NegativeTTL = 10 * time.Second
+	cache := NewCache[cachedUser](store, config)
+	cache.now = clock.Now
+	cache.random = func() float64 { return 1 } // never refresh early
+	return cache, store, clock
+}
+
+func TestCache_SingleFlight(t *testing.T) {
+	cache, _, _ := newTestCache(t)
+	ctx := context.Background()
+
+	var loads atomic.Int32
+	release := make(chan struct{})
+	load := func(context.Context) (cachedUser, error) {
+		loads.Add(1)
+		<-release
+		return cachedUser{ID: "u1", Name: "Ada"}, nil
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			user, err := cache.Get(ctx, "user:u1", load)
+			if err == nil && user.Name != "Ada" {
+				err = errors.New("unexpected user " + user.Name)
+			}
+			errs <- err
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+	}
+	if loads.Load() != 1 {
+		t.Errorf("expected one load, got %d", loads.Load())
+	}
+
+	if _, err := cache.Get(ctx, "user:u1", func(context.Context) (cachedUser, error) {
+		t.Error("cached value should not be loaded again")
+		return cachedUser{}, nil
+	}); err != nil {
+		t.Errorf("Get failed: %v", err)
+	}
+}
+
+func TestCache_NegativeCaching(t *testing.T) {
+	cache, _, clock := newTestCache(t)
+	ctx := context.Background()
+
+	loads := 0
+	load := func(context.Context) (cachedUser, error) {
+		loads++
+		return cachedUser{}, apperrors.NotFound("user")
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := cache.Get(ctx, "user:missing", load); !apperrors.IsNotFound(err) {
+			t.Fatalf("expected not found, got %v", err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("expected not found to be cached, got %d loads", loads)
+	}
+
+	clock.Advance(11 * time.Second)
+	if _, err := cache.Get(ctx, "user:missing", load); !apperrors.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if loads != 2 {
+		t.Errorf("expected a reload after NegativeTTL, got %d loads", loads)
+	}
+}
+
+func TestCache_StaleWhileRevalidate(t *testing.T) {
+	cache, _, clock := newTestCache(t)
+	ctx := context.Background()
+	if err := cache.Set(ctx, "user:u1", cachedUser{ID: "u1", Name: "Ada"}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	outage := errors.New("sql: connection refused")
+	failing := func(context.Context) (cachedUser, error) { return cachedUser{}, outage }
+
+	clock.Advance(2 * time.Minute)
+	user, err := cache.Get(ctx, "user:u1", failing)
+	if err != nil || user.Name != "Ada" {
+		t.Fatalf("expected the stale value, got %+v, %v", user, err)
+	}
+
+	user, err = cache.Get(ctx, "user:u1", func(context.Context) (cachedUser, error) {
+		return cachedUser{ID: "u1", Name: "Ada L."}, nil
+	})
+	if err != nil || user.Name != "Ada L." {
+		t.Fatalf("expected the reloaded value, got %+v, %v", user, err)
+	}
+
+	clock.Advance(7 * time.Minute)
+	if _, err := cache.Get(ctx, "user:u1", failing); !errors.Is(err, outage) {
+		t.Errorf("values past StaleTTL should not be served, got %v", err)
+	}
+}
+
+func TestCache_EarlyRefresh(t *testing.T) {
+	cache, _, clock := newTestCache(t)
+	ctx := context.Background()
+
+	name := "Ada"
+	loads := 0
+	load := func(context.Context) (cachedUser, error) {
+		loads++
+		clock.Advance(time.Second) // loading takes a second
+		return cachedUser{ID: "u1", Name: name}, nil
+	}
+	if _, err := cache.Get(ctx, "user:u1", load); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	clock.Advance(55 * time.Second) // expires in 5s
+	name = "Ada L."
+	cache.random = func() float64 { return 0.0001 } // refresh up to ~9s ahead for a 1s load
+
+	user, err := cache.Get(ctx, "user:u1", load)
+	if err != nil || user.Name != "Ada" {
+		t.Fatalf("expected the cached value while refreshing, got %+v, %v", user, err)
+	}
+	cache.refreshing.Wait()
+	if loads != 2 {
+		t.Fatalf("expected a background refresh, got %d loads", loads)
+	}
+
+	cache.random = func() float64 { return 1 }
+	user, _ = cache.Get(ctx, "user:u1", load)
+	if user.Name != "Ada L." || loads != 2 {
+		t.Errorf("expected the refreshed value, got %+v after %d loads", user, loads)
+	}
+}
+
+func TestCache_StoreFailure(t *testing.T) {
+	cache, store, _ := newTestCache(t)
+	store.failing = errors.New("redis: connection refused")
+
+	user, err := cache.Get(context.Background(), "user:u1", func(context.Context) (cachedUser, error) {
+		return cachedUser{ID: "u1", Name: "Ada"}, nil
+	})
+	if err != nil || user.Name != "Ada" {
+		t.Errorf("a failing store should fall through to the loader, got %+v, %v", user, err)
+	}
+}
@@ -0,0 +1,45 @@
+// Package database provides database client utilities.
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mycobrun/cobrun-shared/geo"
+)
+
+// RedisGeofenceStateStore persists geofence membership state in Redis so that
+// every replica consuming location updates sees the same state.
+type RedisGeofenceStateStore struct {
+	client *RedisClient
+}
+
+// NewRedisGeofenceStateStore creates a new Redis-backed geofence state store.
+func NewRedisGeofenceStateStore(client *RedisClient) *RedisGeofenceStateStore {
+	return &RedisGeofenceStateStore{client: client}
+}
+
+// Get loads the membership state of an entity.
+func (s *RedisGeofenceStateStore) Get(ctx context.Context, entityID string) (*geo.GeofenceMembership, error) {
+	var membership geo.GeofenceMembership
+	err := s.client.GetJSON(ctx, fmt.Sprintf(RedisKeyPatterns.GeofenceState, entityID), &membership)
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// Save stores the membership state, refreshing its TTL.
+func (s *RedisGeofenceStateStore) Save(ctx context.Context, membership *geo.GeofenceMembership) error {
+	key := fmt.Sprintf(RedisKeyPatterns.GeofenceState, membership.EntityID)
+	return s.client.SetJSON(ctx, key, membership, RedisTTLs.GeofenceState)
+}
+
+// Delete removes the membership state of an entity.
+func (s *RedisGeofenceStateStore) Delete(ctx context.Context, entityID string) error {
+	return s.client.Delete(ctx, fmt.Sprintf(RedisKeyPatterns.GeofenceState, entityID))
+}
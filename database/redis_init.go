@@ -5,8 +5,10 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strconv"
 	"time"
 
+	"github.com/mycobrun/cobrun-shared/geo"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -15,11 +17,16 @@ var RedisKeyPatterns = struct {
 	// Geospatial keys for driver locations
 	DriverLocations     string // driver_locations:{city}
 	DriverLocationsByID string // driver_location:{driver_id}
+	DriverLastSeen      string // driver_last_seen:{city}
+	DriverH3Cells       string // driver_h3:{city}
 
 	// Driver status
 	DriverStatus       string // driver:{driver_id}:status
+	DriverStatusHistory string // driver:{driver_id}:status_history
 	ActiveDrivers      string // active_drivers:{city}
 	OnlineDrivers      string // online_drivers:{city}
+	DriverRevisions    string // driver_revision:{city}
+	DriverOfflineRevisions string // driver_offline_revision:{city}
 
 	// Surge pricing
 	SurgeZone          string // surge:{zone_id}
@@ -32,6 +39,7 @@ var RedisKeyPatterns = struct {
 	// Driver offers
 	DriverOffer        string // offer:{offer_id}
 	DriverPendingOffer string // driver:{driver_id}:pending_offer
+	OfferExpiry        string // offers:expiring
 
 	// Rate limiting
 	RateLimit          string // ratelimit:{entity_type}:{entity_id}:{action}
@@ -42,6 +50,7 @@ var RedisKeyPatterns = struct {
 
 	// Caching
 	GeofenceCache      string // geofence:{geofence_id}
+	GeofenceState      string // geofence_state:{entity_id}
 	PriceEstimateCache string // price_estimate:{hash}
 	RateCardCache      string // rate_card:{service_area_id}:{ride_type}
 	UserCache          string // user:{user_id}
@@ -52,19 +61,26 @@ var RedisKeyPatterns = struct {
 }{
 	DriverLocations:     "driver_locations:%s",
 	DriverLocationsByID: "driver_location:%s",
+	DriverLastSeen:      "driver_last_seen:%s",
+	DriverH3Cells:       "driver_h3:%s",
 	DriverStatus:        "driver:%s:status",
+	DriverStatusHistory: "driver:%s:status_history",
 	ActiveDrivers:       "active_drivers:%s",
 	OnlineDrivers:       "online_drivers:%s",
+	DriverRevisions:     "driver_revision:%s",
+	DriverOfflineRevisions: "driver_offline_revision:%s",
 	SurgeZone:           "surge:%s",
 	SurgeByCity:         "surge:city:%s",
 	ActiveRequest:       "request:%s",
 	RiderActiveRequest:  "rider:%s:active_request",
 	DriverOffer:         "offer:%s",
 	DriverPendingOffer:  "driver:%s:pending_offer",
+	OfferExpiry:         "offers:expiring",
 	RateLimit:           "ratelimit:%s:%s:%s",
 	Session:             "session:%s",
 	UserSessions:        "user:%s:sessions",
 	GeofenceCache:       "geofence:%s",
+	GeofenceState:       "geofence_state:%s",
 	PriceEstimateCache:  "price_estimate:%s",
 	RateCardCache:       "rate_card:%s:%s",
 	UserCache:           "user:%s",
@@ -76,12 +92,15 @@ var RedisKeyPatterns = struct {
 var RedisTTLs = struct {
 	DriverLocation     time.Duration
 	DriverStatus       time.Duration
+	DriverStatusHistory time.Duration
 	ActiveDriver       time.Duration
 	Surge              time.Duration
 	ActiveRequest      time.Duration
 	DriverOffer        time.Duration
+	OfferRetention     time.Duration
 	Session            time.Duration
 	GeofenceCache      time.Duration
+	GeofenceState      time.Duration
 	PriceEstimateCache time.Duration
 	RateCardCache      time.Duration
 	UserCache          time.Duration
@@ -89,12 +108,15 @@ var RedisTTLs = struct {
 }{
 	DriverLocation:     30 * time.Second,  // Stale after 30 seconds
 	DriverStatus:       5 * time.Minute,   // Refresh every 5 minutes
+	DriverStatusHistory: 24 * time.Hour,   // Transition history
 	ActiveDriver:       1 * time.Minute,   // Quick expiry for active lists
 	Surge:              5 * time.Minute,   // Surge data refresh
 	ActiveRequest:      10 * time.Minute,  // Request timeout
 	DriverOffer:        15 * time.Second,  // Offer expiry
+	OfferRetention:     5 * time.Minute,   // Resolved offers kept for late responses
 	Session:            24 * time.Hour,    // Session duration
 	GeofenceCache:      1 * time.Hour,     // Geofence cache
+	GeofenceState:      1 * time.Hour,     // Membership state of idle entities
 	PriceEstimateCache: 5 * time.Minute,   // Price estimate validity
 	RateCardCache:      1 * time.Hour,     // Rate card cache
 	UserCache:          15 * time.Minute,  // User cache
@@ -134,7 +156,7 @@ func (ri *RedisInitializer) Initialize(ctx context.Context) error {
 
 	// Ensure geo keys exist for each city
 	for _, city := range defaultCities {
-		key := fmt.Sprintf(RedisKeyPatterns.DriverLocations, city)
+		key := ri.client.Key(RedisKeyPatterns.DriverLocations, city)
 		// Just ensure the key exists by checking it
 		_, _ = ri.client.client.Exists(ctx, key).Result()
 	}
@@ -147,6 +169,8 @@ func (ri *RedisInitializer) Initialize(ctx context.Context) error {
 type DriverStatusData struct {
 	Status       string    `json:"status"`
 	VehicleID    string    `json:"vehicle_id,omitempty"`
+	VehicleClass string    `json:"vehicle_class,omitempty"`
+	Rating       float64   `json:"rating,omitempty"`
 	TripID       string    `json:"trip_id,omitempty"`
 	LastLocation string    `json:"last_location,omitempty"` // "lat,lng"
 	UpdatedAt    time.Time `json:"updated_at"`
@@ -179,6 +203,7 @@ type OfferData struct {
 	RequestID string    `json:"request_id"`
 	DriverID  string    `json:"driver_id"`
 	Fare      float64   `json:"fare"`
+	Status    string    `json:"status,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 	ExpiresAt time.Time `json:"expires_at"`
 }
@@ -196,65 +221,213 @@ type SessionData struct {
 
 // DriverLocationService provides Redis-based driver location operations.
 type DriverLocationService struct {
-	client *RedisClient
+	client       *RedisClient
+	availability *DriverAvailability
+	tracker      *geo.GeofenceTracker
 }
 
 // NewDriverLocationService creates a new driver location service.
 func NewDriverLocationService(client *RedisClient) *DriverLocationService {
-	return &DriverLocationService{client: client}
+	return &DriverLocationService{client: client, availability: NewDriverAvailability(client)}
+}
+
+// WithGeofenceTracker feeds every location update into a geofence tracker.
+func (s *DriverLocationService) WithGeofenceTracker(tracker *geo.GeofenceTracker) *DriverLocationService {
+	s.tracker = tracker
+	return s
 }
 
-// UpdateLocation updates a driver's location.
+// UpdateLocation updates a driver's location and last-seen time through
+// DriverAvailability. Returns ErrDriverNotOnline, ignoring the update, if
+// the driver is not online, so a late update cannot put them back into
+// search results.
 func (s *DriverLocationService) UpdateLocation(ctx context.Context, driverID, city string, lat, lng float64) error {
-	key := fmt.Sprintf(RedisKeyPatterns.DriverLocations, city)
-	location := &redis.GeoLocation{
-		Name:      driverID,
-		Longitude: lng,
-		Latitude:  lat,
+	now := time.Now()
+
+	applied, err := s.availability.UpdateLocation(ctx, driverID, city, geo.NewPoint(lat, lng))
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return ErrDriverNotOnline
 	}
-	return s.client.GeoAdd(ctx, key, location)
+
+	if s.tracker != nil {
+		_, err := s.tracker.Update(ctx, geo.LocationUpdate{
+			EntityID:  driverID,
+			Location:  geo.NewPoint(lat, lng),
+			Timestamp: now,
+		})
+		if err != nil {
+			return fmt.Errorf("geofence tracking failed: %w", err)
+		}
+	}
+
+	return nil
 }
 
-// GetNearbyDrivers finds drivers near a location.
+// nearbyDriversLimit is the number of drivers GetNearbyDrivers returns at
+// most, and maxNearbyDriversScan the number it reads at most to find them.
+const (
+	nearbyDriversLimit   = 50
+	maxNearbyDriversScan = 800
+)
+
+// GetNearbyDrivers finds up to 50 drivers near a location, nearest first.
+// Drivers not seen within RedisTTLs.DriverLocation are left out; up to
+// maxNearbyDriversScan are read so that stale drivers not yet evicted by the
+// sweeper do not crowd out fresh ones.
 func (s *DriverLocationService) GetNearbyDrivers(ctx context.Context, city string, lat, lng, radiusKm float64) ([]redis.GeoLocation, error) {
-	key := fmt.Sprintf(RedisKeyPatterns.DriverLocations, city)
+	key := s.client.Key(RedisKeyPatterns.DriverLocations, city)
 	query := &redis.GeoRadiusQuery{
 		Radius:      radiusKm,
 		Unit:        "km",
 		WithCoord:   true,
 		WithDist:    true,
 		WithGeoHash: true,
-		Count:       50,
+		Sort:        "ASC",
 	}
-	return s.client.GeoRadius(ctx, key, lng, lat, query)
+
+	for count := 2 * nearbyDriversLimit; ; count *= 2 {
+		query.Count = count
+		locations, err := s.client.GeoRadius(ctx, key, lng, lat, query)
+		if err != nil || len(locations) == 0 {
+			return locations, err
+		}
+		fresh, err := s.filterStale(ctx, city, locations)
+		if err != nil {
+			return nil, err
+		}
+		if len(fresh) >= nearbyDriversLimit || len(locations) < count || count >= maxNearbyDriversScan {
+			if len(fresh) > nearbyDriversLimit {
+				fresh = fresh[:nearbyDriversLimit]
+			}
+			return fresh, nil
+		}
+	}
+}
+
+// filterStale drops members whose last-seen time is older than the location
+// TTL, or missing. GEO members cannot expire on their own.
+func (s *DriverLocationService) filterStale(ctx context.Context, city string, locations []redis.GeoLocation) ([]redis.GeoLocation, error) {
+	members := make([]string, len(locations))
+	for i, loc := range locations {
+		members[i] = loc.Name
+	}
+
+	scores, err := s.client.client.ZMScore(ctx, s.client.Key(RedisKeyPatterns.DriverLastSeen, city), members...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get driver last-seen times: %w", err)
+	}
+
+	cutoff := float64(time.Now().Add(-RedisTTLs.DriverLocation).UnixMilli())
+	fresh := locations[:0]
+	for i, loc := range locations {
+		// A missing member scores 0
+		if i < len(scores) && scores[i] >= cutoff {
+			fresh = append(fresh, loc)
+		}
+	}
+	return fresh, nil
+}
+
+// LastSeen returns when a driver last reported a location.
+// Returns ErrKeyNotFound if the driver has not been seen.
+func (s *DriverLocationService) LastSeen(ctx context.Context, driverID, city string) (time.Time, error) {
+	score, err := s.client.client.ZScore(ctx, s.client.Key(RedisKeyPatterns.DriverLastSeen, city), driverID).Result()
+	if err == redis.Nil {
+		return time.Time{}, ErrKeyNotFound
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(int64(score)), nil
 }
 
 // RemoveDriver removes a driver from location tracking.
 func (s *DriverLocationService) RemoveDriver(ctx context.Context, driverID, city string) error {
-	key := fmt.Sprintf(RedisKeyPatterns.DriverLocations, city)
+	if err := s.client.ZRem(ctx, s.client.Key(RedisKeyPatterns.DriverLastSeen, city), driverID); err != nil {
+		return err
+	}
+	if err := s.client.client.HDel(ctx, s.client.Key(RedisKeyPatterns.DriverH3Cells, city), driverID).Err(); err != nil {
+		return err
+	}
+	key := s.client.Key(RedisKeyPatterns.DriverLocations, city)
 	return s.client.ZRem(ctx, key, driverID)
 }
 
-// SetDriverOnline marks a driver as online.
+// SetDriverOnline marks a driver as online through DriverAvailability,
+// keeping their location if they were already online.
 func (s *DriverLocationService) SetDriverOnline(ctx context.Context, driverID, city string) error {
-	key := fmt.Sprintf(RedisKeyPatterns.OnlineDrivers, city)
-	_, err := s.client.SAdd(ctx, key, driverID)
+	_, err := s.availability.Transition(ctx, DriverTransitionRequest{DriverID: driverID, City: city, To: DriverStateOnline})
 	return err
 }
 
-// SetDriverOffline marks a driver as offline.
+// SetDriverOffline marks a driver as offline through DriverAvailability,
+// which removes them from the online and geo sets.
 func (s *DriverLocationService) SetDriverOffline(ctx context.Context, driverID, city string) error {
-	// Remove from online drivers set
-	key := fmt.Sprintf(RedisKeyPatterns.OnlineDrivers, city)
-	_, _ = s.client.SRem(ctx, key, driverID)
+	if _, err := s.availability.GoOffline(ctx, driverID, city, ""); err != nil {
+		return err
+	}
+
+	// Stop geofence tracking
+	if s.tracker != nil {
+		_ = s.tracker.Remove(ctx, driverID)
+	}
 
-	// Remove from location geo set
 	return s.RemoveDriver(ctx, driverID, city)
 }
 
 // GetOnlineDriverCount returns the number of online drivers in a city.
 func (s *DriverLocationService) GetOnlineDriverCount(ctx context.Context, city string) (int64, error) {
-	key := fmt.Sprintf(RedisKeyPatterns.OnlineDrivers, city)
+	key := s.client.Key(RedisKeyPatterns.OnlineDrivers, city)
 	return s.client.SCard(ctx, key)
 }
 
+// SetDriverStatus stores a driver's vehicle and rating details in their
+// status hash. Status, TripID and LastLocation are owned by
+// DriverAvailability and are not written; neither is the hash's expiry.
+func (s *DriverLocationService) SetDriverStatus(ctx context.Context, driverID string, status *DriverStatusData) error {
+	key := s.client.Key(RedisKeyPatterns.DriverStatus, driverID)
+	if status.UpdatedAt.IsZero() {
+		status.UpdatedAt = time.Now().UTC()
+	}
+
+	err := s.client.client.HSet(ctx, key,
+		"vehicle_id", status.VehicleID,
+		"vehicle_class", status.VehicleClass,
+		"rating", strconv.FormatFloat(status.Rating, 'f', -1, 64),
+		"updated_at", status.UpdatedAt.Format(time.RFC3339Nano),
+	).Err()
+	if err != nil {
+		return fmt.Errorf("failed to set driver status: %w", err)
+	}
+	return nil
+}
+
+// GetDriverStatus reads a driver's status hash.
+// Returns ErrKeyNotFound if the driver has no status.
+func (s *DriverLocationService) GetDriverStatus(ctx context.Context, driverID string) (*DriverStatusData, error) {
+	fields, err := s.client.HGetAll(ctx, s.client.Key(RedisKeyPatterns.DriverStatus, driverID))
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, ErrKeyNotFound
+	}
+	return parseDriverStatus(fields), nil
+}
+
+func parseDriverStatus(fields map[string]string) *DriverStatusData {
+	rating, _ := strconv.ParseFloat(fields["rating"], 64)
+	updatedAt, _ := time.Parse(time.RFC3339Nano, fields["updated_at"])
+	return &DriverStatusData{
+		Status:       fields["status"],
+		VehicleID:    fields["vehicle_id"],
+		VehicleClass: fields["vehicle_class"],
+		Rating:       rating,
+		TripID:       fields["trip_id"],
+		LastLocation: fields["last_location"],
+		UpdatedAt:    updatedAt,
+	}
+}
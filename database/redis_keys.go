@@ -0,0 +1,75 @@
+// Package database provides database client utilities.
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HashTag wraps s in braces so that Redis Cluster hashes only s, putting
+// every key that contains the same tag on the same slot.
+func HashTag(s string) string {
+	return "{" + s + "}"
+}
+
+// TaggedKey formats one of RedisKeyPatterns with its first argument as the
+// hash tag, e.g. "driver_locations:{austin}". Keys built from the same first
+// argument, such as the geo, last-seen and H3 keys of a city, share a slot
+// and can be used together in a Lua script or MULTI on a cluster.
+func TaggedKey(pattern string, args ...interface{}) string {
+	if len(args) == 0 {
+		return pattern
+	}
+	tagged := make([]interface{}, len(args))
+	copy(tagged, args)
+	tagged[0] = HashTag(fmt.Sprint(args[0]))
+	return fmt.Sprintf(pattern, tagged...)
+}
+
+// Key formats one of RedisKeyPatterns for this client. In cluster mode the
+// key is hash tagged as by TaggedKey; otherwise it is formatted as is, so
+// keys written by existing standalone deployments keep their names.
+func (r *RedisClient) Key(pattern string, args ...interface{}) string {
+	if r.Mode() == RedisModeCluster {
+		return TaggedKey(pattern, args...)
+	}
+	return fmt.Sprintf(pattern, args...)
+}
+
+// KeySlot returns the Redis Cluster slot of key, honouring hash tags.
+func KeySlot(key string) int {
+	if tag, ok := hashTagOf(key); ok {
+		key = tag
+	}
+	return int(crc16(key) % 16384)
+}
+
+// hashTagOf returns the part of key Redis Cluster hashes, if it has a
+// non-empty hash tag.
+func hashTagOf(key string) (string, bool) {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return "", false
+	}
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 {
+		return "", false
+	}
+	return key[start+1 : start+1+end], true
+}
+
+// crc16 is the CRC16-CCITT (XMODEM) checksum Redis Cluster hashes keys with.
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestTaggedKey(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		args     []interface{}
+		expected string
+	}{
+		{RedisKeyPatterns.DriverLocations, []interface{}{"austin"}, "driver_locations:{austin}"},
+		{RedisKeyPatterns.DriverStatus, []interface{}{"d1"}, "driver:{d1}:status"},
+		{RedisKeyPatterns.RateCardCache, []interface{}{"sa1", "economy"}, "rate_card:{sa1}:economy"},
+		{RedisKeyPatterns.OfferExpiry, nil, "offers:expiring"},
+	}
+	for _, tt := range tests {
+		if got := TaggedKey(tt.pattern, tt.args...); got != tt.expected {
+			t.Errorf("TaggedKey(%q) = %q, want %q", tt.pattern, got, tt.expected)
+		}
+	}
+}
+
+func TestKeySlot(t *testing.T) {
+	// Reference values from CLUSTER KEYSLOT
+	if slot := KeySlot("123456789"); slot != 12739 {
+		t.Errorf("expected slot 12739, got %d", slot)
+	}
+	if KeySlot("{user1000}.following") != KeySlot("{user1000}.followers") {
+		t.Error("keys with the same hash tag should share a slot")
+	}
+	if KeySlot("foo{}{bar}") != KeySlot("foo{}{bar}") || KeySlot("foo{}{bar}") == KeySlot("bar") {
+		t.Error("an empty hash tag should hash the whole key")
+	}
+}
+
+func TestRedisClient_Key(t *testing.T) {
+	standalone := &RedisClient{config: RedisConfig{}}
+	if key := standalone.Key(RedisKeyPatterns.DriverStatus, "d1"); key != "driver:d1:status" {
+		t.Errorf("standalone keys should keep their names, got %q", key)
+	}
+
+	cluster := &RedisClient{config: RedisConfig{Mode: RedisModeCluster}}
+	sameSlot := func(name string, keys ...string) {
+		t.Helper()
+		for _, key := range keys[1:] {
+			if KeySlot(key) != KeySlot(keys[0]) {
+				t.Errorf("%s: %q and %q are on different slots", name, keys[0], key)
+			}
+		}
+	}
+	sameSlot("driver transition",
+		cluster.Key(RedisKeyPatterns.DriverStatus, "d1"),
+		cluster.Key(RedisKeyPatterns.DriverStatusHistory, "d1"),
+	)
+	sameSlot("driver index",
+		cluster.Key(RedisKeyPatterns.OnlineDrivers, "austin"),
+		cluster.Key(RedisKeyPatterns.DriverLocations, "austin"),
+		cluster.Key(RedisKeyPatterns.DriverLastSeen, "austin"),
+		cluster.Key(RedisKeyPatterns.DriverRevisions, "austin"),
+		cluster.Key(RedisKeyPatterns.DriverOfflineRevisions, "austin"),
+		cluster.Key(RedisKeyPatterns.DriverH3Cells, "austin"),
+	)
+}
+
+func TestNewUniversalClient(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  RedisConfig
+		cluster bool
+		wantErr bool
+	}{
+		{name: "standalone", config: RedisConfig{Host: "localhost", Port: 6379}},
+		{name: "cluster", config: RedisConfig{Mode: RedisModeCluster, Addrs: []string{"cache.redis.cache.windows.net:6380"}}, cluster: true},
+		{name: "sentinel", config: RedisConfig{Mode: RedisModeSentinel, Addrs: []string{"s1:26379", "s2:26379"}, MasterName: "cobrun"}},
+		{name: "sentinel replica reads", config: RedisConfig{Mode: RedisModeSentinel, Addrs: []string{"s1:26379"}, MasterName: "cobrun", ReadOnly: true}, cluster: true},
+		{name: "sentinel without master", config: RedisConfig{Mode: RedisModeSentinel, Addrs: []string{"s1:26379"}}, wantErr: true},
+		{name: "standalone with several addresses", config: RedisConfig{Addrs: []string{"a:6379", "b:6379"}}, wantErr: true},
+		{name: "cluster with DB", config: RedisConfig{Mode: RedisModeCluster, Addrs: []string{"a:6379"}, DB: 1}, wantErr: true},
+		{name: "unknown mode", config: RedisConfig{Mode: "replicated"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := newUniversalClient(tt.config)
+			if tt.wantErr {
+				if err == nil {
+					client.Close()
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newUniversalClient failed: %v", err)
+			}
+			defer client.Close()
+
+			_, isCluster := client.(*redis.ClusterClient)
+			if isCluster != tt.cluster {
+				t.Errorf("expected cluster client %v, got %T", tt.cluster, client)
+			}
+		})
+	}
+}
+
+func TestFencingKey(t *testing.T) {
+	for _, key := range []string{"lock:stale_driver_sweeper", "lock:{d1}:offer"} {
+		fencing := fencingKey(key)
+		if fencing == key {
+			t.Errorf("fencing key for %q should differ from the lock key", key)
+		}
+		if KeySlot(fencing) != KeySlot(key) {
+			t.Errorf("fencing key %q is not on the slot of %q", fencing, key)
+		}
+	}
+}
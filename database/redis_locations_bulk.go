@@ -0,0 +1,216 @@
+// Package database provides database client utilities.
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mycobrun/cobrun-shared/geo"
+)
+
+// DriverLocationUpdate is a single entry of a bulk location update.
+type DriverLocationUpdate struct {
+	DriverID  string
+	City      string
+	Lat       float64
+	Lng       float64
+	Timestamp time.Time
+}
+
+// BulkLocationConfig holds bulk location ingestion configuration.
+type BulkLocationConfig struct {
+	// ChunkSize is the number of drivers per pipeline round trip; it bounds
+	// the latency of each round trip.
+	ChunkSize int
+	// Concurrency is the number of chunks written at once.
+	Concurrency int
+	// Timeout bounds each pipeline round trip (0 disables).
+	Timeout time.Duration
+}
+
+// DefaultBulkLocationConfig returns sensible defaults.
+func DefaultBulkLocationConfig() BulkLocationConfig {
+	return BulkLocationConfig{
+		ChunkSize:   500,
+		Concurrency: 4,
+		Timeout:     2 * time.Second,
+	}
+}
+
+// BulkLocationResult summarizes a bulk location update.
+type BulkLocationResult struct {
+	// Accepted counts updates that moved a driver.
+	Accepted int `json:"accepted"`
+	// Rejected counts updates with a missing driver or city or invalid coordinates.
+	Rejected int `json:"rejected"`
+	// Superseded counts older updates for a driver already in the batch.
+	Superseded int `json:"superseded"`
+	// Skipped counts updates for drivers not online, or older than the
+	// driver's last-seen time.
+	Skipped int `json:"skipped"`
+	Cities  int `json:"cities"`
+	// RoundTrips counts the pipelined chunks written.
+	RoundTrips int           `json:"round_trips"`
+	Duration   time.Duration `json:"duration"`
+}
+
+// UpdateLocations applies many location updates with a few round trips.
+// Updates are grouped per city and only the latest update per driver is
+// kept; each chunk is pipelined through the same scripts as UpdateLocation,
+// so drivers who are not online and updates older than the last-seen time
+// are skipped. Chunks are written concurrently across cities.
+func (s *DriverLocationService) UpdateLocations(ctx context.Context, updates []DriverLocationUpdate, config BulkLocationConfig) (*BulkLocationResult, error) {
+	start := time.Now()
+	result := &BulkLocationResult{}
+
+	byCity := groupLocationUpdates(updates, result)
+	result.Cities = len(byCity)
+
+	chunkSize := config.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = 500
+	}
+	concurrency := config.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	var chunks [][]DriverLocationUpdate
+	for _, cityUpdates := range byCity {
+		for i := 0; i < len(cityUpdates); i += chunkSize {
+			end := i + chunkSize
+			if end > len(cityUpdates) {
+				end = len(cityUpdates)
+			}
+			chunks = append(chunks, cityUpdates[i:end])
+		}
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		moved    []DriverLocationUpdate
+	)
+	sem := make(chan struct{}, concurrency)
+	for _, chunk := range chunks {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(chunk []DriverLocationUpdate) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			applied, err := s.writeLocationChunk(ctx, chunk, config.Timeout)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			result.RoundTrips++
+			for i, u := range chunk {
+				if applied[i] {
+					result.Accepted++
+					moved = append(moved, u)
+				} else {
+					result.Skipped++
+				}
+			}
+		}(chunk)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		result.Duration = time.Since(start)
+		return result, firstErr
+	}
+
+	if s.tracker != nil {
+		for _, u := range moved {
+			_, err := s.tracker.Update(ctx, geo.LocationUpdate{
+				EntityID:  u.DriverID,
+				Location:  geo.NewPoint(u.Lat, u.Lng),
+				Timestamp: u.Timestamp,
+			})
+			if err != nil {
+				result.Duration = time.Since(start)
+				return result, fmt.Errorf("geofence tracking failed: %w", err)
+			}
+		}
+	}
+
+	result.Duration = time.Since(start)
+	return result, nil
+}
+
+// writeLocationChunk applies a chunk of updates and reports which were applied.
+func (s *DriverLocationService) writeLocationChunk(ctx context.Context, updates []DriverLocationUpdate, timeout time.Duration) ([]bool, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return s.availability.UpdateLocations(ctx, updates)
+}
+
+// groupLocationUpdates validates updates, keeps the latest per driver and
+// groups them by city. Missing timestamps are set to now.
+func groupLocationUpdates(updates []DriverLocationUpdate, result *BulkLocationResult) map[string][]DriverLocationUpdate {
+	now := time.Now()
+	type position struct {
+		city  string
+		index int
+	}
+	latest := make(map[string]position, len(updates))
+	byCity := make(map[string][]DriverLocationUpdate)
+
+	for _, u := range updates {
+		if u.DriverID == "" || u.City == "" || !geo.NewPoint(u.Lat, u.Lng).IsValid() {
+			result.Rejected++
+			continue
+		}
+		if u.Timestamp.IsZero() {
+			u.Timestamp = now
+		}
+
+		if pos, ok := latest[u.DriverID]; ok {
+			result.Superseded++
+			prev := byCity[pos.city][pos.index]
+			if !u.Timestamp.After(prev.Timestamp) {
+				continue
+			}
+			if pos.city == u.City {
+				byCity[pos.city][pos.index] = u
+				continue
+			}
+			// The driver crossed into another city within the batch
+			byCity[pos.city][pos.index].DriverID = ""
+		}
+
+		latest[u.DriverID] = position{city: u.City, index: len(byCity[u.City])}
+		byCity[u.City] = append(byCity[u.City], u)
+	}
+
+	// Drop entries superseded from another city
+	for city, cityUpdates := range byCity {
+		kept := cityUpdates[:0]
+		for _, u := range cityUpdates {
+			if u.DriverID != "" {
+				kept = append(kept, u)
+			}
+		}
+		if len(kept) == 0 {
+			delete(byCity, city)
+			continue
+		}
+		byCity[city] = kept
+	}
+
+	return byCity
+}
@@ -0,0 +1,154 @@
+//go:build integration
+
+// Package database provides database client utilities.
+package database
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mycobrun/cobrun-shared/geo"
+	"github.com/mycobrun/cobrun-shared/testing/fixtures"
+)
+
+func TestUpdateLocations_Integration(t *testing.T) {
+	ctx, client := newIntegrationRedisClient(t)
+	locations := NewDriverLocationService(client)
+
+	batches := make([]fixtures.LocationBatchFixture, 1200)
+	for i := range batches {
+		batches[i] = fixtures.NewLocationBatch(fmt.Sprintf("driver-%d", i), 3)
+	}
+	updates := locationUpdatesFromBatches(batches, "seattle", "portland")
+	setDriversOnline(t, ctx, client, updates)
+	// driver-1199 went offline before its updates arrived
+	if _, err := NewDriverAvailability(client).GoOffline(ctx, "driver-1199", "portland", "logged_out"); err != nil {
+		t.Fatalf("GoOffline failed: %v", err)
+	}
+
+	config := DefaultBulkLocationConfig()
+	result, err := locations.UpdateLocations(ctx, updates, config)
+	if err != nil {
+		t.Fatalf("UpdateLocations failed: %v", err)
+	}
+	if result.Accepted != 1199 || result.Skipped != 1 || result.Superseded != 2400 || result.Cities != 2 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	// 600 drivers per city in chunks of 500
+	if result.RoundTrips != 4 {
+		t.Errorf("expected 4 round trips, got %d", result.RoundTrips)
+	}
+
+	last := batches[0].Points[2]
+	lastSeen, err := locations.LastSeen(ctx, "driver-0", "seattle")
+	if err != nil {
+		t.Fatalf("LastSeen failed: %v", err)
+	}
+	if lastSeen.UnixMilli() != last.Timestamp.UnixMilli() {
+		t.Errorf("expected last seen %v, got %v", last.Timestamp, lastSeen)
+	}
+
+	cell, err := client.HGet(ctx, fmt.Sprintf(RedisKeyPatterns.DriverH3Cells, "seattle"), "driver-0")
+	if err != nil {
+		t.Fatalf("HGet failed: %v", err)
+	}
+	expected := geo.NewH3Index(geo.H3ResolutionBlock).GetCellString(geo.NewPoint(last.Lat, last.Lng))
+	if cell != expected {
+		t.Errorf("expected cell %s, got %s", expected, cell)
+	}
+
+	// Like a single update, the status hash records the last location
+	location, err := client.HGet(ctx, fmt.Sprintf(RedisKeyPatterns.DriverStatus, "driver-0"), "last_location")
+	if err != nil {
+		t.Fatalf("HGet failed: %v", err)
+	}
+	if expected := fmt.Sprintf("%s,%s", strconv.FormatFloat(last.Lat, 'f', -1, 64), strconv.FormatFloat(last.Lng, 'f', -1, 64)); location != expected {
+		t.Errorf("expected last location %s, got %s", expected, location)
+	}
+
+	count, err := client.client.ZCard(ctx, fmt.Sprintf(RedisKeyPatterns.DriverLocations, "seattle")).Result()
+	if err != nil {
+		t.Fatalf("ZCard failed: %v", err)
+	}
+	if count != 600 {
+		t.Errorf("expected 600 drivers in seattle, got %d", count)
+	}
+	pos, _ := client.GeoPos(ctx, fmt.Sprintf(RedisKeyPatterns.DriverLocations, "portland"), "driver-1199")
+	if len(pos) != 1 || pos[0] != nil {
+		t.Errorf("expected the offline driver left out of the geo set, got %v", pos)
+	}
+
+	// An older bulk update moves neither the driver nor last-seen backwards
+	stale := []DriverLocationUpdate{{
+		DriverID:  "driver-0",
+		City:      "seattle",
+		Lat:       last.Lat + 0.01,
+		Lng:       last.Lng,
+		Timestamp: last.Timestamp.Add(-time.Hour),
+	}}
+	result, err = locations.UpdateLocations(ctx, stale, config)
+	if err != nil {
+		t.Fatalf("UpdateLocations failed: %v", err)
+	}
+	if result.Accepted != 0 || result.Skipped != 1 {
+		t.Errorf("expected the older update skipped, got %+v", result)
+	}
+	lastSeen, _ = locations.LastSeen(ctx, "driver-0", "seattle")
+	if lastSeen.UnixMilli() != last.Timestamp.UnixMilli() {
+		t.Errorf("expected last seen unchanged, got %v", lastSeen)
+	}
+	pos, _ = client.GeoPos(ctx, fmt.Sprintf(RedisKeyPatterns.DriverLocations, "seattle"), "driver-0")
+	if len(pos) != 1 || pos[0] == nil || pos[0].Latitude > last.Lat+0.001 {
+		t.Errorf("expected the driver left at the newer position, got %v", pos)
+	}
+}
+
+// setDriversOnline moves the drivers of updates online in their city,
+// without a location.
+func setDriversOnline(tb testing.TB, ctx context.Context, client *RedisClient, updates []DriverLocationUpdate) {
+	tb.Helper()
+	availability := NewDriverAvailability(client)
+	seen := make(map[string]bool)
+	for _, u := range updates {
+		if seen[u.DriverID] {
+			continue
+		}
+		seen[u.DriverID] = true
+		_, err := availability.Transition(ctx, DriverTransitionRequest{DriverID: u.DriverID, City: u.City, To: DriverStateOnline})
+		if err != nil {
+			tb.Fatalf("Transition failed: %v", err)
+		}
+	}
+}
+
+// BenchmarkUpdateLocations_Integration measures bulk ingestion throughput
+// against Redis, in drivers/s:
+//
+//	go test -tags integration -run '^$' -bench UpdateLocations ./database
+func BenchmarkUpdateLocations_Integration(b *testing.B) {
+	ctx, client := newIntegrationRedisClient(b)
+	locations := NewDriverLocationService(client)
+
+	batches := make([]fixtures.LocationBatchFixture, 5000)
+	for i := range batches {
+		batches[i] = fixtures.NewLocationBatch(fmt.Sprintf("driver-%d", i), 1)
+	}
+	updates := locationUpdatesFromBatches(batches, "seattle", "portland", "denver")
+	setDriversOnline(b, ctx, client, updates)
+	config := DefaultBulkLocationConfig()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Newer positions each round, so every update is written
+		for j := range updates {
+			updates[j].Timestamp = updates[j].Timestamp.Add(time.Millisecond)
+		}
+		if _, err := locations.UpdateLocations(ctx, updates, config); err != nil {
+			b.Fatalf("UpdateLocations failed: %v", err)
+		}
+	}
+	b.ReportMetric(float64(len(updates))*float64(b.N)/b.Elapsed().Seconds(), "drivers/s")
+}
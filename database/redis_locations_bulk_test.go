@@ -0,0 +1,113 @@
+// Package database provides database client utilities.
+package database
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mycobrun/cobrun-shared/testing/fixtures"
+)
+
+// locationUpdatesFromBatches flattens location batch fixtures into bulk updates,
+// spreading drivers over the given cities.
+func locationUpdatesFromBatches(batches []fixtures.LocationBatchFixture, cities ...string) []DriverLocationUpdate {
+	var updates []DriverLocationUpdate
+	for i, batch := range batches {
+		city := cities[i%len(cities)]
+		for _, p := range batch.Points {
+			updates = append(updates, DriverLocationUpdate{
+				DriverID:  batch.DriverID,
+				City:      city,
+				Lat:       p.Lat,
+				Lng:       p.Lng,
+				Timestamp: p.Timestamp,
+			})
+		}
+	}
+	return updates
+}
+
+func TestGroupLocationUpdates(t *testing.T) {
+	now := time.Now()
+	updates := []DriverLocationUpdate{
+		{DriverID: "driver-1", City: "seattle", Lat: 47.60, Lng: -122.33, Timestamp: now},
+		{DriverID: "driver-1", City: "seattle", Lat: 47.61, Lng: -122.34, Timestamp: now.Add(time.Second)},
+		// Older update arriving late is dropped
+		{DriverID: "driver-1", City: "seattle", Lat: 47.50, Lng: -122.30, Timestamp: now.Add(-time.Second)},
+		{DriverID: "driver-2", City: "seattle", Lat: 47.62, Lng: -122.35, Timestamp: now},
+		// driver-2 crosses into another city
+		{DriverID: "driver-2", City: "bellevue", Lat: 47.61, Lng: -122.20, Timestamp: now.Add(time.Second)},
+		{DriverID: "", City: "seattle", Lat: 47.60, Lng: -122.33},
+		{DriverID: "driver-3", City: "", Lat: 47.60, Lng: -122.33},
+		{DriverID: "driver-4", City: "seattle", Lat: 91, Lng: -122.33},
+		{DriverID: "driver-5", City: "tacoma", Lat: 47.25, Lng: -122.44},
+	}
+
+	result := &BulkLocationResult{}
+	byCity := groupLocationUpdates(updates, result)
+
+	if result.Rejected != 3 {
+		t.Errorf("expected 3 rejected, got %d", result.Rejected)
+	}
+	if result.Superseded != 3 {
+		t.Errorf("expected 3 superseded, got %d", result.Superseded)
+	}
+
+	seattle := byCity["seattle"]
+	if len(seattle) != 1 || seattle[0].DriverID != "driver-1" || seattle[0].Lat != 47.61 {
+		t.Errorf("expected latest driver-1 update in seattle, got %+v", seattle)
+	}
+	bellevue := byCity["bellevue"]
+	if len(bellevue) != 1 || bellevue[0].DriverID != "driver-2" {
+		t.Errorf("expected driver-2 in bellevue, got %+v", bellevue)
+	}
+	tacoma := byCity["tacoma"]
+	if len(tacoma) != 1 || tacoma[0].Timestamp.IsZero() {
+		t.Errorf("expected driver-5 in tacoma with a timestamp, got %+v", tacoma)
+	}
+	if len(byCity) != 3 {
+		t.Errorf("expected 3 cities, got %d", len(byCity))
+	}
+}
+
+func TestGroupLocationUpdates_Fixtures(t *testing.T) {
+	batches := make([]fixtures.LocationBatchFixture, 50)
+	for i := range batches {
+		batches[i] = fixtures.NewLocationBatch(fmt.Sprintf("driver-%d", i), 10)
+	}
+	updates := locationUpdatesFromBatches(batches, "seattle", "portland")
+
+	result := &BulkLocationResult{}
+	byCity := groupLocationUpdates(updates, result)
+
+	if len(byCity["seattle"]) != 25 || len(byCity["portland"]) != 25 {
+		t.Fatalf("expected 25 drivers per city, got %d and %d", len(byCity["seattle"]), len(byCity["portland"]))
+	}
+	if result.Superseded != 450 {
+		t.Errorf("expected 450 superseded, got %d", result.Superseded)
+	}
+	last := batches[0].Points[9]
+	for _, u := range byCity["seattle"] {
+		if u.DriverID == "driver-0" && u.Lat != last.Lat {
+			t.Errorf("expected latest point kept for driver-0, got %+v", u)
+		}
+	}
+}
+
+// BenchmarkGroupLocationUpdates measures the in-memory grouping only; Redis
+// throughput is measured by BenchmarkUpdateLocations_Integration.
+func BenchmarkGroupLocationUpdates(b *testing.B) {
+	batches := make([]fixtures.LocationBatchFixture, 5000)
+	for i := range batches {
+		batches[i] = fixtures.NewLocationBatch(fmt.Sprintf("driver-%d", i), 2)
+	}
+	updates := locationUpdatesFromBatches(batches, "seattle", "portland", "denver")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		groupLocationUpdates(updates, &BulkLocationResult{})
+	}
+	b.ReportMetric(float64(len(updates))*float64(b.N)/b.Elapsed().Seconds(), "updates/s")
+}
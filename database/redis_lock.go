@@ -0,0 +1,285 @@
+// Package database provides database client utilities.
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrLockNotAcquired is returned when a lock is held by someone else.
+var ErrLockNotAcquired = fmt.Errorf("lock not acquired")
+
+// ErrLockLost is returned when a lock expired or was taken over before it
+// was extended or released.
+var ErrLockLost = errors.New("lock lost")
+
+// acquireLockScript takes the lock and issues the next fencing token.
+// KEYS: lock, fencing counter
+// ARGV: owner token, TTL (ms)
+var acquireLockScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("INCR", KEYS[2])
+end
+return 0
+`)
+
+// releaseLockScript deletes the lock if it is still held by the owner.
+// KEYS: lock
+// ARGV: owner token
+var releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// extendLockScript resets the lock TTL if it is still held by the owner.
+// KEYS: lock
+// ARGV: owner token, TTL (ms)
+var extendLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// LockOption configures AcquireLock.
+type LockOption func(*lockOptions)
+
+type lockOptions struct {
+	wait     bool
+	backoff  RetryConfig
+	watchdog bool
+}
+
+// WithLockWait blocks until the lock is acquired or ctx is done, backing off
+// between attempts as configured. MaxRetries is ignored; a zero InitialDelay,
+// Multiplier or MaxDelay takes the DefaultRetryConfig value.
+func WithLockWait(backoff RetryConfig) LockOption {
+	defaults := DefaultRetryConfig()
+	if backoff.InitialDelay <= 0 {
+		backoff.InitialDelay = defaults.InitialDelay
+	}
+	if backoff.Multiplier <= 0 {
+		backoff.Multiplier = defaults.Multiplier
+	}
+	if backoff.MaxDelay <= 0 {
+		backoff.MaxDelay = defaults.MaxDelay
+	}
+	return func(o *lockOptions) {
+		o.wait = true
+		o.backoff = backoff
+	}
+}
+
+// WithLockWatchdog extends the lock every third of its TTL until it is
+// released or the context passed to AcquireLock is done, after which it
+// expires on its own. Lost reports a lock the watchdog failed to keep.
+func WithLockWatchdog() LockOption {
+	return func(o *lockOptions) {
+		o.watchdog = true
+	}
+}
+
+// Lock represents a distributed lock.
+//
+// A lock can expire while its holder is paused, e.g. by a GC pause or a slow
+// network, so another process may acquire it before the holder notices. Each
+// acquisition gets a fencing token larger than any before it for the same
+// key: pass Token along with writes to the protected resource and have it
+// reject tokens lower than the largest it has seen.
+type Lock struct {
+	client *RedisClient
+	key    string
+	value  string
+	token  int64
+	ttl    time.Duration
+
+	lost     chan struct{}
+	lostOnce sync.Once
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	watching sync.WaitGroup
+}
+
+// AcquireLock attempts to acquire a distributed lock, returning
+// ErrLockNotAcquired if it is held by someone else. ttl must be at least a
+// millisecond: a lock without a TTL would be held forever by a holder that
+// crashed.
+func (r *RedisClient) AcquireLock(ctx context.Context, key string, ttl time.Duration, opts ...LockOption) (*Lock, error) {
+	if err := checkLockTTL(ttl); err != nil {
+		return nil, err
+	}
+	var o lockOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	for attempt := 0; ; attempt++ {
+		lock, err := r.tryAcquireLock(ctx, key, ttl)
+		if err == nil {
+			if o.watchdog {
+				lock.watching.Add(1)
+				go lock.watch(ctx)
+			}
+			return lock, nil
+		}
+		if !o.wait || !errors.Is(err, ErrLockNotAcquired) {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %w", ErrLockNotAcquired, ctx.Err())
+		case <-time.After(calculateDelay(o.backoff, min(attempt, 30))):
+		}
+	}
+}
+
+func (r *RedisClient) tryAcquireLock(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	owner := make([]byte, 16)
+	if _, err := rand.Read(owner); err != nil {
+		return nil, fmt.Errorf("failed to generate lock owner: %w", err)
+	}
+	value := hex.EncodeToString(owner)
+
+	token, err := acquireLockScript.Run(ctx, r.client, []string{key, fencingKey(key)}, value, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return nil, err
+	}
+	if token == 0 {
+		return nil, ErrLockNotAcquired
+	}
+	return &Lock{
+		client: r,
+		key:    key,
+		value:  value,
+		token:  token,
+		ttl:    ttl,
+		lost:   make(chan struct{}),
+		stop:   make(chan struct{}),
+	}, nil
+}
+
+// checkLockTTL rejects TTLs Redis cannot set with PX.
+func checkLockTTL(ttl time.Duration) error {
+	if ttl < time.Millisecond {
+		return fmt.Errorf("lock TTL must be at least 1ms, got %v", ttl)
+	}
+	return nil
+}
+
+// fencingKey returns the key of the fencing counter for a lock, on the same
+// cluster slot as the lock unless the key has braces without forming a hash
+// tag. The counter never expires, so tokens keep increasing across holders;
+// this leaves one permanent key per lock name, so lock names should come
+// from a fixed set rather than, say, one per request.
+func fencingKey(key string) string {
+	if _, ok := hashTagOf(key); ok {
+		return key + ":fencing"
+	}
+	return HashTag(key) + ":fencing"
+}
+
+// Key returns the locked key.
+func (l *Lock) Key() string {
+	return l.key
+}
+
+// Token returns the fencing token of this acquisition.
+func (l *Lock) Token() int64 {
+	return l.token
+}
+
+// Lost returns a channel that is closed once the lock is known to be lost:
+// when Extend or Release finds it taken over or expired, or when the
+// watchdog could not extend it before it expired. Work protected by the lock
+// should stop when it is closed.
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Release stops the watchdog and releases the lock. Releasing a lock that
+// is no longer held is not an error; Lost reports it instead.
+func (l *Lock) Release(ctx context.Context) error {
+	l.stopWatchdog()
+	released, err := releaseLockScript.Run(ctx, l.client.client, []string{l.key}, l.value).Int64()
+	if err != nil {
+		return err
+	}
+	if released == 0 {
+		l.markLost()
+	}
+	return nil
+}
+
+// Extend resets the lock TTL. Returns ErrLockLost if the lock is no longer
+// held.
+func (l *Lock) Extend(ctx context.Context, ttl time.Duration) error {
+	if err := checkLockTTL(ttl); err != nil {
+		return err
+	}
+	extended, err := extendLockScript.Run(ctx, l.client.client, []string{l.key}, l.value, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if extended == 0 {
+		l.markLost()
+		return ErrLockLost
+	}
+	return nil
+}
+
+// watch extends the lock until it is released or ctx is done. Extensions
+// failing on errors are retried on the next tick until the lock expires.
+func (l *Lock) watch(ctx context.Context) {
+	defer l.watching.Done()
+
+	interval := l.ttl / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	expiresAt := time.Now().Add(l.ttl)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+
+		start := time.Now()
+		extendCtx, cancel := context.WithTimeout(ctx, interval)
+		err := l.Extend(extendCtx, l.ttl)
+		cancel()
+		switch {
+		case err == nil:
+			expiresAt = start.Add(l.ttl)
+		case errors.Is(err, ErrLockLost):
+			return
+		case ctx.Err() != nil:
+			return
+		case !time.Now().Before(expiresAt):
+			l.markLost()
+			return
+		}
+	}
+}
+
+func (l *Lock) stopWatchdog() {
+	l.stopOnce.Do(func() { close(l.stop) })
+	l.watching.Wait()
+}
+
+func (l *Lock) markLost() {
+	l.lostOnce.Do(func() { close(l.lost) })
+}
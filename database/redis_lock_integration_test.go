@@ -0,0 +1,115 @@
+//go:build integration
+
+// Package database provides database client utilities.
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLock_Integration(t *testing.T) {
+	ctx, client := newIntegrationRedisClient(t)
+
+	t.Run("FencingTokens", func(t *testing.T) {
+		first, err := client.AcquireLock(ctx, "lock:fencing", time.Second)
+		if err != nil {
+			t.Fatalf("AcquireLock failed: %v", err)
+		}
+		if err := first.Release(ctx); err != nil {
+			t.Fatalf("Release failed: %v", err)
+		}
+		second, err := client.AcquireLock(ctx, "lock:fencing", time.Second)
+		if err != nil {
+			t.Fatalf("AcquireLock failed: %v", err)
+		}
+		defer second.Release(ctx)
+
+		if second.Token() <= first.Token() {
+			t.Errorf("expected increasing tokens, got %d then %d", first.Token(), second.Token())
+		}
+		if second.value == first.value {
+			t.Error("expected a unique owner per acquisition")
+		}
+	})
+
+	t.Run("ReleaseByOtherOwner", func(t *testing.T) {
+		lock, err := client.AcquireLock(ctx, "lock:expired", 50*time.Millisecond)
+		if err != nil {
+			t.Fatalf("AcquireLock failed: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+		other, err := client.AcquireLock(ctx, "lock:expired", time.Second)
+		if err != nil {
+			t.Fatalf("AcquireLock after expiry failed: %v", err)
+		}
+		defer other.Release(ctx)
+
+		if err := lock.Release(ctx); err != nil {
+			t.Errorf("Release failed: %v", err)
+		}
+		select {
+		case <-lock.Lost():
+		default:
+			t.Error("expected the lock to be reported lost")
+		}
+		if exists, _ := client.Exists(ctx, "lock:expired"); exists != 1 {
+			t.Error("releasing an expired lock must not release the new owner's")
+		}
+	})
+
+	t.Run("Watchdog", func(t *testing.T) {
+		holderCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		lock, err := client.AcquireLock(holderCtx, "lock:watchdog", 300*time.Millisecond, WithLockWatchdog())
+		if err != nil {
+			t.Fatalf("AcquireLock failed: %v", err)
+		}
+
+		time.Sleep(time.Second)
+		if _, err := client.AcquireLock(ctx, "lock:watchdog", time.Second); !errors.Is(err, ErrLockNotAcquired) {
+			t.Fatalf("expected the watchdog to keep the lock, got %v", err)
+		}
+
+		// Losing the lock is reported
+		if err := client.Delete(ctx, "lock:watchdog"); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		select {
+		case <-lock.Lost():
+		case <-time.After(time.Second):
+			t.Fatal("expected the lock to be reported lost")
+		}
+	})
+
+	t.Run("WaitForLock", func(t *testing.T) {
+		held, err := client.AcquireLock(ctx, "lock:wait", 5*time.Second)
+		if err != nil {
+			t.Fatalf("AcquireLock failed: %v", err)
+		}
+		go func() {
+			time.Sleep(200 * time.Millisecond)
+			held.Release(ctx)
+		}()
+
+		backoff := RetryConfig{InitialDelay: 20 * time.Millisecond, MaxDelay: 100 * time.Millisecond, Multiplier: 2}
+		lock, err := client.AcquireLock(ctx, "lock:wait", time.Second, WithLockWait(backoff))
+		if err != nil {
+			t.Fatalf("waiting AcquireLock failed: %v", err)
+		}
+		lock.Release(ctx)
+
+		waitCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		blocker, err := client.AcquireLock(ctx, "lock:wait", 5*time.Second)
+		if err != nil {
+			t.Fatalf("AcquireLock failed: %v", err)
+		}
+		defer blocker.Release(ctx)
+		if _, err := client.AcquireLock(waitCtx, "lock:wait", time.Second, WithLockWait(backoff)); !errors.Is(err, ErrLockNotAcquired) {
+			t.Errorf("expected ErrLockNotAcquired once the context is done, got %v", err)
+		}
+	})
+}
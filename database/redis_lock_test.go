@@ -0,0 +1,36 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAcquireLock_InvalidTTL(t *testing.T) {
+	// Rejected before Redis is reached
+	client := &RedisClient{}
+	for _, ttl := range []time.Duration{0, -time.Second, 500 * time.Microsecond} {
+		if _, err := client.AcquireLock(context.Background(), "lock:sweeper", ttl, WithLockWatchdog()); err == nil {
+			t.Errorf("expected an error for TTL %v", ttl)
+		}
+	}
+
+	lock := &Lock{client: client, key: "lock:sweeper"}
+	if err := lock.Extend(context.Background(), 0); err == nil {
+		t.Error("expected an error extending by 0")
+	}
+}
+
+func TestWithLockWait_DefaultsZeroBackoff(t *testing.T) {
+	var o lockOptions
+	WithLockWait(RetryConfig{})(&o)
+	defaults := DefaultRetryConfig()
+	if o.backoff.InitialDelay != defaults.InitialDelay || o.backoff.Multiplier != defaults.Multiplier || o.backoff.MaxDelay != defaults.MaxDelay {
+		t.Errorf("expected the default backoff, got %+v", o.backoff)
+	}
+
+	WithLockWait(RetryConfig{InitialDelay: time.Millisecond, Multiplier: 1.5, MaxDelay: time.Second})(&o)
+	if o.backoff.InitialDelay != time.Millisecond || o.backoff.Multiplier != 1.5 || o.backoff.MaxDelay != time.Second {
+		t.Errorf("expected the configured backoff kept, got %+v", o.backoff)
+	}
+}
@@ -0,0 +1,449 @@
+// Package database provides database client utilities.
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Offer statuses.
+const (
+	OfferStatusPending  = "pending"
+	OfferStatusAccepted = "accepted"
+	OfferStatusDeclined = "declined"
+	OfferStatusExpired  = "expired"
+)
+
+// OfferResult is the outcome of an offer transition.
+type OfferResult string
+
+const (
+	// OfferResultCreated means the offer was created and the driver reserved.
+	OfferResultCreated OfferResult = "created"
+	// OfferResultDriverBusy means the driver already has a pending offer.
+	OfferResultDriverBusy OfferResult = "driver_busy"
+	// OfferResultAccepted means the driver accepted the offer.
+	OfferResultAccepted OfferResult = "accepted"
+	// OfferResultDeclined means the driver declined the offer.
+	OfferResultDeclined OfferResult = "declined"
+	// OfferResultExpired means the offer expired before the transition.
+	OfferResultExpired OfferResult = "expired"
+	// OfferResultAlreadyResolved means the offer was already accepted, declined or expired.
+	OfferResultAlreadyResolved OfferResult = "already_resolved"
+	// OfferResultWrongDriver means the offer belongs to another driver.
+	OfferResultWrongDriver OfferResult = "wrong_driver"
+	// OfferResultNotFound means the offer does not exist (or its record expired).
+	OfferResultNotFound OfferResult = "not_found"
+)
+
+// OfferOutcome describes the result of an offer operation.
+type OfferOutcome struct {
+	Result OfferResult `json:"result"`
+	// Offer is the offer after the operation, when it exists.
+	Offer *OfferData `json:"offer,omitempty"`
+	// PendingOfferID is the driver's existing offer when Result is OfferResultDriverBusy.
+	PendingOfferID string `json:"pending_offer_id,omitempty"`
+}
+
+// OK reports whether the requested transition happened.
+func (o *OfferOutcome) OK() bool {
+	switch o.Result {
+	case OfferResultCreated, OfferResultAccepted, OfferResultDeclined:
+		return true
+	}
+	return false
+}
+
+// createOfferScript creates an offer only if the driver has no pending offer.
+// KEYS: pending offer, offer, expiry set
+// ARGV: offer ID, offer JSON, driver ID, request ID, expires at (ms), offer TTL (ms), retention (ms)
+var createOfferScript = redis.NewScript(`
+local current = redis.call("GET", KEYS[1])
+if current then
+	return {0, current}
+end
+redis.call("HSET", KEYS[2], "status", "pending", "data", ARGV[2], "driver_id", ARGV[3], "request_id", ARGV[4], "expires_at", ARGV[5])
+redis.call("PEXPIRE", KEYS[2], tonumber(ARGV[6]) + tonumber(ARGV[7]))
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[6])
+redis.call("ZADD", KEYS[3], ARGV[5], ARGV[1])
+return {1, ARGV[1]}
+`)
+
+// resolveOfferScript moves a pending offer to a final status and returns it
+// with the offer's driver. Pending offers past their expiry are always moved
+// to expired.
+// KEYS: offer, pending offer, expiry set
+// ARGV: offer ID, driver ID (empty skips the check), status, now (ms)
+var resolveOfferScript = redis.NewScript(`
+local offer = redis.call("HMGET", KEYS[1], "status", "driver_id", "expires_at")
+if not offer[1] then
+	return {"not_found", ""}
+end
+if ARGV[2] ~= "" and offer[2] ~= ARGV[2] then
+	return {"wrong_driver", ""}
+end
+if offer[1] ~= "pending" then
+	return {"already_resolved", offer[2]}
+end
+
+local status = ARGV[3]
+if tonumber(offer[3]) <= tonumber(ARGV[4]) then
+	status = "expired"
+elseif status == "expired" then
+	return {"not_due", offer[2]}
+end
+
+redis.call("HSET", KEYS[1], "status", status, "resolved_at", ARGV[4])
+if redis.call("GET", KEYS[2]) == ARGV[1] then
+	redis.call("DEL", KEYS[2])
+end
+redis.call("ZREM", KEYS[3], ARGV[1])
+return {status, offer[2]}
+`)
+
+// In a cluster the pending offer, offer and expiry set are on different
+// slots, so each step below touches a single key.
+
+// reserveOfferScript reserves a driver for an offer unless they already
+// have a pending one.
+// KEYS: pending offer
+// ARGV: offer ID, offer TTL (ms)
+var reserveOfferScript = redis.NewScript(`
+local current = redis.call("GET", KEYS[1])
+if current then
+	return {0, current}
+end
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+return {1, ARGV[1]}
+`)
+
+// releaseOfferScript clears a driver's pending offer if it is still the
+// given one.
+// KEYS: pending offer
+// ARGV: offer ID
+var releaseOfferScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// resolveOfferStatusScript moves a pending offer to a final status like
+// resolveOfferScript, leaving the pending offer and expiry set to the caller.
+// KEYS: offer
+// ARGV: driver ID (empty skips the check), status, now (ms)
+var resolveOfferStatusScript = redis.NewScript(`
+local offer = redis.call("HMGET", KEYS[1], "status", "driver_id", "expires_at")
+if not offer[1] then
+	return {"not_found", ""}
+end
+if ARGV[1] ~= "" and offer[2] ~= ARGV[1] then
+	return {"wrong_driver", ""}
+end
+if offer[1] ~= "pending" then
+	return {"already_resolved", offer[2]}
+end
+
+local status = ARGV[2]
+if tonumber(offer[3]) <= tonumber(ARGV[3]) then
+	status = "expired"
+elseif status == "expired" then
+	return {"not_due", offer[2]}
+end
+
+redis.call("HSET", KEYS[1], "status", status, "resolved_at", ARGV[3])
+return {status, offer[2]}
+`)
+
+// OfferManager manages the driver offer lifecycle with atomic Lua scripts,
+// so a driver holds at most one pending offer and late responses are rejected.
+//
+// In a cluster, creating and resolving an offer take several steps: a failed
+// create releases the driver again, and a resolved offer whose cleanup failed
+// keeps the driver reserved until it would have expired.
+type OfferManager struct {
+	client *RedisClient
+	ttl    time.Duration
+}
+
+// NewOfferManager creates a new offer manager using RedisTTLs.DriverOffer.
+func NewOfferManager(client *RedisClient) *OfferManager {
+	return &OfferManager{client: client, ttl: RedisTTLs.DriverOffer}
+}
+
+// WithTTL overrides the offer expiry.
+func (m *OfferManager) WithTTL(ttl time.Duration) *OfferManager {
+	m.ttl = ttl
+	return m
+}
+
+// Create creates a pending offer if the driver has none.
+// CreatedAt and ExpiresAt are set from the manager's TTL when zero.
+func (m *OfferManager) Create(ctx context.Context, offer *OfferData) (*OfferOutcome, error) {
+	if offer.OfferID == "" || offer.DriverID == "" {
+		return nil, fmt.Errorf("offer ID and driver ID are required")
+	}
+
+	now := time.Now().UTC()
+	if offer.CreatedAt.IsZero() {
+		offer.CreatedAt = now
+	}
+	if offer.ExpiresAt.IsZero() {
+		offer.ExpiresAt = offer.CreatedAt.Add(m.ttl)
+	}
+	ttl := offer.ExpiresAt.Sub(now)
+	if ttl <= 0 {
+		return nil, fmt.Errorf("offer %s already expired", offer.OfferID)
+	}
+	offer.Status = OfferStatusPending
+
+	data, err := json.Marshal(offer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal offer: %w", err)
+	}
+
+	if m.client.Mode() == RedisModeCluster {
+		return m.createInSteps(ctx, offer, data, ttl)
+	}
+
+	keys := []string{
+		m.client.Key(RedisKeyPatterns.DriverPendingOffer, offer.DriverID),
+		m.client.Key(RedisKeyPatterns.DriverOffer, offer.OfferID),
+		RedisKeyPatterns.OfferExpiry,
+	}
+	res, err := createOfferScript.Run(ctx, m.client.client, keys,
+		offer.OfferID, data, offer.DriverID, offer.RequestID,
+		offer.ExpiresAt.UnixMilli(), ttl.Milliseconds(), RedisTTLs.OfferRetention.Milliseconds(),
+	).Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create offer: %w", err)
+	}
+
+	if created, _ := res[0].(int64); created == 0 {
+		pending, _ := res[1].(string)
+		return &OfferOutcome{Result: OfferResultDriverBusy, PendingOfferID: pending}, nil
+	}
+	return &OfferOutcome{Result: OfferResultCreated, Offer: offer}, nil
+}
+
+// createInSteps reserves the driver, then writes the offer and its expiry.
+func (m *OfferManager) createInSteps(ctx context.Context, offer *OfferData, data []byte, ttl time.Duration) (*OfferOutcome, error) {
+	pendingKey := m.client.Key(RedisKeyPatterns.DriverPendingOffer, offer.DriverID)
+	res, err := reserveOfferScript.Run(ctx, m.client.client, []string{pendingKey},
+		offer.OfferID, ttl.Milliseconds(),
+	).Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create offer: %w", err)
+	}
+	if reserved, _ := res[0].(int64); reserved == 0 {
+		pending, _ := res[1].(string)
+		return &OfferOutcome{Result: OfferResultDriverBusy, PendingOfferID: pending}, nil
+	}
+
+	offerKey := m.client.Key(RedisKeyPatterns.DriverOffer, offer.OfferID)
+	pipe := m.client.client.TxPipeline()
+	pipe.HSet(ctx, offerKey,
+		"status", OfferStatusPending,
+		"data", data,
+		"driver_id", offer.DriverID,
+		"request_id", offer.RequestID,
+		"expires_at", offer.ExpiresAt.UnixMilli(),
+	)
+	pipe.PExpire(ctx, offerKey, ttl+RedisTTLs.OfferRetention)
+	pipe.ZAdd(ctx, RedisKeyPatterns.OfferExpiry, redis.Z{Score: float64(offer.ExpiresAt.UnixMilli()), Member: offer.OfferID})
+	if _, err := pipe.Exec(ctx); err != nil {
+		// Free the driver rather than leave them reserved for a missing offer
+		_ = releaseOfferScript.Run(ctx, m.client.client, []string{pendingKey}, offer.OfferID).Err()
+		return nil, fmt.Errorf("failed to create offer: %w", err)
+	}
+	return &OfferOutcome{Result: OfferResultCreated, Offer: offer}, nil
+}
+
+// Accept accepts a pending offer on behalf of the driver.
+func (m *OfferManager) Accept(ctx context.Context, offerID, driverID string) (*OfferOutcome, error) {
+	return m.resolve(ctx, offerID, driverID, OfferStatusAccepted)
+}
+
+// Decline declines a pending offer on behalf of the driver.
+func (m *OfferManager) Decline(ctx context.Context, offerID, driverID string) (*OfferOutcome, error) {
+	return m.resolve(ctx, offerID, driverID, OfferStatusDeclined)
+}
+
+// Expire expires a pending offer whose expiry has passed.
+// Returns a nil outcome if the offer is not due yet.
+func (m *OfferManager) Expire(ctx context.Context, offerID string) (*OfferOutcome, error) {
+	return m.resolve(ctx, offerID, "", OfferStatusExpired)
+}
+
+// Get returns an offer and its current status.
+// Returns ErrKeyNotFound if the offer does not exist.
+func (m *OfferManager) Get(ctx context.Context, offerID string) (*OfferData, error) {
+	fields, err := m.client.HGetAll(ctx, m.client.Key(RedisKeyPatterns.DriverOffer, offerID))
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, ErrKeyNotFound
+	}
+
+	var offer OfferData
+	if err := json.Unmarshal([]byte(fields["data"]), &offer); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal offer: %w", err)
+	}
+	offer.Status = fields["status"]
+	return &offer, nil
+}
+
+// PendingOffer returns the ID of the driver's pending offer, or "" if none.
+func (m *OfferManager) PendingOffer(ctx context.Context, driverID string) (string, error) {
+	id, err := m.client.Get(ctx, m.client.Key(RedisKeyPatterns.DriverPendingOffer, driverID))
+	if errors.Is(err, ErrKeyNotFound) {
+		return "", nil
+	}
+	return id, err
+}
+
+func (m *OfferManager) resolve(ctx context.Context, offerID, driverID, status string) (*OfferOutcome, error) {
+	offerKey := m.client.Key(RedisKeyPatterns.DriverOffer, offerID)
+	cluster := m.client.Mode() == RedisModeCluster
+	var res []string
+	var err error
+	if cluster {
+		res, err = resolveOfferStatusScript.Run(ctx, m.client.client, []string{offerKey},
+			driverID, status, time.Now().UnixMilli(),
+		).StringSlice()
+	} else {
+		// The pending key is declared up front, so it is looked up when
+		// expiring an offer on behalf of no particular driver
+		owner := driverID
+		if owner == "" {
+			owner, err = m.client.HGet(ctx, offerKey, "driver_id")
+			if errors.Is(err, ErrKeyNotFound) {
+				return &OfferOutcome{Result: OfferResultNotFound}, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		keys := []string{offerKey, m.client.Key(RedisKeyPatterns.DriverPendingOffer, owner), RedisKeyPatterns.OfferExpiry}
+		res, err = resolveOfferScript.Run(ctx, m.client.client, keys,
+			offerID, driverID, status, time.Now().UnixMilli(),
+		).StringSlice()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s offer: %w", offerAction(status), err)
+	}
+	if len(res) != 2 {
+		return nil, fmt.Errorf("unexpected offer script result %v", res)
+	}
+
+	if cluster {
+		switch res[0] {
+		case OfferStatusAccepted, OfferStatusDeclined, OfferStatusExpired:
+			// The offer is resolved; failing to clean up only leaves the
+			// driver reserved until the offer would have expired, and the
+			// index entry to the sweeper.
+			pendingKey := m.client.Key(RedisKeyPatterns.DriverPendingOffer, res[1])
+			_ = releaseOfferScript.Run(ctx, m.client.client, []string{pendingKey}, offerID).Err()
+			_ = m.client.ZRem(ctx, RedisKeyPatterns.OfferExpiry, offerID)
+		}
+	}
+
+	var result OfferResult
+	switch res[0] {
+	case "not_due":
+		return nil, nil
+	case "not_found":
+		return &OfferOutcome{Result: OfferResultNotFound}, nil
+	case "wrong_driver":
+		return &OfferOutcome{Result: OfferResultWrongDriver}, nil
+	case "already_resolved":
+		result = OfferResultAlreadyResolved
+	case OfferStatusAccepted:
+		result = OfferResultAccepted
+	case OfferStatusDeclined:
+		result = OfferResultDeclined
+	case OfferStatusExpired:
+		result = OfferResultExpired
+	default:
+		return nil, fmt.Errorf("unexpected offer script result %q", res[0])
+	}
+
+	offer, err := m.Get(ctx, offerID)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return nil, err
+	}
+	return &OfferOutcome{Result: result, Offer: offer}, nil
+}
+
+func offerAction(status string) string {
+	switch status {
+	case OfferStatusAccepted:
+		return "accept"
+	case OfferStatusDeclined:
+		return "decline"
+	default:
+		return "expire"
+	}
+}
+
+// SweepExpired expires up to limit offers that are past due and returns them.
+func (m *OfferManager) SweepExpired(ctx context.Context, limit int64) ([]*OfferData, error) {
+	ids, err := m.client.ZRangeByScore(ctx, RedisKeyPatterns.OfferExpiry, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(time.Now().UnixMilli(), 10),
+		Count: limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired offers: %w", err)
+	}
+
+	var expired []*OfferData
+	for _, id := range ids {
+		outcome, err := m.Expire(ctx, id)
+		if err != nil {
+			return expired, err
+		}
+		if outcome == nil {
+			continue
+		}
+		if outcome.Result == OfferResultExpired && outcome.Offer != nil {
+			expired = append(expired, outcome.Offer)
+			continue
+		}
+		// Resolved concurrently or record gone: just drop it from the index
+		if err := m.client.ZRem(ctx, RedisKeyPatterns.OfferExpiry, id); err != nil {
+			return expired, err
+		}
+	}
+	return expired, nil
+}
+
+// RunExpirySweeper sweeps expired offers every interval until ctx is done,
+// calling onExpired for each offer it expires. Several replicas may run the
+// sweeper; each offer is expired exactly once.
+func (m *OfferManager) RunExpirySweeper(ctx context.Context, interval time.Duration, onExpired func(ctx context.Context, offer *OfferData)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			// Transient errors are retried on the next tick
+			expired, _ := m.SweepExpired(ctx, 100)
+			if onExpired != nil {
+				for _, offer := range expired {
+					onExpired(ctx, offer)
+				}
+			}
+		}
+	}
+}
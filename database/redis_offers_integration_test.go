@@ -0,0 +1,151 @@
+//go:build integration
+
+// Package database provides database client utilities.
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pkgtesting "github.com/mycobrun/cobrun-shared/testing"
+)
+
+// newIntegrationRedisClient starts a Redis container and returns a client for it.
+func newIntegrationRedisClient(t testing.TB) (context.Context, *RedisClient) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	// Benchmarks run longer than pkgtesting.TestContext allows
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	t.Cleanup(cancel)
+
+	container, err := pkgtesting.StartRedisContainer(ctx)
+	if err != nil {
+		t.Fatalf("failed to start Redis container: %v", err)
+	}
+	t.Cleanup(pkgtesting.CleanupContainer(ctx, container))
+
+	host, err := container.Host(ctx)
+	if err != nil {
+		t.Fatalf("failed to get container host: %v", err)
+	}
+	port, err := container.MappedPort(ctx, "6379")
+	if err != nil {
+		t.Fatalf("failed to get container port: %v", err)
+	}
+
+	client, err := NewRedisClient(ctx, RedisConfig{
+		Host:       host,
+		Port:       port.Int(),
+		TLSEnabled: false,
+		PoolSize:   10,
+	})
+	if err != nil {
+		t.Fatalf("failed to create Redis client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return ctx, client
+}
+
+func TestOfferManager_Integration(t *testing.T) {
+	ctx, client := newIntegrationRedisClient(t)
+	manager := NewOfferManager(client)
+
+	t.Run("OneOfferPerDriver", func(t *testing.T) {
+		first, err := manager.Create(ctx, &OfferData{OfferID: "offer-1", RequestID: "req-1", DriverID: "driver-1", Fare: 12.5})
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if first.Result != OfferResultCreated {
+			t.Fatalf("expected created, got %s", first.Result)
+		}
+
+		second, err := manager.Create(ctx, &OfferData{OfferID: "offer-2", RequestID: "req-2", DriverID: "driver-1"})
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if second.Result != OfferResultDriverBusy || second.PendingOfferID != "offer-1" {
+			t.Errorf("expected driver busy with offer-1, got %+v", second)
+		}
+	})
+
+	t.Run("AcceptOnce", func(t *testing.T) {
+		wrong, err := manager.Accept(ctx, "offer-1", "driver-2")
+		if err != nil {
+			t.Fatalf("Accept failed: %v", err)
+		}
+		if wrong.Result != OfferResultWrongDriver {
+			t.Errorf("expected wrong driver, got %s", wrong.Result)
+		}
+
+		accepted, err := manager.Accept(ctx, "offer-1", "driver-1")
+		if err != nil {
+			t.Fatalf("Accept failed: %v", err)
+		}
+		if accepted.Result != OfferResultAccepted || accepted.Offer.Status != OfferStatusAccepted || accepted.Offer.Fare != 12.5 {
+			t.Errorf("expected accepted offer, got %+v", accepted)
+		}
+
+		again, err := manager.Decline(ctx, "offer-1", "driver-1")
+		if err != nil {
+			t.Fatalf("Decline failed: %v", err)
+		}
+		if again.Result != OfferResultAlreadyResolved {
+			t.Errorf("expected already resolved, got %s", again.Result)
+		}
+
+		pending, _ := manager.PendingOffer(ctx, "driver-1")
+		if pending != "" {
+			t.Errorf("expected no pending offer after accept, got %q", pending)
+		}
+	})
+
+	t.Run("LateAcceptExpires", func(t *testing.T) {
+		short := NewOfferManager(client).WithTTL(50 * time.Millisecond)
+		if _, err := short.Create(ctx, &OfferData{OfferID: "offer-3", DriverID: "driver-3"}); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+
+		late, err := short.Accept(ctx, "offer-3", "driver-3")
+		if err != nil {
+			t.Fatalf("Accept failed: %v", err)
+		}
+		if late.Result != OfferResultExpired {
+			t.Errorf("expected expired, got %s", late.Result)
+		}
+	})
+
+	t.Run("Sweeper", func(t *testing.T) {
+		short := NewOfferManager(client).WithTTL(50 * time.Millisecond)
+		if _, err := short.Create(ctx, &OfferData{OfferID: "offer-4", DriverID: "driver-4"}); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+
+		if outcome, err := short.Expire(ctx, "offer-4"); err != nil || outcome != nil {
+			t.Fatalf("expected offer not due yet, got %+v, %v", outcome, err)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+		expired, err := short.SweepExpired(ctx, 10)
+		if err != nil {
+			t.Fatalf("SweepExpired failed: %v", err)
+		}
+		if len(expired) != 1 || expired[0].OfferID != "offer-4" || expired[0].Status != OfferStatusExpired {
+			t.Fatalf("expected offer-4 expired, got %+v", expired)
+		}
+
+		// The driver can be offered again
+		next, err := short.Create(ctx, &OfferData{OfferID: "offer-5", DriverID: "driver-4"})
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if next.Result != OfferResultCreated {
+			t.Errorf("expected created, got %s", next.Result)
+		}
+	})
+}
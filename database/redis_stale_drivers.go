@@ -0,0 +1,217 @@
+// Package database provides database client utilities.
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// evictStaleDriversScript removes drivers that are still stale, so a location
+// update racing with the sweep is never lost.
+// KEYS: geo set, last-seen set, H3 cell hash
+// ARGV: cutoff (ms), driver IDs...
+var evictStaleDriversScript = redis.NewScript(`
+local removed = {}
+local cutoff = tonumber(ARGV[1])
+for i = 2, #ARGV do
+	local score = redis.call("ZSCORE", KEYS[2], ARGV[i])
+	if not score or tonumber(score) <= cutoff then
+		redis.call("ZREM", KEYS[1], ARGV[i])
+		redis.call("ZREM", KEYS[2], ARGV[i])
+		redis.call("HDEL", KEYS[3], ARGV[i])
+		table.insert(removed, ARGV[i])
+	end
+end
+return removed
+`)
+
+// StaleDriverSweeperConfig holds stale driver sweeper configuration.
+type StaleDriverSweeperConfig struct {
+	// Cities are the cities whose geo sets are swept.
+	Cities []string
+	// StaleAfter is how long after the last location update a driver is evicted.
+	StaleAfter time.Duration
+	// Interval is the time between sweeps.
+	Interval time.Duration
+	// BatchSize limits the drivers evicted per script call.
+	BatchSize int64
+	// LockKey keeps replicas from sweeping at the same time; the lock is
+	// held for one sweep and released after it.
+	LockKey string
+}
+
+// DefaultStaleDriverSweeperConfig returns sensible defaults.
+func DefaultStaleDriverSweeperConfig(cities ...string) StaleDriverSweeperConfig {
+	return StaleDriverSweeperConfig{
+		Cities:     cities,
+		StaleAfter: RedisTTLs.DriverLocation,
+		Interval:   10 * time.Second,
+		BatchSize:  500,
+		LockKey:    "lock:stale_driver_sweeper",
+	}
+}
+
+// StaleDriverSweeper evicts drivers that stopped reporting their location
+// from the GEO sets, since GEO members cannot expire.
+type StaleDriverSweeper struct {
+	client       *RedisClient
+	config       StaleDriverSweeperConfig
+	availability *DriverAvailability
+	onEvicted    func(ctx context.Context, city string, driverIDs []string)
+}
+
+// NewStaleDriverSweeper creates a new stale driver sweeper.
+func NewStaleDriverSweeper(client *RedisClient, config StaleDriverSweeperConfig) *StaleDriverSweeper {
+	return &StaleDriverSweeper{client: client, config: config}
+}
+
+// WithAvailability moves evicted drivers offline. Drivers en route or on a
+// trip keep their state; only their stale position is removed.
+func (s *StaleDriverSweeper) WithAvailability(availability *DriverAvailability) *StaleDriverSweeper {
+	s.availability = availability
+	return s
+}
+
+// OnEvicted registers a callback for each evicted batch.
+func (s *StaleDriverSweeper) OnEvicted(fn func(ctx context.Context, city string, driverIDs []string)) *StaleDriverSweeper {
+	s.onEvicted = fn
+	return s
+}
+
+// Sweep evicts stale drivers in a city and returns how many were removed.
+// Drivers in the geo set without a last-seen time, e.g. added before it was
+// tracked, are evicted too.
+func (s *StaleDriverSweeper) Sweep(ctx context.Context, city string) (int, error) {
+	geoKey := s.client.Key(RedisKeyPatterns.DriverLocations, city)
+	lastSeenKey := s.client.Key(RedisKeyPatterns.DriverLastSeen, city)
+	cutoff := time.Now().Add(-s.config.StaleAfter).UnixMilli()
+
+	batch := s.config.BatchSize
+	if batch <= 0 {
+		batch = 500
+	}
+
+	total := 0
+	for {
+		ids, err := s.client.ZRangeByScore(ctx, lastSeenKey, &redis.ZRangeBy{
+			Min:   "-inf",
+			Max:   strconv.FormatInt(cutoff, 10),
+			Count: batch,
+		})
+		if err != nil {
+			return total, fmt.Errorf("failed to list stale drivers: %w", err)
+		}
+		if len(ids) == 0 {
+			break
+		}
+
+		removed, err := s.evict(ctx, city, cutoff, ids)
+		total += removed
+		if err != nil {
+			return total, err
+		}
+		if int64(len(ids)) < batch {
+			break
+		}
+	}
+
+	// Both sets share the city's hash tag, so ZDIFF works on a cluster too
+	untracked, err := s.client.client.ZDiff(ctx, geoKey, lastSeenKey).Result()
+	if err != nil {
+		return total, fmt.Errorf("failed to list untracked drivers: %w", err)
+	}
+	for i := 0; i < len(untracked); i += int(batch) {
+		end := min(i+int(batch), len(untracked))
+		removed, err := s.evict(ctx, city, cutoff, untracked[i:end])
+		total += removed
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// evict removes the given drivers if they are still stale or untracked.
+func (s *StaleDriverSweeper) evict(ctx context.Context, city string, cutoff int64, ids []string) (int, error) {
+	keys := []string{
+		s.client.Key(RedisKeyPatterns.DriverLocations, city),
+		s.client.Key(RedisKeyPatterns.DriverLastSeen, city),
+		s.client.Key(RedisKeyPatterns.DriverH3Cells, city),
+	}
+	args := make([]interface{}, 0, len(ids)+1)
+	args = append(args, cutoff)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	removed, err := evictStaleDriversScript.Run(ctx, s.client.client, keys, args...).StringSlice()
+	if err != nil {
+		return 0, fmt.Errorf("failed to evict stale drivers: %w", err)
+	}
+	if len(removed) > 0 {
+		s.evicted(ctx, city, removed)
+	}
+	return len(removed), nil
+}
+
+func (s *StaleDriverSweeper) evicted(ctx context.Context, city string, driverIDs []string) {
+	if s.availability != nil {
+		for _, id := range driverIDs {
+			_, err := s.availability.GoOffline(ctx, id, city, "stale_location")
+			if err != nil && !errors.Is(err, ErrInvalidDriverTransition) {
+				log.Printf("failed to move stale driver %s offline: %v", id, err)
+			}
+		}
+	}
+	if s.onEvicted != nil {
+		s.onEvicted(ctx, city, driverIDs)
+	}
+}
+
+// SweepAll sweeps every configured city if this replica wins the sweep lock,
+// and releases it afterwards. Returns false if another replica is sweeping.
+func (s *StaleDriverSweeper) SweepAll(ctx context.Context) (bool, error) {
+	lock, err := s.client.AcquireLock(ctx, s.config.LockKey, s.config.Interval, WithLockWatchdog())
+	if errors.Is(err, ErrLockNotAcquired) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire sweeper lock: %w", err)
+	}
+	defer func() {
+		if err := lock.Release(context.WithoutCancel(ctx)); err != nil {
+			log.Printf("failed to release sweeper lock: %v", err)
+		}
+	}()
+
+	for _, city := range s.config.Cities {
+		if _, err := s.Sweep(ctx, city); err != nil {
+			return true, fmt.Errorf("city %s: %w", city, err)
+		}
+	}
+	return true, nil
+}
+
+// Run sweeps on the configured interval until ctx is done. Run it on one
+// replica by starting it from LeaderElection.OnElected; the sweep lock only
+// keeps sweeps from overlapping.
+func (s *StaleDriverSweeper) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if _, err := s.SweepAll(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("stale driver sweep failed: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+//go:build integration
+
+// Package database provides database client utilities.
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mycobrun/cobrun-shared/geo"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestStaleDriverSweeper_Integration(t *testing.T) {
+	ctx, client := newIntegrationRedisClient(t)
+	locations := NewDriverLocationService(client)
+	availability := NewDriverAvailability(client)
+	city := "denver"
+
+	for _, id := range []string{"fresh", "ghost"} {
+		if err := locations.SetDriverOnline(ctx, id, city); err != nil {
+			t.Fatalf("SetDriverOnline failed: %v", err)
+		}
+	}
+	if err := locations.UpdateLocation(ctx, "fresh", city, 39.7392, -104.9903); err != nil {
+		t.Fatalf("UpdateLocation failed: %v", err)
+	}
+	if err := locations.UpdateLocation(ctx, "ghost", city, 39.7393, -104.9904); err != nil {
+		t.Fatalf("UpdateLocation failed: %v", err)
+	}
+	// The ghost's app crashed a minute ago
+	old := float64(time.Now().Add(-time.Minute).UnixMilli())
+	if err := client.ZAdd(ctx, fmt.Sprintf(RedisKeyPatterns.DriverLastSeen, city), redis.Z{Score: old, Member: "ghost"}); err != nil {
+		t.Fatalf("ZAdd failed: %v", err)
+	}
+
+	t.Run("FilteredOnRead", func(t *testing.T) {
+		nearby, err := locations.GetNearbyDrivers(ctx, city, 39.7392, -104.9903, 1)
+		if err != nil {
+			t.Fatalf("GetNearbyDrivers failed: %v", err)
+		}
+		if len(nearby) != 1 || nearby[0].Name != "fresh" {
+			t.Errorf("expected only the fresh driver, got %+v", nearby)
+		}
+	})
+
+	t.Run("Sweep", func(t *testing.T) {
+		// A driver added to the geo set without a last-seen time
+		untracked := &redis.GeoLocation{Name: "untracked", Latitude: 39.7394, Longitude: -104.9905}
+		if err := client.GeoAdd(ctx, fmt.Sprintf(RedisKeyPatterns.DriverLocations, city), untracked); err != nil {
+			t.Fatalf("GeoAdd failed: %v", err)
+		}
+
+		var evicted []string
+		sweeper := NewStaleDriverSweeper(client, DefaultStaleDriverSweeperConfig(city)).
+			OnEvicted(func(_ context.Context, _ string, ids []string) { evicted = append(evicted, ids...) })
+
+		swept, err := sweeper.SweepAll(ctx)
+		if err != nil || !swept {
+			t.Fatalf("expected sweep to run, got %v, %v", swept, err)
+		}
+		if len(evicted) != 2 || evicted[0] != "ghost" || evicted[1] != "untracked" {
+			t.Errorf("expected ghost and untracked evicted, got %v", evicted)
+		}
+
+		pos, _ := client.GeoPos(ctx, fmt.Sprintf(RedisKeyPatterns.DriverLocations, city), "ghost", "fresh")
+		if pos[0] != nil || pos[1] == nil {
+			t.Errorf("expected only the ghost removed from the geo set, got %v", pos)
+		}
+
+		// The lock is released after the sweep
+		if exists, _ := client.Exists(ctx, DefaultStaleDriverSweeperConfig().LockKey); exists != 0 {
+			t.Error("expected the sweeper lock released after the sweep")
+		}
+
+		// Another replica sweeping at the same time skips the sweep
+		lock, err := client.AcquireLock(ctx, DefaultStaleDriverSweeperConfig().LockKey, time.Minute)
+		if err != nil {
+			t.Fatalf("AcquireLock failed: %v", err)
+		}
+		defer lock.Release(ctx)
+		swept, err = sweeper.SweepAll(ctx)
+		if err != nil || swept {
+			t.Errorf("expected sweep to be skipped while the lock is held, got %v, %v", swept, err)
+		}
+	})
+	t.Run("StaleDoNotCrowdOutFresh", func(t *testing.T) {
+		city := "boulder"
+		geoKey := fmt.Sprintf(RedisKeyPatterns.DriverLocations, city)
+		lastSeenKey := fmt.Sprintf(RedisKeyPatterns.DriverLastSeen, city)
+
+		// Stale drivers right at the pickup, the fresh one further away
+		for i := 0; i < 2*nearbyDriversLimit; i++ {
+			id := fmt.Sprintf("ghost-%d", i)
+			if err := client.GeoAdd(ctx, geoKey, &redis.GeoLocation{Name: id, Latitude: 40.0150, Longitude: -105.2705}); err != nil {
+				t.Fatalf("GeoAdd failed: %v", err)
+			}
+			if err := client.ZAdd(ctx, lastSeenKey, redis.Z{Score: old, Member: id}); err != nil {
+				t.Fatalf("ZAdd failed: %v", err)
+			}
+		}
+		if _, err := availability.GoOnline(ctx, "fresh-boulder", city, geo.NewPoint(40.0200, -105.2705)); err != nil {
+			t.Fatalf("GoOnline failed: %v", err)
+		}
+
+		nearby, err := locations.GetNearbyDrivers(ctx, city, 40.0150, -105.2705, 5)
+		if err != nil {
+			t.Fatalf("GetNearbyDrivers failed: %v", err)
+		}
+		if len(nearby) != 1 || nearby[0].Name != "fresh-boulder" {
+			t.Errorf("expected the fresh driver, got %d drivers", len(nearby))
+		}
+	})
+}
@@ -0,0 +1,229 @@
+// Package database provides database client utilities.
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/mycobrun/cobrun-shared/cache"
+	"github.com/mycobrun/cobrun-shared/telemetry"
+)
+
+// TieredCacheConfig holds tiered cache configuration.
+type TieredCacheConfig struct {
+	// Local bounds the in-process tier. Its TTL also bounds how long a
+	// replica can serve a value after missing an invalidation.
+	Local cache.LRUConfig
+	// Channel is the Redis pub/sub channel invalidations are broadcast on.
+	Channel string
+}
+
+// DefaultTieredCacheConfig returns sensible defaults.
+func DefaultTieredCacheConfig() TieredCacheConfig {
+	return TieredCacheConfig{
+		Local:   cache.DefaultLRUConfig(),
+		Channel: "cache:invalidate",
+	}
+}
+
+// TieredCache keeps hot keys, such as rate cards and geofences, in an
+// in-process LRU in front of Redis. Writes and deletes are broadcast on a
+// Redis channel so that every replica running Run drops its local copy.
+//
+// A value read from Redis is not kept locally if its key was written or
+// invalidated during the read. An invalidation missed while the subscription
+// is down is bounded by the local TTL, since the tier is purged on reconnect.
+//
+// It is a CacheStore, so a Cache can use it for typed values:
+//
+//	tiered := database.NewTieredCache(redis, database.DefaultTieredCacheConfig())
+//	go tiered.Run(ctx)
+//	rateCards := database.NewCache[RateCard](tiered, database.DefaultCacheConfig(database.RedisTTLs.RateCardCache))
+type TieredCache struct {
+	local   *cache.LRU
+	redis   *RedisClient
+	config  TieredCacheConfig
+	origin  string
+	metrics *cache.TierMetrics
+
+	// generations counts writes and invalidations per stripe of keys
+	generations [invalidationStripes]atomic.Uint64
+}
+
+// invalidationStripes is the number of key stripes with their own
+// invalidation generation.
+const invalidationStripes = 256
+
+var _ CacheStore = (*TieredCache)(nil)
+
+// invalidation is the message broadcast when keys change.
+type invalidation struct {
+	Origin string   `json:"o"`
+	Keys   []string `json:"k"`
+}
+
+// NewTieredCache creates a tiered cache over client.
+func NewTieredCache(client *RedisClient, config TieredCacheConfig) *TieredCache {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	return &TieredCache{
+		local:  cache.NewLRU(config.Local),
+		redis:  client,
+		config: config,
+		origin: hex.EncodeToString(id),
+	}
+}
+
+// WithMetrics records hits and misses of the "local" and "redis" tiers.
+func (c *TieredCache) WithMetrics(metrics *telemetry.CacheMetrics) *TieredCache {
+	c.local.WithMetrics(metrics)
+	c.metrics = cache.NewTierMetrics(metrics, "redis")
+	return c
+}
+
+// Local returns the in-process tier.
+func (c *TieredCache) Local() *cache.LRU {
+	return c.local
+}
+
+// Get returns the value for key from the local tier, or from Redis. Returns
+// ErrKeyNotFound if neither has it.
+func (c *TieredCache) Get(ctx context.Context, key string) (string, error) {
+	if value, ok := c.local.Get(key); ok {
+		return string(value), nil
+	}
+
+	generation := c.generation(key).Load()
+	value, err := c.redis.Get(ctx, key)
+	if errors.Is(err, ErrKeyNotFound) {
+		c.metrics.Miss()
+		return "", err
+	}
+	if err != nil {
+		return "", err
+	}
+	c.metrics.Hit()
+
+	ttl := time.Duration(0)
+	if c.config.Local.TTL <= 0 {
+		// Without a local TTL, keep it no longer than Redis does
+		if ttl, err = c.redis.TTL(ctx, key); err != nil || ttl <= 0 {
+			return value, nil
+		}
+	}
+	c.local.Set(key, []byte(value), ttl)
+	// The value may predate a write or invalidation that raced with the read;
+	// checking after Set also covers one that lands between check and Set.
+	if c.generation(key).Load() != generation {
+		c.local.Delete(key)
+	}
+	return value, nil
+}
+
+// Set stores value in Redis and locally, and tells the other replicas to
+// drop their copy.
+func (c *TieredCache) Set(ctx context.Context, key, value string, expiration time.Duration) error {
+	if err := c.redis.Set(ctx, key, value, expiration); err != nil {
+		return err
+	}
+	c.generation(key).Add(1)
+	c.local.Set(key, []byte(value), expiration)
+	return c.broadcast(ctx, key)
+}
+
+// Delete removes keys from Redis and from every replica's local tier.
+func (c *TieredCache) Delete(ctx context.Context, keys ...string) error {
+	c.drop(keys...)
+	if err := c.redis.Delete(ctx, keys...); err != nil {
+		return err
+	}
+	return c.broadcast(ctx, keys...)
+}
+
+// generation returns the invalidation generation of key's stripe.
+func (c *TieredCache) generation(key string) *atomic.Uint64 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &c.generations[h.Sum32()%invalidationStripes]
+}
+
+// drop removes keys from the local tier, after advancing their generation
+// so that reads in flight do not put them back.
+func (c *TieredCache) drop(keys ...string) {
+	for _, key := range keys {
+		c.generation(key).Add(1)
+	}
+	c.local.Delete(keys...)
+}
+
+// purge clears the local tier and advances every generation.
+func (c *TieredCache) purge() {
+	for i := range c.generations {
+		c.generations[i].Add(1)
+	}
+	c.local.Purge()
+}
+
+func (c *TieredCache) broadcast(ctx context.Context, keys ...string) error {
+	msg, err := json.Marshal(invalidation{Origin: c.origin, Keys: keys})
+	if err != nil {
+		return err
+	}
+	return c.redis.Publish(ctx, c.config.Channel, msg)
+}
+
+// Run applies the invalidations broadcast by other replicas until ctx is
+// done. While disconnected invalidations may be missed, so the local tier is
+// cleared whenever the subscription is (re)established.
+func (c *TieredCache) Run(ctx context.Context) error {
+	pubsub := c.redis.Subscribe(ctx, c.config.Channel)
+	defer pubsub.Close()
+
+	for {
+		msg, err := pubsub.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			c.purge()
+			log.Printf("cache invalidation subscription failed: %v", err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			if m.Kind == "subscribe" {
+				c.purge()
+			}
+		case *redis.Message:
+			c.invalidate(m.Payload)
+		}
+	}
+}
+
+// invalidate drops the keys named in a broadcast from another replica.
+func (c *TieredCache) invalidate(payload string) {
+	var msg invalidation
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		log.Printf("invalid cache invalidation message: %v", err)
+		return
+	}
+	if msg.Origin == c.origin {
+		return
+	}
+	c.drop(msg.Keys...)
+}
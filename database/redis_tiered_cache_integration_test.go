@@ -0,0 +1,68 @@
+//go:build integration
+
+// Package database provides database client utilities.
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTieredCache_Integration(t *testing.T) {
+	ctx, client := newIntegrationRedisClient(t)
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Two replicas sharing Redis
+	a := NewTieredCache(client, DefaultTieredCacheConfig())
+	b := NewTieredCache(client, DefaultTieredCacheConfig())
+	go a.Run(runCtx)
+	go b.Run(runCtx)
+	time.Sleep(100 * time.Millisecond) // let the subscriptions start
+
+	key := "rate_card:seattle:economy"
+	if err := a.Set(ctx, key, "v1", RedisTTLs.RateCardCache); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if v, err := b.Get(ctx, key); err != nil || v != "v1" {
+		t.Fatalf("expected v1 from Redis, got %q, %v", v, err)
+	}
+	if _, ok := b.Local().Get(key); !ok {
+		t.Fatal("expected the value to be cached locally")
+	}
+
+	if err := a.Set(ctx, key, "v2", RedisTTLs.RateCardCache); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	waitFor(t, func() bool {
+		_, ok := b.Local().Get(key)
+		return !ok
+	})
+	if v, _ := b.Get(ctx, key); v != "v2" {
+		t.Errorf("expected v2 after invalidation, got %q", v)
+	}
+
+	if err := b.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	waitFor(t, func() bool {
+		_, ok := a.Local().Get(key)
+		return !ok
+	})
+	if _, err := a.Get(ctx, key); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTieredCache_InvalidationGeneration(t *testing.T) {
+	c := NewTieredCache(nil, DefaultTieredCacheConfig())
+	key := "rate_card:seattle:economy"
+	c.Local().Set(key, []byte("v1"), 0)
+
+	before := c.generation(key).Load()
+	own, _ := json.Marshal(invalidation{Origin: c.origin, Keys: []string{key}})
+	c.invalidate(string(own))
+	if c.generation(key).Load() != before {
+		t.Error("own invalidations should not advance the generation")
+	}
+
+	other, _ := json.Marshal(invalidation{Origin: "other", Keys: []string{key}})
+	c.invalidate(string(other))
+	if c.generation(key).Load() == before {
+		t.Error("expected the generation advanced by another replica's invalidation")
+	}
+	if _, ok := c.Local().Get(key); ok {
+		t.Error("expected the key dropped from the local tier")
+	}
+
+	before = c.generation(key).Load()
+	c.purge()
+	if c.generation(key).Load() == before {
+		t.Error("expected a purge to advance every generation")
+	}
+}
+
+func TestTieredCache_Invalidate(t *testing.T) {
+	cache := NewTieredCache(nil, DefaultTieredCacheConfig())
+	cache.local.Set("rate_card:sea:economy", []byte("v1"), 0)
+	cache.local.Set("rate_card:sea:xl", []byte("v1"), 0)
+
+	// Our own broadcasts are ignored: the local copy is already current
+	cache.invalidate(`{"o":"` + cache.origin + `","k":["rate_card:sea:economy"]}`)
+	if _, ok := cache.local.Get("rate_card:sea:economy"); !ok {
+		t.Error("own invalidation should keep the local copy")
+	}
+
+	cache.invalidate(`{"o":"other","k":["rate_card:sea:economy","missing"]}`)
+	if _, ok := cache.local.Get("rate_card:sea:economy"); ok {
+		t.Error("expected the key to be dropped")
+	}
+	if _, ok := cache.local.Get("rate_card:sea:xl"); !ok {
+		t.Error("other keys should be kept")
+	}
+
+	cache.invalidate("not json")
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	apperrors "github.com/mycobrun/cobrun-shared/errors"
 )
 
 // RetryConfig holds retry configuration.
@@ -113,6 +114,23 @@ func isRetryable(err error) bool {
 		return false
 	}
 
+	// Concurrent modifications need a fresh read, not a blind retry
+	if errors.Is(err, ErrPreconditionFailed) {
+		return false
+	}
+
+	// Mapped client errors will fail the same way again
+	switch apperrors.Code(err) {
+	case apperrors.CodeNotFound, apperrors.CodeConflict, apperrors.CodeBadRequest,
+		apperrors.CodeValidation, apperrors.CodeUnauthorized, apperrors.CodeForbidden:
+		return false
+	}
+
+	// SQL Server errors carry a number that says whether they are transient
+	if _, ok := SQLErrorNumber(err); ok {
+		return IsTransientSQLError(err)
+	}
+
 	// Check for Azure SDK errors
 	var respErr *azcore.ResponseError
 	if errors.As(err, &respErr) {
@@ -120,7 +138,7 @@ func isRetryable(err error) bool {
 		switch respErr.StatusCode {
 		case 408, 429, 500, 502, 503, 504:
 			return true
-		case 400, 401, 403, 404, 409:
+		case 400, 401, 403, 404, 409, 412:
 			// Client errors are not retryable
 			return false
 		}
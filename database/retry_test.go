@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	mssql "github.com/microsoft/go-mssqldb"
 )
 
 func TestDefaultRetryConfig(t *testing.T) {
@@ -331,6 +332,7 @@ func TestIsRetryable_AzureErrors(t *testing.T) {
 		{name: "403 Forbidden", statusCode: 403, retryable: false},
 		{name: "404 Not Found", statusCode: 404, retryable: false},
 		{name: "409 Conflict", statusCode: 409, retryable: false},
+		{name: "412 Precondition Failed", statusCode: 412, retryable: false},
 	}
 
 	for _, tt := range tests {
@@ -346,6 +348,37 @@ func TestIsRetryable_AzureErrors(t *testing.T) {
 	}
 }
 
+func TestIsRetryable_SQLServerErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{name: "deadlock victim", err: mssql.Error{Number: 1205}, retryable: true},
+		{name: "lock timeout", err: mssql.Error{Number: 1222}, retryable: true},
+		{name: "database unavailable", err: mssql.Error{Number: 40613}, retryable: true},
+		{name: "wrapped deadlock", err: fmt.Errorf("update trip: %w", mssql.Error{Number: 1205}), retryable: true},
+		{name: "deadlock in batch", err: mssql.Error{Number: 3621, All: []mssql.Error{{Number: 1205}, {Number: 3621}}}, retryable: true},
+		{name: "unique violation", err: mssql.Error{Number: 2627, Message: "Violation of UNIQUE KEY constraint; timeout"}, retryable: false},
+		{name: "invalid object", err: mssql.Error{Number: 208}, retryable: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryable(tt.err); got != tt.retryable {
+				t.Errorf("expected retryable=%v, got %v", tt.retryable, got)
+			}
+			if got := IsTransientSQLError(tt.err); got != tt.retryable {
+				t.Errorf("expected transient=%v, got %v", tt.retryable, got)
+			}
+		})
+	}
+
+	if IsTransientSQLError(errors.New("connection reset")) {
+		t.Error("non SQL Server errors are not classified")
+	}
+}
+
 func TestContainsIgnoreCase(t *testing.T) {
 	tests := []struct {
 		s        string
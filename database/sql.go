@@ -5,6 +5,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/microsoft/go-mssqldb" // SQL Server driver
@@ -22,6 +23,14 @@ type SQLConfig struct {
 	MaxOpenConns int
 	MaxIdleConns int
 	MaxLifetime  time.Duration
+
+	// ReadReplica opens a second, read-only pool (ApplicationIntent=ReadOnly)
+	// for reads routed with WithReadReplica or Replica. On Azure SQL the
+	// primary host serves the read scale-out replica; set ReplicaHost for a
+	// geo-replica instead.
+	ReadReplica     bool
+	ReplicaHost     string
+	ReplicaCooldown time.Duration // reads stay on the primary this long after a replica failure
 }
 
 // DefaultSQLConfig returns sensible defaults.
@@ -31,6 +40,8 @@ func DefaultSQLConfig() SQLConfig {
 		MaxOpenConns: 25,
 		MaxIdleConns: 5,
 		MaxLifetime:  5 * time.Minute,
+
+		ReplicaCooldown: 30 * time.Second,
 	}
 }
 
@@ -38,29 +49,77 @@ func DefaultSQLConfig() SQLConfig {
 type SQLClient struct {
 	db     *sql.DB
 	config SQLConfig
+
+	replica          *sql.DB
+	replicaDownUntil atomic.Int64 // unix nanoseconds
+	hooks            sqlHooks
 }
 
 // NewSQLClient creates a new Azure SQL client.
 func NewSQLClient(ctx context.Context, config SQLConfig) (*SQLClient, error) {
+	db, err := openSQL(config, config.Host, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// Verify connection
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	client := &SQLClient{
+		db:     db,
+		config: config,
+	}
+
+	if config.ReadReplica {
+		host := config.ReplicaHost
+		if host == "" {
+			host = config.Host
+		}
+		replica, err := openSQL(config, host, true)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to open read replica: %w", err)
+		}
+		client.replica = replica
+
+		// An unreachable replica must not keep the service from starting;
+		// reads use the primary until it answers.
+		if err := replica.PingContext(ctx); err != nil {
+			client.markReplicaDown()
+		}
+	}
+
+	return client, nil
+}
+
+// openSQL opens a connection pool to host. readOnly connects with
+// ApplicationIntent=ReadOnly, which routes to a readable secondary.
+func openSQL(config SQLConfig, host string, readOnly bool) (*sql.DB, error) {
 	var connStr string
 
 	if config.UseMSI {
 		// Use Azure AD authentication with Managed Identity
 		connStr = fmt.Sprintf(
 			"sqlserver://%s:%d?database=%s&fedauth=ActiveDirectoryMSI",
-			config.Host, config.Port, config.Database,
+			host, config.Port, config.Database,
 		)
 	} else {
 		// Use SQL authentication
 		connStr = fmt.Sprintf(
 			"sqlserver://%s:%s@%s:%d?database=%s&encrypt=true&trustservercertificate=false",
-			config.User, config.Password, config.Host, config.Port, config.Database,
+			config.User, config.Password, host, config.Port, config.Database,
 		)
 	}
+	if readOnly {
+		connStr += "&applicationintent=ReadOnly"
+	}
 
 	db, err := sql.Open("sqlserver", connStr)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, err
 	}
 
 	// Configure connection pool
@@ -68,16 +127,7 @@ func NewSQLClient(ctx context.Context, config SQLConfig) (*SQLClient, error) {
 	db.SetMaxIdleConns(config.MaxIdleConns)
 	db.SetConnMaxLifetime(config.MaxLifetime)
 
-	// Verify connection
-	if err := db.PingContext(ctx); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("failed to ping database: %w", err)
-	}
-
-	return &SQLClient{
-		db:     db,
-		config: config,
-	}, nil
+	return db, nil
 }
 
 // DB returns the underlying sql.DB instance.
@@ -92,68 +142,118 @@ func (c *SQLClient) Ping(ctx context.Context) error {
 
 // Close closes the database connection.
 func (c *SQLClient) Close() error {
+	if c.replica != nil {
+		_ = c.replica.Close()
+	}
 	return c.db.Close()
 }
 
-// Exec executes a query without returning results.
+// Exec executes a query without returning results. Writes always run on the
+// primary.
 func (c *SQLClient) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
-	return c.db.ExecContext(ctx, query, args...)
+	var result sql.Result
+	err := c.hooks.run(ctx, &SQLStatement{Method: "exec", Query: query}, func(ctx context.Context) error {
+		var err error
+		result, err = c.db.ExecContext(ctx, query, args...)
+		return err
+	})
+	return result, err
 }
 
-// Query executes a query and returns rows.
+// Query executes a query and returns rows. It runs on the read replica when
+// ctx was marked with WithReadReplica.
 func (c *SQLClient) Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
-	return c.db.QueryContext(ctx, query, args...)
+	var rows *sql.Rows
+	err := c.read(ctx, "query", query, func(ctx context.Context, db *sql.DB) error {
+		var err error
+		rows, err = db.QueryContext(ctx, query, args...)
+		return err
+	})
+	return rows, err
 }
 
-// QueryRow executes a query and returns a single row.
+// QueryRow executes a query and returns a single row. It runs on the read
+// replica when ctx was marked with WithReadReplica.
 func (c *SQLClient) QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
-	return c.db.QueryRowContext(ctx, query, args...)
+	var row *sql.Row
+	_ = c.read(ctx, "query_row", query, func(ctx context.Context, db *sql.DB) error {
+		row = db.QueryRowContext(ctx, query, args...)
+		return row.Err()
+	})
+	return row
 }
 
 // Transaction represents a database transaction.
 type Transaction struct {
-	tx *sql.Tx
+	tx    *sql.Tx
+	hooks sqlHooks
+
+	savepoints *int // savepoints created so far, shared with nested transactions
+	nested     bool
 }
 
-// Begin starts a new transaction.
-func (c *SQLClient) Begin(ctx context.Context) (*Transaction, error) {
-	tx, err := c.db.BeginTx(ctx, nil)
+// Begin starts a new transaction on the primary.
+func (c *SQLClient) Begin(ctx context.Context, opts ...TxOption) (*Transaction, error) {
+	tx, err := c.db.BeginTx(ctx, txOptions(opts))
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	return &Transaction{tx: tx}, nil
+	return &Transaction{tx: tx, hooks: c.hooks, savepoints: new(int)}, nil
 }
 
 // Commit commits the transaction.
 func (t *Transaction) Commit() error {
+	if t.nested {
+		return ErrNestedTransaction
+	}
 	return t.tx.Commit()
 }
 
 // Rollback rolls back the transaction.
 func (t *Transaction) Rollback() error {
+	if t.nested {
+		return ErrNestedTransaction
+	}
 	return t.tx.Rollback()
 }
 
 // Exec executes a query in the transaction.
 func (t *Transaction) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
-	return t.tx.ExecContext(ctx, query, args...)
+	var result sql.Result
+	err := t.hooks.run(ctx, &SQLStatement{Method: "exec", Query: query, InTx: true}, func(ctx context.Context) error {
+		var err error
+		result, err = t.tx.ExecContext(ctx, query, args...)
+		return err
+	})
+	return result, err
 }
 
 // Query executes a query in the transaction.
 func (t *Transaction) Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
-	return t.tx.QueryContext(ctx, query, args...)
+	var rows *sql.Rows
+	err := t.hooks.run(ctx, &SQLStatement{Method: "query", Query: query, InTx: true}, func(ctx context.Context) error {
+		var err error
+		rows, err = t.tx.QueryContext(ctx, query, args...)
+		return err
+	})
+	return rows, err
 }
 
 // QueryRow executes a query in the transaction and returns a single row.
 func (t *Transaction) QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
-	return t.tx.QueryRowContext(ctx, query, args...)
+	var row *sql.Row
+	_ = t.hooks.run(ctx, &SQLStatement{Method: "query_row", Query: query, InTx: true}, func(ctx context.Context) error {
+		row = t.tx.QueryRowContext(ctx, query, args...)
+		return row.Err()
+	})
+	return row
 }
 
 // WithTransaction executes a function within a transaction.
 // If the function returns an error, the transaction is rolled back.
 // Otherwise, it's committed.
-func (c *SQLClient) WithTransaction(ctx context.Context, fn func(*Transaction) error) error {
-	tx, err := c.Begin(ctx)
+func (c *SQLClient) WithTransaction(ctx context.Context, fn func(*Transaction) error, opts ...TxOption) error {
+	tx, err := c.Begin(ctx, opts...)
 	if err != nil {
 		return err
 	}
@@ -175,7 +275,8 @@ func (c *SQLClient) WithTransaction(ctx context.Context, fn func(*Transaction) e
 	return tx.Commit()
 }
 
-// Paginate helps build paginated queries.
+// Paginate helps build paginated queries with OFFSET/LIMIT. Deep pages get
+// slower as the skipped rows are still read; use Keyset for large tables.
 type Paginate struct {
 	Page     int
 	PageSize int
@@ -243,7 +344,7 @@ func (c *SQLClient) ExecWithRetry(ctx context.Context, query string, args ...int
 	var result sql.Result
 	err := RetrySQLOperation(ctx, func() error {
 		var execErr error
-		result, execErr = c.db.ExecContext(ctx, query, args...)
+		result, execErr = c.Exec(ctx, query, args...)
 		return execErr
 	})
 	return result, err
@@ -254,7 +355,7 @@ func (c *SQLClient) QueryWithRetry(ctx context.Context, query string, args ...in
 	var rows *sql.Rows
 	err := RetrySQLOperation(ctx, func() error {
 		var queryErr error
-		rows, queryErr = c.db.QueryContext(ctx, query, args...)
+		rows, queryErr = c.Query(ctx, query, args...)
 		return queryErr
 	})
 	return rows, err
@@ -282,7 +383,7 @@ type RetryableRow struct {
 // Scan executes the query with retry and scans the result.
 func (r *RetryableRow) Scan(dest ...interface{}) error {
 	return RetrySQLOperation(r.ctx, func() error {
-		row := r.client.db.QueryRowContext(r.ctx, r.query, r.args...)
+		row := r.client.QueryRow(r.ctx, r.query, r.args...)
 		return row.Scan(dest...)
 	})
 }
@@ -295,10 +396,11 @@ func (c *SQLClient) PingWithRetry(ctx context.Context) error {
 }
 
 // WithTransactionRetry executes a function within a transaction with retry logic.
-// The entire transaction is retried on transient failures.
-func (c *SQLClient) WithTransactionRetry(ctx context.Context, fn func(*Transaction) error) error {
+// The entire transaction is retried on transient failures, such as being
+// chosen as a deadlock victim; see IsTransientSQLError.
+func (c *SQLClient) WithTransactionRetry(ctx context.Context, fn func(*Transaction) error, opts ...TxOption) error {
 	return RetrySQLOperation(ctx, func() error {
-		return c.WithTransaction(ctx, fn)
+		return c.WithTransaction(ctx, fn, opts...)
 	})
 }
 
@@ -0,0 +1,93 @@
+// Package database provides database client utilities.
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+// SQLLeaseBackend grants leases as SQL Server application locks
+// (sp_getapplock) named "leader:<name>", for services without Redis.
+//
+// An application lock is held by a session rather than for a TTL, so each
+// lease keeps a connection from the pool open while it is held, and is lost
+// as soon as that connection breaks.
+type SQLLeaseBackend struct {
+	client *SQLClient
+}
+
+// NewSQLLeaseBackend creates a lease backend on SQL Server.
+func NewSQLLeaseBackend(client *SQLClient) *SQLLeaseBackend {
+	return &SQLLeaseBackend{client: client}
+}
+
+// sqlLease is an application lock held by a dedicated session.
+type sqlLease struct {
+	conn     *sql.Conn
+	resource string
+}
+
+// TryAcquire takes the lease on name. The ttl is unused: the lock is held
+// until it is released or the session ends.
+func (b *SQLLeaseBackend) TryAcquire(ctx context.Context, name string, ttl time.Duration) (Lease, error) {
+	conn, err := b.client.DB().Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resource := "leader:" + name
+	var status int
+	err = conn.QueryRowContext(ctx, `
+		DECLARE @status int;
+		EXEC @status = sp_getapplock @Resource = @p1, @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = 0;
+		SELECT @status`, resource).Scan(&status)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to get application lock: %w", err)
+	}
+
+	switch status {
+	case 0, 1:
+		return &sqlLease{conn: conn, resource: resource}, nil
+	case -1:
+		conn.Close()
+		return nil, ErrLockNotAcquired
+	default:
+		conn.Close()
+		return nil, fmt.Errorf("failed to get application lock: sp_getapplock returned %d", status)
+	}
+}
+
+// Extend checks that the session still holds the lock. Any failure is
+// reported as ErrLockLost, since a broken session no longer holds it.
+func (l *sqlLease) Extend(ctx context.Context, ttl time.Duration) error {
+	var mode string
+	err := l.conn.QueryRowContext(ctx, "SELECT APPLOCK_MODE('public', @p1, 'Session')", l.resource).Scan(&mode)
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %v", ErrLockLost, err)
+	}
+	if mode != "Exclusive" {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// Release releases the lock and returns the session to the pool. If the
+// lock cannot be released, the session is discarded instead, which releases
+// it too.
+func (l *sqlLease) Release(ctx context.Context) error {
+	_, err := l.conn.ExecContext(ctx, "EXEC sp_releaseapplock @Resource = @p1, @LockOwner = 'Session'", l.resource)
+	if err != nil {
+		_ = l.conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	}
+	if closeErr := l.conn.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSQLLeaseBackend(t *testing.T) {
+	status, mode := int64(0), "Exclusive"
+	client, fake := newFakeSQLClient(t, func(query string, args []driver.NamedValue) fakeSQLResult {
+		switch {
+		case strings.Contains(query, "sp_getapplock"):
+			return fakeSQLResult{columns: []string{""}, rows: [][]driver.Value{{status}}}
+		case strings.Contains(query, "APPLOCK_MODE"):
+			return fakeSQLResult{columns: []string{""}, rows: [][]driver.Value{{mode}}}
+		}
+		return fakeSQLResult{}
+	})
+	backend := NewSQLLeaseBackend(client)
+	ctx := context.Background()
+
+	lease, err := backend.TryAcquire(ctx, "sweeper", 0)
+	if err != nil {
+		t.Fatalf("TryAcquire failed: %v", err)
+	}
+	if args := fake.Calls()[0].args; len(args) != 1 || args[0].Value != "leader:sweeper" {
+		t.Errorf("unexpected lock resource %v", args)
+	}
+	if err := lease.Extend(ctx, 0); err != nil {
+		t.Errorf("Extend failed: %v", err)
+	}
+
+	mode = "NoLock"
+	if err := lease.Extend(ctx, 0); !errors.Is(err, ErrLockLost) {
+		t.Errorf("expected ErrLockLost, got %v", err)
+	}
+
+	if err := lease.Release(ctx); err != nil {
+		t.Errorf("Release failed: %v", err)
+	}
+	calls := fake.Calls()
+	if last := calls[len(calls)-1].query; !strings.Contains(last, "sp_releaseapplock") {
+		t.Errorf("expected the lock to be released, got %q", last)
+	}
+
+	status = -1
+	if _, err := backend.TryAcquire(ctx, "sweeper", 0); !errors.Is(err, ErrLockNotAcquired) {
+		t.Errorf("expected ErrLockNotAcquired, got %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+// Package database provides database client utilities.
+package database
+
+import (
+	"errors"
+
+	mssql "github.com/microsoft/go-mssqldb"
+)
+
+// transientSQLErrors are the SQL Server error numbers for conditions that
+// clear on their own, so the statement or transaction can be retried.
+var transientSQLErrors = map[int32]bool{
+	1205:  true, // deadlock victim; the transaction was rolled back
+	1222:  true, // lock request timeout
+	3960:  true, // snapshot isolation update conflict
+	4060:  true, // cannot open database, e.g. during failover
+	4221:  true, // login to read secondary timed out
+	976:   true, // database not accessible on this secondary
+	978:   true, // secondary not readable
+	983:   true, // availability replica not online
+	10053: true, // transport-level error, connection aborted
+	10054: true, // transport-level error, connection reset
+	10060: true, // transport-level error, connection timed out
+	10928: true, // resource limit reached
+	10929: true, // resource limit reached, minimum guarantee
+	40143: true, // service error processing the request
+	40197: true, // service error processing the request, e.g. failover
+	40501: true, // service busy
+	40613: true, // database not currently available
+	42108: true, // serverless database is resuming
+	42109: true, // serverless database is paused
+	49918: true, // not enough resources to process the request
+	49919: true, // too many create or update operations
+	49920: true, // too many operations in progress
+}
+
+// SQLErrorNumber returns the number of the SQL Server error in err's chain.
+func SQLErrorNumber(err error) (int32, bool) {
+	var sqlErr mssql.Error
+	if errors.As(err, &sqlErr) {
+		return sqlErr.Number, true
+	}
+	return 0, false
+}
+
+// IsTransientSQLError reports whether err is a SQL Server error worth
+// retrying: a deadlock, lock timeout, failover or Azure SQL throttling. Any
+// other error the server reports, such as a constraint violation, fails the
+// same way again. Retry uses it for every SQL Server error.
+func IsTransientSQLError(err error) bool {
+	var sqlErr mssql.Error
+	if !errors.As(err, &sqlErr) {
+		return false
+	}
+	if transientSQLErrors[sqlErr.Number] {
+		return true
+	}
+	// A batch can raise several errors; the first is not always the cause.
+	for _, e := range sqlErr.All {
+		if transientSQLErrors[e.Number] {
+			return true
+		}
+	}
+	return false
+}
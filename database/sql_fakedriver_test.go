@@ -0,0 +1,182 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+)
+
+// fakeSQLResult is what the fake driver returns for a statement.
+type fakeSQLResult struct {
+	columns  []string
+	rows     [][]driver.Value
+	affected int64
+	err      error
+}
+
+// fakeSQLCall is a statement received by the fake driver.
+type fakeSQLCall struct {
+	query string
+	args  []driver.NamedValue
+}
+
+// fakeSQLDB is the state behind one fake connection string.
+type fakeSQLDB struct {
+	mu      sync.Mutex
+	handler func(query string, args []driver.NamedValue) fakeSQLResult
+	calls   []fakeSQLCall
+}
+
+func (f *fakeSQLDB) handle(query string, args []driver.NamedValue) fakeSQLResult {
+	f.mu.Lock()
+	f.calls = append(f.calls, fakeSQLCall{query: query, args: args})
+	handler := f.handler
+	f.mu.Unlock()
+	if handler == nil {
+		return fakeSQLResult{}
+	}
+	return handler(query, args)
+}
+
+func (f *fakeSQLDB) Calls() []fakeSQLCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fakeSQLCall(nil), f.calls...)
+}
+
+var fakeSQLDBs sync.Map // dsn -> *fakeSQLDB
+
+func init() {
+	sql.Register("fakesql", fakeSQLDriver{})
+}
+
+// newFakeSQLClient returns a SQLClient whose statements are answered by handler.
+func newFakeSQLClient(t *testing.T, handler func(query string, args []driver.NamedValue) fakeSQLResult) (*SQLClient, *fakeSQLDB) {
+	t.Helper()
+	db, fake := newFakeSQLDB(t, t.Name(), handler)
+	return &SQLClient{db: db, config: DefaultSQLConfig()}, fake
+}
+
+// newFakeSQLDB opens a fake database named name whose statements are
+// answered by handler.
+func newFakeSQLDB(t *testing.T, name string, handler func(query string, args []driver.NamedValue) fakeSQLResult) (*sql.DB, *fakeSQLDB) {
+	t.Helper()
+	fake := &fakeSQLDB{handler: handler}
+	fakeSQLDBs.Store(name, fake)
+
+	db, err := sql.Open("fakesql", name)
+	if err != nil {
+		t.Fatalf("failed to open fake database: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		fakeSQLDBs.Delete(name)
+	})
+	return db, fake
+}
+
+type fakeSQLDriver struct{}
+
+func (fakeSQLDriver) Open(dsn string) (driver.Conn, error) {
+	fake, ok := fakeSQLDBs.Load(dsn)
+	if !ok {
+		return nil, fmt.Errorf("unknown fake database %q", dsn)
+	}
+	return &fakeSQLConn{db: fake.(*fakeSQLDB)}, nil
+}
+
+type fakeSQLConn struct {
+	db *fakeSQLDB
+}
+
+func (c *fakeSQLConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeSQLStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeSQLConn) Close() error { return nil }
+
+func (c *fakeSQLConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeSQLConn) BeginTx(_ context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	query := "BEGIN"
+	if opts.Isolation != 0 {
+		query = fmt.Sprintf("BEGIN ISOLATION %s", sql.IsolationLevel(opts.Isolation))
+	}
+	if res := c.db.handle(query, nil); res.err != nil {
+		return nil, res.err
+	}
+	return &fakeSQLTx{conn: c}, nil
+}
+
+func (c *fakeSQLConn) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+func (c *fakeSQLConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	res := c.db.handle(query, args)
+	if res.err != nil {
+		return nil, res.err
+	}
+	return &fakeSQLRows{columns: res.columns, rows: res.rows}, nil
+}
+
+func (c *fakeSQLConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	res := c.db.handle(query, args)
+	if res.err != nil {
+		return nil, res.err
+	}
+	return driver.RowsAffected(res.affected), nil
+}
+
+type fakeSQLTx struct {
+	conn *fakeSQLConn
+}
+
+func (t *fakeSQLTx) Commit() error   { return t.conn.db.handle("COMMIT", nil).err }
+func (t *fakeSQLTx) Rollback() error { return t.conn.db.handle("ROLLBACK", nil).err }
+
+type fakeSQLStmt struct {
+	conn  *fakeSQLConn
+	query string
+}
+
+func (s *fakeSQLStmt) Close() error  { return nil }
+func (s *fakeSQLStmt) NumInput() int { return -1 }
+
+func (s *fakeSQLStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, namedValues(args))
+}
+
+func (s *fakeSQLStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, namedValues(args))
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
+
+type fakeSQLRows struct {
+	columns []string
+	rows    [][]driver.Value
+	pos     int
+}
+
+func (r *fakeSQLRows) Columns() []string { return r.columns }
+func (r *fakeSQLRows) Close() error      { return nil }
+
+func (r *fakeSQLRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.pos])
+	r.pos++
+	return nil
+}
@@ -0,0 +1,197 @@
+// Package database provides database client utilities.
+package database
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mycobrun/cobrun-shared/logging"
+	"github.com/mycobrun/cobrun-shared/telemetry"
+)
+
+// SQLStatement describes a statement run by a SQLClient or Transaction, as
+// seen by hooks. Arguments are left out as they may hold personal data.
+type SQLStatement struct {
+	Method    string // "exec", "query" or "query_row"
+	Query     string
+	Operation string // SQL verb, e.g. "SELECT"
+	Table     string // first table the statement names, "" if unknown
+	Replica   bool   // ran on the read replica
+	InTx      bool
+
+	// Set before After is called. For queries, Duration covers the time to
+	// the first result, not reading the rows.
+	Duration time.Duration
+	Err      error
+}
+
+// SQLHook observes the statements run by a SQLClient and its transactions.
+// Before may return a derived context, e.g. carrying a span, which the
+// statement and After receive.
+type SQLHook interface {
+	Before(ctx context.Context, stmt *SQLStatement) context.Context
+	After(ctx context.Context, stmt *SQLStatement)
+}
+
+// Use adds hooks run around every Exec and Query. Add them before the client
+// is shared; transactions keep the hooks they were begun with.
+//
+//	client.Use(
+//		database.NewSQLTracingHook(tel.Tracer()),
+//		database.NewSQLMetricsHook(dbMetrics),
+//		database.NewSlowQueryHook(200*time.Millisecond, logger),
+//	)
+func (c *SQLClient) Use(hooks ...SQLHook) *SQLClient {
+	c.hooks = append(c.hooks, hooks...)
+	return c
+}
+
+type sqlHooks []SQLHook
+
+// run runs fn between the hooks; After hooks run in reverse order.
+func (h sqlHooks) run(ctx context.Context, stmt *SQLStatement, fn func(context.Context) error) error {
+	if len(h) == 0 {
+		return fn(ctx)
+	}
+
+	stmt.Operation, stmt.Table = parseSQLStatement(stmt.Query)
+	for _, hook := range h {
+		ctx = hook.Before(ctx, stmt)
+	}
+	start := time.Now()
+	err := fn(ctx)
+	stmt.Duration = time.Since(start)
+	stmt.Err = err
+	for i := len(h) - 1; i >= 0; i-- {
+		h[i].After(ctx, stmt)
+	}
+	return err
+}
+
+var sqlStatementTablePattern = regexp.MustCompile(`(?i)\b(?:FROM|INTO|UPDATE)\s+(\w+(?:\.\w+)*)`)
+
+// parseSQLStatement returns the verb and first table of query, e.g.
+// "SELECT", "dbo.trips".
+func parseSQLStatement(query string) (operation, table string) {
+	// Unquote [identifiers] so that only literals and comments are dropped.
+	code := strings.Join(splitSQLCode(strings.NewReplacer("[", "", "]", "").Replace(query)), " ")
+	if fields := strings.Fields(code); len(fields) > 0 {
+		operation = strings.ToUpper(strings.TrimRight(fields[0], "(;"))
+	}
+	if m := sqlStatementTablePattern.FindStringSubmatch(code); m != nil {
+		table = m[1]
+	}
+	return operation, table
+}
+
+// sqlOperationName names a statement for metrics, e.g. "trips.select".
+func sqlOperationName(stmt *SQLStatement) string {
+	operation := strings.ToLower(stmt.Operation)
+	if stmt.Table == "" {
+		return operation
+	}
+	return stmt.Table + "." + operation
+}
+
+type sqlSpanKey struct{}
+
+type sqlTracingHook struct {
+	tracer trace.Tracer
+}
+
+// NewSQLTracingHook returns a hook that wraps every statement in a client
+// span named like "SELECT trips", with the telemetry.DatabaseAttributes.
+func NewSQLTracingHook(tracer trace.Tracer) SQLHook {
+	return sqlTracingHook{tracer: tracer}
+}
+
+func (h sqlTracingHook) Before(ctx context.Context, stmt *SQLStatement) context.Context {
+	name := strings.TrimSpace(stmt.Operation + " " + stmt.Table)
+	ctx, span := h.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(telemetry.DatabaseAttributes("mssql", stmt.Operation, stmt.Table)...),
+		trace.WithAttributes(attribute.Bool("db.replica", stmt.Replica)),
+	)
+	return context.WithValue(ctx, sqlSpanKey{}, span)
+}
+
+func (h sqlTracingHook) After(ctx context.Context, stmt *SQLStatement) {
+	span, ok := ctx.Value(sqlSpanKey{}).(trace.Span)
+	if !ok {
+		return
+	}
+	if stmt.Err != nil {
+		span.RecordError(stmt.Err)
+		span.SetStatus(codes.Error, stmt.Err.Error())
+	} else {
+		span.SetStatus(codes.Ok, "")
+	}
+	span.End()
+}
+
+type sqlMetricsHook struct {
+	metrics *telemetry.DatabaseMetrics
+}
+
+// NewSQLMetricsHook returns a hook that records every statement with
+// DatabaseMetrics.RecordOperation, named like "trips.select".
+func NewSQLMetricsHook(metrics *telemetry.DatabaseMetrics) SQLHook {
+	return sqlMetricsHook{metrics: metrics}
+}
+
+func (h sqlMetricsHook) Before(ctx context.Context, _ *SQLStatement) context.Context {
+	return ctx
+}
+
+func (h sqlMetricsHook) After(ctx context.Context, stmt *SQLStatement) {
+	h.metrics.RecordOperation(ctx, sqlOperationName(stmt), stmt.Duration, stmt.Err)
+}
+
+// maxLoggedQueryLength bounds the query text in slow query logs.
+const maxLoggedQueryLength = 1000
+
+type slowQueryHook struct {
+	threshold time.Duration
+	logger    *logging.Logger
+}
+
+// NewSlowQueryHook returns a hook that logs a warning for statements slower
+// than threshold. A nil logger uses the one from the context.
+func NewSlowQueryHook(threshold time.Duration, logger *logging.Logger) SQLHook {
+	return slowQueryHook{threshold: threshold, logger: logger}
+}
+
+func (h slowQueryHook) Before(ctx context.Context, _ *SQLStatement) context.Context {
+	return ctx
+}
+
+func (h slowQueryHook) After(ctx context.Context, stmt *SQLStatement) {
+	if stmt.Duration < h.threshold {
+		return
+	}
+	logger := h.logger
+	if logger == nil {
+		logger = logging.FromContext(ctx)
+	}
+
+	query := stmt.Query
+	if len(query) > maxLoggedQueryLength {
+		query = query[:maxLoggedQueryLength] + "..."
+	}
+	args := []any{
+		"operation", sqlOperationName(stmt),
+		"duration_ms", stmt.Duration.Milliseconds(),
+		"replica", stmt.Replica,
+		"query", query,
+	}
+	if stmt.Err != nil {
+		args = append(args, "error", stmt.Err.Error())
+	}
+	logger.Warn("slow query", args...)
+}
@@ -0,0 +1,153 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/mycobrun/cobrun-shared/logging"
+)
+
+type recordingHook struct {
+	events []string
+	stmts  []SQLStatement
+}
+
+func (h *recordingHook) Before(ctx context.Context, stmt *SQLStatement) context.Context {
+	h.events = append(h.events, "before "+stmt.Method)
+	return ctx
+}
+
+func (h *recordingHook) After(_ context.Context, stmt *SQLStatement) {
+	h.events = append(h.events, "after "+stmt.Method)
+	h.stmts = append(h.stmts, *stmt)
+}
+
+func TestSQLClient_Hooks(t *testing.T) {
+	failure := errors.New("deadlock")
+	client, _ := newFakeSQLClient(t, func(query string, _ []driver.NamedValue) fakeSQLResult {
+		if strings.HasPrefix(query, "DELETE") {
+			return fakeSQLResult{err: failure}
+		}
+		return fakeSQLResult{columns: []string{"id"}, rows: [][]driver.Value{{int64(1)}}, affected: 1}
+	})
+	hook := &recordingHook{}
+	client.Use(hook)
+	ctx := context.Background()
+
+	if _, err := client.Exec(ctx, "INSERT INTO [dbo].[trips] (id) VALUES (@p1)", 1); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if _, err := Select[int64](ctx, client, "SELECT id FROM trips"); err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	err := client.WithTransaction(ctx, func(tx *Transaction) error {
+		_, err := tx.Exec(ctx, "DELETE FROM trips WHERE id = @p1", 1)
+		return err
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("expected the failure, got %v", err)
+	}
+
+	if len(hook.stmts) != 3 {
+		t.Fatalf("expected 3 statements, got %+v", hook.stmts)
+	}
+	insert, sel, del := hook.stmts[0], hook.stmts[1], hook.stmts[2]
+	if insert.Method != "exec" || insert.Operation != "INSERT" || insert.Table != "dbo.trips" || insert.Err != nil {
+		t.Errorf("unexpected insert: %+v", insert)
+	}
+	if sel.Method != "query" || sel.Operation != "SELECT" || sel.Table != "trips" || sel.InTx {
+		t.Errorf("unexpected select: %+v", sel)
+	}
+	if !del.InTx || del.Operation != "DELETE" || !errors.Is(del.Err, failure) {
+		t.Errorf("unexpected delete: %+v", del)
+	}
+	if hook.events[0] != "before exec" || hook.events[1] != "after exec" {
+		t.Errorf("unexpected hook order: %v", hook.events)
+	}
+}
+
+func TestParseSQLStatement(t *testing.T) {
+	tests := []struct {
+		query     string
+		operation string
+		table     string
+	}{
+		{"SELECT * FROM trips WHERE id = @p1", "SELECT", "trips"},
+		{"  -- comment FROM x\n select id from [dbo].[riders]", "SELECT", "dbo.riders"},
+		{"SELECT TOP (21) * FROM (SELECT * FROM trips) AS keyset", "SELECT", "trips"},
+		{"UPDATE drivers SET status = 'FROM x'", "UPDATE", "drivers"},
+		{"insert into payments (id) values (1)", "INSERT", "payments"},
+		{"EXEC sp_refresh", "EXEC", ""},
+	}
+	for _, tt := range tests {
+		op, table := parseSQLStatement(tt.query)
+		if op != tt.operation || table != tt.table {
+			t.Errorf("%q: expected %s %s, got %s %s", tt.query, tt.operation, tt.table, op, table)
+		}
+	}
+}
+
+func TestSQLTracingHook(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	client, _ := newFakeSQLClient(t, func(query string, _ []driver.NamedValue) fakeSQLResult {
+		if strings.Contains(query, "missing") {
+			return fakeSQLResult{err: errors.New("invalid object name")}
+		}
+		return fakeSQLResult{affected: 1}
+	})
+	client.Use(NewSQLTracingHook(tracer))
+
+	_, _ = client.Exec(context.Background(), "UPDATE trips SET status = @p1", "completed")
+	_, _ = client.Exec(context.Background(), "DELETE FROM missing")
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if spans[0].Name() != "UPDATE trips" || spans[0].Status().Code != codes.Ok {
+		t.Errorf("unexpected span: %s %v", spans[0].Name(), spans[0].Status())
+	}
+	if spans[1].Name() != "DELETE missing" || spans[1].Status().Code != codes.Error {
+		t.Errorf("unexpected span: %s %v", spans[1].Name(), spans[1].Status())
+	}
+	var table string
+	for _, attr := range spans[0].Attributes() {
+		if attr.Key == "db.sql.table" {
+			table = attr.Value.AsString()
+		}
+	}
+	if table != "trips" {
+		t.Errorf("expected db.sql.table=trips, got %q", table)
+	}
+}
+
+func TestSlowQueryHook(t *testing.T) {
+	var buf bytes.Buffer
+	logger := &logging.Logger{Logger: slog.New(slog.NewJSONHandler(&buf, nil))}
+
+	client, _ := newFakeSQLClient(t, nil)
+	client.Use(NewSlowQueryHook(time.Hour, logger))
+	_, _ = client.Exec(context.Background(), "UPDATE trips SET status = 'fast'")
+	if buf.Len() != 0 {
+		t.Errorf("fast query should not be logged: %s", buf.String())
+	}
+
+	client.Use(NewSlowQueryHook(0, logger))
+	_, _ = client.Exec(context.Background(), "UPDATE trips SET status = 'slow'")
+	out := buf.String()
+	if !strings.Contains(out, `"msg":"slow query"`) || !strings.Contains(out, `"operation":"trips.update"`) || !strings.Contains(out, "status = 'slow'") {
+		t.Errorf("unexpected log: %s", out)
+	}
+}
@@ -0,0 +1,300 @@
+// Package database provides database client utilities.
+package database
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// SortKey is one column of a keyset ordering.
+type SortKey struct {
+	Column string
+	Desc   bool
+}
+
+// Asc orders by column ascending.
+func Asc(column string) SortKey {
+	return SortKey{Column: column}
+}
+
+// Desc orders by column descending.
+func Desc(column string) SortKey {
+	return SortKey{Column: column, Desc: true}
+}
+
+var sqlColumnPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Keyset paginates by seeking past the sort key of the last row seen instead
+// of skipping rows with OFFSET, so every page costs the same however deep it
+// is. The sort keys must be non-null and, together, unique; end with the
+// primary key to break ties. An index on the sort keys makes each page a
+// single index seek.
+//
+// Cursors are opaque and signed with an HMAC, so clients can neither forge
+// them nor reuse them for another query.
+type Keyset struct {
+	keys   []SortKey
+	secret []byte
+}
+
+// NewKeyset creates a keyset ordering. secret signs the cursors.
+func NewKeyset(secret []byte, keys ...SortKey) (*Keyset, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("keyset secret is required")
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("keyset needs at least one sort key")
+	}
+	for _, k := range keys {
+		if !sqlColumnPattern.MatchString(k.Column) {
+			return nil, fmt.Errorf("invalid sort column %q", k.Column)
+		}
+	}
+	return &Keyset{keys: keys, secret: secret}, nil
+}
+
+// OrderBy returns the ORDER BY list, e.g. "[created_at] DESC, [id] DESC".
+func (k *Keyset) OrderBy() string {
+	parts := make([]string, len(k.keys))
+	for i, key := range k.keys {
+		dir := "ASC"
+		if key.Desc {
+			dir = "DESC"
+		}
+		parts[i] = fmt.Sprintf("[%s] %s", key.Column, dir)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Seek decodes cursor and returns the predicate selecting the rows after it,
+// with its arguments. scope must be the one the cursor was created with,
+// typically the query text. An empty cursor returns "1 = 1". Returns
+// ErrInvalidCursor for a malformed or tampered cursor.
+func (k *Keyset) Seek(scope, cursor string) (string, []interface{}, error) {
+	if cursor == "" {
+		return "1 = 1", nil, nil
+	}
+	values, err := k.decode(scope, cursor)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// (a > @k0) OR (a = @k0 AND b > @k1) OR ...
+	args := make([]interface{}, len(k.keys))
+	var or []string
+	for i, key := range k.keys {
+		args[i] = sql.Named(fmt.Sprintf("keyset_%d", i), values[i])
+		var and []string
+		for j := 0; j < i; j++ {
+			and = append(and, fmt.Sprintf("[%s] = @keyset_%d", k.keys[j].Column, j))
+		}
+		op := ">"
+		if key.Desc {
+			op = "<"
+		}
+		and = append(and, fmt.Sprintf("[%s] %s @keyset_%d", key.Column, op, i))
+		or = append(or, "("+strings.Join(and, " AND ")+")")
+	}
+	return "(" + strings.Join(or, " OR ") + ")", args, nil
+}
+
+// Cursor returns the cursor pointing after row, a struct whose `db` fields
+// include the sort columns.
+func (k *Keyset) Cursor(scope string, row interface{}) (string, error) {
+	v := reflect.ValueOf(row)
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return "", fmt.Errorf("keyset row must be a struct, got %T", row)
+	}
+
+	byName := sqlFieldsOf(v.Type()).byName
+	values := make([]cursorValue, len(k.keys))
+	for i, key := range k.keys {
+		index, ok := byName[strings.ToLower(key.Column)]
+		if !ok {
+			return "", fmt.Errorf("sort column %q has no matching field in %s", key.Column, v.Type())
+		}
+		field, err := v.FieldByIndexErr(index)
+		if err != nil {
+			return "", fmt.Errorf("sort column %q is nil", key.Column)
+		}
+		cv, err := newCursorValue(field.Interface())
+		if err != nil {
+			return "", fmt.Errorf("sort column %q: %w", key.Column, err)
+		}
+		values[i] = cv
+	}
+
+	payload, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(k.sign(scope, payload)), nil
+}
+
+func (k *Keyset) sign(scope string, payload []byte) []byte {
+	mac := hmac.New(sha256.New, k.secret)
+	mac.Write([]byte(k.OrderBy()))
+	mac.Write([]byte{0})
+	mac.Write([]byte(scope))
+	mac.Write([]byte{0})
+	mac.Write(payload)
+	return mac.Sum(nil)[:16]
+}
+
+func (k *Keyset) decode(scope, cursor string) ([]interface{}, error) {
+	encoded, signature, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return nil, invalidCursor()
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, invalidCursor()
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, k.sign(scope, payload)) {
+		return nil, invalidCursor()
+	}
+
+	var values []cursorValue
+	if err := json.Unmarshal(payload, &values); err != nil || len(values) != len(k.keys) {
+		return nil, invalidCursor()
+	}
+	out := make([]interface{}, len(values))
+	for i, v := range values {
+		if out[i], err = v.value(); err != nil {
+			return nil, invalidCursor()
+		}
+	}
+	return out, nil
+}
+
+// cursorValue is a sort key value with its type, so that it is bound with
+// the same SQL type it was read with.
+type cursorValue struct {
+	Type  string          `json:"t"`
+	Value json.RawMessage `json:"v"`
+}
+
+func newCursorValue(v interface{}) (cursorValue, error) {
+	if valuer, ok := v.(driver.Valuer); ok {
+		var err error
+		if v, err = valuer.Value(); err != nil {
+			return cursorValue{}, err
+		}
+	}
+
+	var typ string
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	switch {
+	case !rv.IsValid() || rv.Kind() == reflect.Pointer:
+		return cursorValue{}, fmt.Errorf("sort keys must not be null")
+	case rv.Type() == timeType:
+		typ = "time"
+	case rv.Kind() >= reflect.Int && rv.Kind() <= reflect.Int64:
+		typ = "int"
+	case rv.Kind() >= reflect.Uint && rv.Kind() <= reflect.Uint64:
+		typ = "uint"
+	case rv.Kind() == reflect.Float32 || rv.Kind() == reflect.Float64:
+		typ = "float"
+	case rv.Kind() == reflect.String:
+		typ = "string"
+	case rv.Kind() == reflect.Bool:
+		typ = "bool"
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+		typ = "bytes"
+	default:
+		return cursorValue{}, fmt.Errorf("unsupported sort key type %s", rv.Type())
+	}
+
+	data, err := json.Marshal(rv.Interface())
+	if err != nil {
+		return cursorValue{}, err
+	}
+	return cursorValue{Type: typ, Value: data}, nil
+}
+
+func (c cursorValue) value() (interface{}, error) {
+	var err error
+	switch c.Type {
+	case "time":
+		var t time.Time
+		err = json.Unmarshal(c.Value, &t)
+		return t, err
+	case "int":
+		var i int64
+		err = json.Unmarshal(c.Value, &i)
+		return i, err
+	case "uint":
+		var u uint64
+		err = json.Unmarshal(c.Value, &u)
+		return u, err
+	case "float":
+		var f float64
+		err = json.Unmarshal(c.Value, &f)
+		return f, err
+	case "string":
+		var s string
+		err = json.Unmarshal(c.Value, &s)
+		return s, err
+	case "bool":
+		var b bool
+		err = json.Unmarshal(c.Value, &b)
+		return b, err
+	case "bytes":
+		var b []byte
+		err = json.Unmarshal(c.Value, &b)
+		return b, err
+	}
+	return nil, fmt.Errorf("unknown cursor value type %q", c.Type)
+}
+
+// SelectKeyset returns one page of query ordered by the keyset. query is the
+// unordered base query, e.g. "SELECT * FROM trips WHERE rider_id = @rider",
+// and must select the sort columns; the page is read from it as a derived
+// table. cursor is the NextCursor of the previous page ("" for the first).
+//
+//	page, err := database.SelectKeyset[Trip](ctx, client, trips, query, cursor, 20, sql.Named("rider", riderID))
+//	http.APIPaginated(w, page.Items, correlationID, page.NextCursor, 0, 20, 0)
+func SelectKeyset[T any](ctx context.Context, q SQLQuerier, k *Keyset, query, cursor string, pageSize int, args ...interface{}) (*Page[T], error) {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+
+	seek, seekArgs, err := k.Seek(query, cursor)
+	if err != nil {
+		return nil, err
+	}
+	paged := fmt.Sprintf("SELECT TOP (%d) * FROM (%s) AS keyset WHERE %s ORDER BY %s",
+		pageSize+1, query, seek, k.OrderBy())
+
+	items, err := Select[T](ctx, q, paged, append(append([]interface{}(nil), args...), seekArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &Page[T]{Items: items}
+	if len(items) > pageSize {
+		page.Items = items[:pageSize]
+		if page.NextCursor, err = k.Cursor(query, &page.Items[pageSize-1]); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
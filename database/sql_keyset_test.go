@@ -0,0 +1,151 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type keysetTrip struct {
+	ID        int64     `db:"id"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func TestKeyset_Seek(t *testing.T) {
+	k, err := NewKeyset([]byte("secret"), Desc("created_at"), Asc("id"))
+	if err != nil {
+		t.Fatalf("NewKeyset failed: %v", err)
+	}
+	if got := k.OrderBy(); got != "[created_at] DESC, [id] ASC" {
+		t.Errorf("unexpected order by: %s", got)
+	}
+
+	where, args, err := k.Seek("q", "")
+	if err != nil || where != "1 = 1" || args != nil {
+		t.Errorf("unexpected first page seek: %q, %v, %v", where, args, err)
+	}
+
+	created := time.Date(2026, 5, 1, 12, 0, 0, 123, time.UTC)
+	cursor, err := k.Cursor("q", keysetTrip{ID: 42, CreatedAt: created})
+	if err != nil {
+		t.Fatalf("Cursor failed: %v", err)
+	}
+
+	where, args, err = k.Seek("q", cursor)
+	if err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	want := "(([created_at] < @keyset_0) OR ([created_at] = @keyset_0 AND [id] > @keyset_1))"
+	if where != want {
+		t.Errorf("expected %s, got %s", want, where)
+	}
+	if ts := args[0].(sql.NamedArg).Value.(time.Time); !ts.Equal(created) {
+		t.Errorf("expected created_at to round-trip, got %v", ts)
+	}
+	if id := args[1].(sql.NamedArg).Value; id != int64(42) {
+		t.Errorf("expected id 42, got %v (%T)", id, id)
+	}
+}
+
+func TestKeyset_RejectsTamperedCursors(t *testing.T) {
+	k, _ := NewKeyset([]byte("secret"), Asc("id"))
+	cursor, err := k.Cursor("q", keysetTrip{ID: 1})
+	if err != nil {
+		t.Fatalf("Cursor failed: %v", err)
+	}
+
+	other, _ := NewKeyset([]byte("other"), Asc("id"))
+	forged, _ := other.Cursor("q", keysetTrip{ID: 1})
+	payload, signature, _ := strings.Cut(cursor, ".")
+
+	for name, c := range map[string]string{
+		"other scope":  cursor,
+		"other secret": forged,
+		"no signature": payload,
+		"modified":     payload + "x." + signature,
+		"garbage":      "!!!.???",
+	} {
+		scope := "q"
+		if name == "other scope" {
+			scope = "other query"
+		}
+		if _, _, err := k.Seek(scope, c); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: expected ErrInvalidCursor, got %v", name, err)
+		}
+	}
+}
+
+func TestNewKeyset_Validation(t *testing.T) {
+	if _, err := NewKeyset(nil, Asc("id")); err == nil {
+		t.Error("expected an error without secret")
+	}
+	if _, err := NewKeyset([]byte("s")); err == nil {
+		t.Error("expected an error without sort keys")
+	}
+	if _, err := NewKeyset([]byte("s"), Asc("id; DROP TABLE trips")); err == nil {
+		t.Error("expected an error for an invalid column")
+	}
+}
+
+func TestSelectKeyset(t *testing.T) {
+	base := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	var all [][]driver.Value
+	for i := 5; i >= 1; i-- {
+		all = append(all, []driver.Value{int64(i), base.Add(time.Duration(i) * time.Hour)})
+	}
+
+	client, fake := newFakeSQLClient(t, func(query string, args []driver.NamedValue) fakeSQLResult {
+		// Emulate the seek on id DESC and TOP (3)
+		rows := all
+		for _, a := range args {
+			if a.Name == "keyset_0" {
+				rows = nil
+				for _, r := range all {
+					if r[0].(int64) < a.Value.(int64) {
+						rows = append(rows, r)
+					}
+				}
+			}
+		}
+		if len(rows) > 3 {
+			rows = rows[:3]
+		}
+		return fakeSQLResult{columns: []string{"id", "created_at"}, rows: rows}
+	})
+
+	k, _ := NewKeyset([]byte("secret"), Desc("id"))
+	query := "SELECT id, created_at FROM trips WHERE rider_id = @rider"
+
+	page, err := SelectKeyset[keysetTrip](context.Background(), client, k, query, "", 2, sql.Named("rider", "r1"))
+	if err != nil {
+		t.Fatalf("SelectKeyset failed: %v", err)
+	}
+	if len(page.Items) != 2 || page.Items[1].ID != 4 || page.NextCursor == "" {
+		t.Fatalf("unexpected first page: %+v", page)
+	}
+	first := fake.Calls()[0].query
+	if first != "SELECT TOP (3) * FROM (SELECT id, created_at FROM trips WHERE rider_id = @rider) AS keyset WHERE 1 = 1 ORDER BY [id] DESC" {
+		t.Errorf("unexpected query: %s", first)
+	}
+
+	var ids []int64
+	for cursor := page.NextCursor; cursor != ""; cursor = page.NextCursor {
+		if page, err = SelectKeyset[keysetTrip](context.Background(), client, k, query, cursor, 2, sql.Named("rider", "r1")); err != nil {
+			t.Fatalf("SelectKeyset failed: %v", err)
+		}
+		for _, item := range page.Items {
+			ids = append(ids, item.ID)
+		}
+	}
+	if len(ids) != 3 || ids[0] != 3 || ids[2] != 1 {
+		t.Errorf("unexpected remaining ids: %v", ids)
+	}
+
+	if _, err := SelectKeyset[keysetTrip](context.Background(), client, k, "SELECT * FROM payments", page.NextCursor+"x", 2); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("expected ErrInvalidCursor, got %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+// Package database provides database client utilities.
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	mssql "github.com/microsoft/go-mssqldb"
+)
+
+type sqlRouteKey struct{}
+
+type sqlRoute int
+
+const (
+	routeDefault sqlRoute = iota
+	routePrimary
+	routeReplica
+)
+
+// WithReadReplica routes the reads made with ctx to the client's read
+// replica, if it has one. Writes and transactions always use the primary.
+// The replica lags slightly behind, so don't use it to read what was just
+// written.
+func WithReadReplica(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sqlRouteKey{}, routeReplica)
+}
+
+// WithPrimary routes the reads made with ctx to the primary, overriding
+// WithReadReplica and Replica, e.g. to read a row just written.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sqlRouteKey{}, routePrimary)
+}
+
+func sqlRouteFrom(ctx context.Context) sqlRoute {
+	route, _ := ctx.Value(sqlRouteKey{}).(sqlRoute)
+	return route
+}
+
+// Replica returns a querier whose reads go to the read replica, falling back
+// to the primary, and whose writes go to the primary. Use it to choose the
+// replica per call:
+//
+//	trips, err := database.Select[Trip](ctx, client.Replica(), "SELECT * FROM trips WHERE rider_id = @p1", riderID)
+func (c *SQLClient) Replica() SQLQuerier {
+	return sqlReplica{c}
+}
+
+// ReplicaDB returns the read replica's sql.DB, or nil without a replica.
+func (c *SQLClient) ReplicaDB() *sql.DB {
+	return c.replica
+}
+
+type sqlReplica struct {
+	client *SQLClient
+}
+
+func (r sqlReplica) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return r.client.Exec(ctx, query, args...)
+}
+
+func (r sqlReplica) Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if sqlRouteFrom(ctx) == routeDefault {
+		ctx = WithReadReplica(ctx)
+	}
+	return r.client.Query(ctx, query, args...)
+}
+
+// read runs a read on the replica when ctx asks for it and the replica is
+// healthy, and on the primary otherwise. If the replica is unavailable the
+// read is repeated on the primary, and reads skip the replica for
+// ReplicaCooldown.
+func (c *SQLClient) read(ctx context.Context, method, query string, fn func(context.Context, *sql.DB) error) error {
+	if c.useReplica(ctx) {
+		err := c.hooks.run(ctx, &SQLStatement{Method: method, Query: query, Replica: true}, func(ctx context.Context) error {
+			return fn(ctx, c.replica)
+		})
+		if err == nil || ctx.Err() != nil || !replicaUnavailable(err) {
+			return err
+		}
+		c.markReplicaDown()
+	}
+	return c.hooks.run(ctx, &SQLStatement{Method: method, Query: query}, func(ctx context.Context) error {
+		return fn(ctx, c.db)
+	})
+}
+
+func (c *SQLClient) useReplica(ctx context.Context) bool {
+	return c.replica != nil &&
+		sqlRouteFrom(ctx) == routeReplica &&
+		time.Now().UnixNano() >= c.replicaDownUntil.Load()
+}
+
+func (c *SQLClient) markReplicaDown() {
+	cooldown := c.config.ReplicaCooldown
+	if cooldown <= 0 {
+		cooldown = DefaultSQLConfig().ReplicaCooldown
+	}
+	c.replicaDownUntil.Store(time.Now().Add(cooldown).UnixNano())
+}
+
+// replicaUnavailable reports whether err means the replica is unreachable
+// or unusable: a broken connection, a network error or a transient SQL
+// Server error. Other errors, such as those raised by the statement itself,
+// would fail on the primary too and are not retried there.
+func replicaUnavailable(err error) bool {
+	if _, ok := SQLErrorNumber(err); ok {
+		return IsTransientSQLError(err)
+	}
+	var netErr net.Error
+	var streamErr mssql.StreamError
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.As(err, &netErr) ||
+		errors.As(err, &streamErr)
+}
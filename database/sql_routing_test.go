@@ -0,0 +1,137 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+
+	mssql "github.com/microsoft/go-mssqldb"
+)
+
+// newReplicatedSQLClient returns a client whose primary answers "primary"
+// and whose replica answers with replicaErr, or "replica" when it is nil.
+func newReplicatedSQLClient(t *testing.T, replicaErr *error) (*SQLClient, *fakeSQLDB, *fakeSQLDB) {
+	t.Helper()
+	answer := func(name string, err *error) func(string, []driver.NamedValue) fakeSQLResult {
+		return func(string, []driver.NamedValue) fakeSQLResult {
+			if err != nil && *err != nil {
+				return fakeSQLResult{err: *err}
+			}
+			return fakeSQLResult{columns: []string{"server"}, rows: [][]driver.Value{{name}}, affected: 1}
+		}
+	}
+	primary, primaryFake := newFakeSQLDB(t, t.Name()+"/primary", answer("primary", nil))
+	replica, replicaFake := newFakeSQLDB(t, t.Name()+"/replica", answer("replica", replicaErr))
+	return &SQLClient{db: primary, replica: replica, config: DefaultSQLConfig()}, primaryFake, replicaFake
+}
+
+func TestSQLClient_ReplicaRouting(t *testing.T) {
+	client, primary, replica := newReplicatedSQLClient(t, nil)
+	ctx := context.Background()
+
+	server := func(ctx context.Context, q SQLQuerier) string {
+		t.Helper()
+		got, err := Get[string](ctx, q, "SELECT @@SERVERNAME")
+		if err != nil {
+			t.Fatalf("query failed: %v", err)
+		}
+		return *got
+	}
+
+	if got := server(ctx, client); got != "primary" {
+		t.Errorf("reads should default to the primary, got %s", got)
+	}
+	if got := server(WithReadReplica(ctx), client); got != "replica" {
+		t.Errorf("WithReadReplica should read from the replica, got %s", got)
+	}
+	if got := server(ctx, client.Replica()); got != "replica" {
+		t.Errorf("Replica should read from the replica, got %s", got)
+	}
+	if got := server(WithPrimary(ctx), client.Replica()); got != "primary" {
+		t.Errorf("WithPrimary should override Replica, got %s", got)
+	}
+
+	var got string
+	if err := client.QueryRow(WithReadReplica(ctx), "SELECT @@SERVERNAME").Scan(&got); err != nil || got != "replica" {
+		t.Errorf("QueryRow should read from the replica, got %s, %v", got, err)
+	}
+
+	before := len(replica.Calls())
+	if _, err := client.Replica().Exec(WithReadReplica(ctx), "UPDATE trips SET status = 'x'"); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if len(replica.Calls()) != before {
+		t.Error("writes must not run on the replica")
+	}
+	if calls := primary.Calls(); calls[len(calls)-1].query != "UPDATE trips SET status = 'x'" {
+		t.Errorf("expected the write on the primary, got %+v", calls[len(calls)-1])
+	}
+}
+
+func TestSQLClient_ReplicaFallback(t *testing.T) {
+	var replicaErr error = &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+	client, _, replica := newReplicatedSQLClient(t, &replicaErr)
+	ctx := WithReadReplica(context.Background())
+
+	got, err := Get[string](ctx, client, "SELECT @@SERVERNAME")
+	if err != nil || *got != "primary" {
+		t.Fatalf("expected fallback to the primary, got %v, %v", got, err)
+	}
+	if len(replica.Calls()) != 1 {
+		t.Fatalf("expected one replica attempt, got %d", len(replica.Calls()))
+	}
+
+	// The replica is skipped during the cooldown.
+	replicaErr = nil
+	var server string
+	if err := client.QueryRow(ctx, "SELECT @@SERVERNAME").Scan(&server); err != nil || server != "primary" {
+		t.Errorf("expected the primary during cooldown, got %s, %v", server, err)
+	}
+	if len(replica.Calls()) != 1 {
+		t.Errorf("replica should not be used during cooldown, got %d calls", len(replica.Calls()))
+	}
+
+	client.replicaDownUntil.Store(0)
+	if err := client.QueryRow(ctx, "SELECT @@SERVERNAME").Scan(&server); err != nil || server != "replica" {
+		t.Errorf("expected the replica after cooldown, got %s, %v", server, err)
+	}
+}
+
+func TestSQLClient_ReplicaStatementError(t *testing.T) {
+	var replicaErr error = mssql.Error{Number: 208, Message: "Invalid object name 'tripz'."}
+	client, primary, _ := newReplicatedSQLClient(t, &replicaErr)
+
+	_, err := client.Query(WithReadReplica(context.Background()), "SELECT * FROM tripz")
+	var sqlErr mssql.Error
+	if !errors.As(err, &sqlErr) || sqlErr.Number != 208 {
+		t.Fatalf("expected the statement error, got %v", err)
+	}
+	if len(primary.Calls()) != 0 {
+		t.Error("statement errors should not be retried on the primary")
+	}
+	if !client.useReplica(WithReadReplica(context.Background())) {
+		t.Error("statement errors should not take the replica out of rotation")
+	}
+}
+
+func TestReplicaUnavailable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"bad connection", driver.ErrBadConn, true},
+		{"network", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, true},
+		{"transient", mssql.Error{Number: 40613, Message: "Database is not currently available"}, true},
+		{"statement", mssql.Error{Number: 208, Message: "Invalid object name 'tripz'."}, false},
+		{"scan", errors.New("sql: Scan error on column index 0"), false},
+	}
+	for _, tt := range tests {
+		if got := replicaUnavailable(tt.err); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
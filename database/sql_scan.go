@@ -0,0 +1,479 @@
+// Package database provides database client utilities.
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	apperrors "github.com/mycobrun/cobrun-shared/errors"
+)
+
+// SQLQuerier runs statements; *SQLClient and *Transaction implement it, so the
+// helpers below work both inside and outside transactions.
+type SQLQuerier interface {
+	Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+var (
+	_ SQLQuerier = (*SQLClient)(nil)
+	_ SQLQuerier = (*Transaction)(nil)
+)
+
+// Get runs a query and scans its first row into a T. Struct fields are matched
+// to columns by their `db` tag (see ScanRows). Returns ErrNotFound if the
+// query returns no rows.
+//
+//	trip, err := database.Get[Trip](ctx, client, "SELECT * FROM trips WHERE id = @p1", id)
+func Get[T any](ctx context.Context, q SQLQuerier, query string, args ...interface{}) (*T, error) {
+	rows, err := q.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, apperrors.Wrap(ErrNotFound, apperrors.CodeNotFound, "row not found")
+	}
+
+	scanner, err := newRowScanner[T](rows)
+	if err != nil {
+		return nil, err
+	}
+	var item T
+	if err := scanner.scan(rows, &item); err != nil {
+		return nil, err
+	}
+	return &item, rows.Close()
+}
+
+// Select runs a query and scans all rows into a slice of T.
+func Select[T any](ctx context.Context, q SQLQuerier, query string, args ...interface{}) ([]T, error) {
+	rows, err := q.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return ScanRows[T](rows)
+}
+
+// ScanRows scans all rows into a slice of T and closes rows.
+//
+// If T is a struct (and not a sql.Scanner or time.Time), each column is
+// assigned to the field whose `db` tag matches the column name, or, without a
+// tag, whose name matches case-insensitively. Fields tagged `db:"-"` are
+// ignored and fields of embedded structs are promoted. A NULL scanned into a
+// non-pointer field leaves its zero value; use pointers or sql.Null* types to
+// tell NULL apart. Other types of T are scanned from a single column.
+func ScanRows[T any](rows *sql.Rows) ([]T, error) {
+	defer rows.Close()
+
+	scanner, err := newRowScanner[T](rows)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []T{}
+	for rows.Next() {
+		var item T
+		if err := scanner.scan(rows, &item); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// rowScanner maps the columns of a result set to the fields of T.
+type rowScanner struct {
+	// fields holds the index path of the field for each column; nil for a
+	// non-struct T scanned from a single column
+	fields [][]int
+}
+
+func newRowScanner[T any](rows *sql.Rows) (*rowScanner, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if !isStructRow(t) {
+		if len(columns) != 1 {
+			return nil, fmt.Errorf("cannot scan %d columns into %s", len(columns), t)
+		}
+		return &rowScanner{}, nil
+	}
+
+	byName := sqlFieldsOf(t).byName
+	s := &rowScanner{fields: make([][]int, len(columns))}
+	for i, col := range columns {
+		index, ok := byName[strings.ToLower(col)]
+		if !ok {
+			return nil, fmt.Errorf("column %q has no matching field in %s", col, t)
+		}
+		s.fields[i] = index
+	}
+	return s, nil
+}
+
+func (s *rowScanner) scan(rows *sql.Rows, dest interface{}) error {
+	if s.fields == nil {
+		return rows.Scan(dest)
+	}
+
+	v := reflect.ValueOf(dest).Elem()
+	targets := make([]interface{}, len(s.fields))
+	var nullable []int
+	for i, index := range s.fields {
+		field := fieldByIndexAlloc(v, index)
+		if acceptsNull(field.Type()) {
+			targets[i] = field.Addr().Interface()
+			continue
+		}
+		// Scan through a pointer so that NULL leaves the zero value
+		targets[i] = reflect.New(reflect.PointerTo(field.Type())).Interface()
+		nullable = append(nullable, i)
+	}
+
+	if err := rows.Scan(targets...); err != nil {
+		return fmt.Errorf("failed to scan row: %w", err)
+	}
+
+	for _, i := range nullable {
+		ptr := reflect.ValueOf(targets[i]).Elem()
+		if !ptr.IsNil() {
+			fieldByIndexAlloc(v, s.fields[i]).Set(ptr.Elem())
+		}
+	}
+	return nil
+}
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// isStructRow reports whether rows are scanned field by field into t.
+func isStructRow(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != timeType && !reflect.PointerTo(t).Implements(scannerType)
+}
+
+// acceptsNull reports whether database/sql can scan NULL into t directly.
+func acceptsNull(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Slice:
+		return true
+	}
+	return reflect.PointerTo(t).Implements(scannerType)
+}
+
+// fieldByIndexAlloc is reflect.Value.FieldByIndex allocating nil embedded
+// struct pointers on the way.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// sqlField is a struct field mapped to a column.
+type sqlField struct {
+	column     string
+	index      []int
+	omitInsert bool
+}
+
+// sqlFields describes the columns of a struct type.
+type sqlFields struct {
+	// ordered holds the fields in declaration order
+	ordered []sqlField
+	// byName indexes fields by lower-cased column name
+	byName map[string][]int
+}
+
+var sqlFieldCache sync.Map // reflect.Type -> *sqlFields
+
+func sqlFieldsOf(t reflect.Type) *sqlFields {
+	if cached, ok := sqlFieldCache.Load(t); ok {
+		return cached.(*sqlFields)
+	}
+
+	fields := &sqlFields{byName: make(map[string][]int)}
+	collectSQLFields(t, nil, fields)
+	cached, _ := sqlFieldCache.LoadOrStore(t, fields)
+	return cached.(*sqlFields)
+}
+
+func collectSQLFields(t reflect.Type, parent []int, fields *sqlFields) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("db")
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		index := append(append([]int(nil), parent...), i)
+
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && isStructRow(ft) {
+			collectSQLFields(ft, index, fields)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		field := sqlField{column: name, index: index, omitInsert: opts == "omitinsert"}
+		key := strings.ToLower(name)
+		existing, ok := fields.byName[key]
+		if !ok {
+			fields.byName[key] = index
+			fields.ordered = append(fields.ordered, field)
+			continue
+		}
+		// The shallower field wins, as with Go's field promotion
+		if len(index) < len(existing) {
+			fields.byName[key] = index
+			for j := range fields.ordered {
+				if strings.EqualFold(fields.ordered[j].column, name) {
+					fields.ordered[j] = field
+				}
+			}
+		}
+	}
+}
+
+// namedParamPattern matches @name parameters but not @@system variables.
+var namedParamPattern = regexp.MustCompile(`(^|[^@\w])@([A-Za-z_]\w*)`)
+
+// NamedArgs returns the arguments for the @name parameters used in query,
+// taken from arg: a struct (by `db` tag or field name) or a
+// map[string]interface{}. SQL Server binds them by name, so query is passed
+// unchanged.
+//
+//	query := "UPDATE trips SET status = @status WHERE id = @id"
+//	args, err := database.NamedArgs(query, trip)
+//	_, err = client.Exec(ctx, query, args...)
+func NamedArgs(query string, arg interface{}) ([]interface{}, error) {
+	lookup, err := namedLookup(arg)
+	if err != nil {
+		return nil, err
+	}
+
+	var args []interface{}
+	seen := make(map[string]bool)
+	for _, name := range namedParams(query) {
+		key := strings.ToLower(name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		value, ok := lookup(key)
+		if !ok {
+			return nil, fmt.Errorf("no value for parameter @%s", name)
+		}
+		args = append(args, sql.Named(name, value))
+	}
+	return args, nil
+}
+
+// NamedExec executes a statement with @name parameters bound from arg.
+func NamedExec(ctx context.Context, q SQLQuerier, query string, arg interface{}) (sql.Result, error) {
+	args, err := NamedArgs(query, arg)
+	if err != nil {
+		return nil, err
+	}
+	return q.Exec(ctx, query, args...)
+}
+
+// namedParams returns the parameter names in query, skipping string literals,
+// quoted identifiers and comments.
+func namedParams(query string) []string {
+	var names []string
+	for _, part := range splitSQLCode(query) {
+		for _, m := range namedParamPattern.FindAllStringSubmatch(part, -1) {
+			names = append(names, m[2])
+		}
+	}
+	return names
+}
+
+// splitSQLCode returns the parts of query outside '...', [...] and "..."
+// quotes and -- or /* */ comments.
+func splitSQLCode(query string) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(query); i++ {
+		var end string
+		switch {
+		case query[i] == '\'':
+			end = "'"
+		case query[i] == '"':
+			end = `"`
+		case query[i] == '[':
+			end = "]"
+		case strings.HasPrefix(query[i:], "--"):
+			end = "\n"
+		case strings.HasPrefix(query[i:], "/*"):
+			end = "*/"
+		default:
+			continue
+		}
+		parts = append(parts, query[start:i])
+		j := strings.Index(query[i+1:], end)
+		if j < 0 {
+			return parts
+		}
+		i += j + len(end)
+		start = i + 1
+	}
+	return append(parts, query[start:])
+}
+
+func namedLookup(arg interface{}) (func(name string) (interface{}, bool), error) {
+	if m, ok := arg.(map[string]interface{}); ok {
+		lower := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			lower[strings.ToLower(strings.TrimPrefix(k, "@"))] = v
+		}
+		return func(name string) (interface{}, bool) {
+			v, ok := lower[name]
+			return v, ok
+		}, nil
+	}
+
+	v := reflect.ValueOf(arg)
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("named arguments must be a struct or map[string]interface{}, got %T", arg)
+	}
+	byName := sqlFieldsOf(v.Type()).byName
+	return func(name string) (interface{}, bool) {
+		index, ok := byName[name]
+		if !ok {
+			return nil, false
+		}
+		field, err := v.FieldByIndexErr(index)
+		if err != nil {
+			// Field of a nil embedded pointer
+			return nil, true
+		}
+		return field.Interface(), true
+	}, nil
+}
+
+// SQL Server limits a statement to 2100 parameters and a VALUES list to 1000 rows.
+const (
+	sqlMaxParams     = 2100
+	sqlMaxInsertRows = 1000
+)
+
+var sqlTablePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// BulkInsert inserts rows into table using multi-row INSERT statements, as
+// many rows per statement as SQL Server's parameter limit allows. Columns come
+// from the `db` tags of T; tag fields with `db:"name,omitinsert"` to leave
+// them to the database (identity or default columns). The statements are not
+// atomic together; run BulkInsert in WithTransaction if they must be.
+// Returns the number of rows inserted.
+func BulkInsert[T any](ctx context.Context, q SQLQuerier, table string, rows []T) (int64, error) {
+	if !sqlTablePattern.MatchString(table) {
+		return 0, fmt.Errorf("invalid table name %q", table)
+	}
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if !isStructRow(t) {
+		return 0, fmt.Errorf("BulkInsert requires a struct type, got %s", t)
+	}
+
+	var fields []sqlField
+	var columns []string
+	for _, f := range sqlFieldsOf(t).ordered {
+		if !f.omitInsert {
+			fields = append(fields, f)
+			columns = append(columns, "["+f.column+"]")
+		}
+	}
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("%s has no columns to insert", t)
+	}
+
+	perStatement := (sqlMaxParams - 1) / len(fields)
+	if perStatement > sqlMaxInsertRows {
+		perStatement = sqlMaxInsertRows
+	}
+	if perStatement == 0 {
+		return 0, fmt.Errorf("%s has too many columns for one statement", t)
+	}
+
+	prefix := fmt.Sprintf("INSERT INTO [%s] (%s) VALUES ",
+		strings.ReplaceAll(table, ".", "].["), strings.Join(columns, ", "))
+
+	var inserted int64
+	for start := 0; start < len(rows); start += perStatement {
+		end := start + perStatement
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		var b strings.Builder
+		b.WriteString(prefix)
+		args := make([]interface{}, 0, (end-start)*len(fields))
+		for r := start; r < end; r++ {
+			if r > start {
+				b.WriteString(", ")
+			}
+			b.WriteString("(")
+			v := reflect.ValueOf(&rows[r]).Elem()
+			for i, f := range fields {
+				if i > 0 {
+					b.WriteString(", ")
+				}
+				var value interface{}
+				if field, err := v.FieldByIndexErr(f.index); err == nil {
+					value = field.Interface()
+				}
+				args = append(args, value)
+				fmt.Fprintf(&b, "@p%d", len(args))
+			}
+			b.WriteString(")")
+		}
+
+		result, err := q.Exec(ctx, b.String(), args...)
+		if err != nil {
+			return inserted, fmt.Errorf("failed to insert rows %d-%d: %w", start, end-1, err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			n = int64(end - start)
+		}
+		inserted += n
+	}
+	return inserted, nil
+}
@@ -0,0 +1,176 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	apperrors "github.com/mycobrun/cobrun-shared/errors"
+)
+
+type scanAudit struct {
+	CreatedAt time.Time  `db:"created_at"`
+	UpdatedAt *time.Time `db:"updated_at"`
+}
+
+type scanTrip struct {
+	scanAudit
+	ID       int64           `db:"id,omitinsert"`
+	RiderID  string          `db:"rider_id"`
+	Notes    string          `db:"notes"`
+	Fare     sql.NullFloat64 `db:"fare"`
+	Status   string
+	Internal string `db:"-"`
+}
+
+func TestSelect_ScansStructs(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	client, _ := newFakeSQLClient(t, func(string, []driver.NamedValue) fakeSQLResult {
+		return fakeSQLResult{
+			columns: []string{"id", "rider_id", "notes", "fare", "STATUS", "created_at", "updated_at"},
+			rows: [][]driver.Value{
+				{int64(1), "r1", nil, 12.5, "completed", created, created},
+				{int64(2), "r2", "vip", nil, "requested", created, nil},
+			},
+		}
+	})
+
+	trips, err := Select[scanTrip](context.Background(), client, "SELECT * FROM trips")
+	if err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	if len(trips) != 2 {
+		t.Fatalf("expected 2 trips, got %d", len(trips))
+	}
+
+	first, second := trips[0], trips[1]
+	if first.ID != 1 || first.RiderID != "r1" || first.Notes != "" || !first.Fare.Valid || first.Fare.Float64 != 12.5 {
+		t.Errorf("unexpected first trip: %+v", first)
+	}
+	if first.Status != "completed" || !first.CreatedAt.Equal(created) || first.UpdatedAt == nil {
+		t.Errorf("unexpected first trip: %+v", first)
+	}
+	if second.Notes != "vip" || second.Fare.Valid || second.UpdatedAt != nil {
+		t.Errorf("unexpected second trip: %+v", second)
+	}
+}
+
+func TestSelect_Scalars(t *testing.T) {
+	client, _ := newFakeSQLClient(t, func(string, []driver.NamedValue) fakeSQLResult {
+		return fakeSQLResult{columns: []string{"id"}, rows: [][]driver.Value{{"a"}, {"b"}}}
+	})
+
+	ids, err := Select[string](context.Background(), client, "SELECT id FROM trips")
+	if err != nil || len(ids) != 2 || ids[1] != "b" {
+		t.Errorf("unexpected result: %v, %v", ids, err)
+	}
+}
+
+func TestSelect_UnknownColumn(t *testing.T) {
+	client, _ := newFakeSQLClient(t, func(string, []driver.NamedValue) fakeSQLResult {
+		return fakeSQLResult{columns: []string{"id", "surprise"}, rows: [][]driver.Value{{int64(1), "x"}}}
+	})
+
+	if _, err := Select[scanTrip](context.Background(), client, "SELECT * FROM trips"); err == nil || !strings.Contains(err.Error(), "surprise") {
+		t.Errorf("expected an error naming the column, got %v", err)
+	}
+}
+
+func TestGet(t *testing.T) {
+	rows := [][]driver.Value{{int64(7), "r1"}}
+	client, fake := newFakeSQLClient(t, func(string, []driver.NamedValue) fakeSQLResult {
+		return fakeSQLResult{columns: []string{"id", "rider_id"}, rows: rows}
+	})
+
+	trip, err := Get[scanTrip](context.Background(), client, "SELECT id, rider_id FROM trips WHERE id = @p1", 7)
+	if err != nil || trip.ID != 7 || trip.RiderID != "r1" {
+		t.Fatalf("unexpected result: %+v, %v", trip, err)
+	}
+	if calls := fake.Calls(); len(calls) != 1 || calls[0].args[0].Value != 7 {
+		t.Errorf("unexpected calls: %+v", calls)
+	}
+
+	rows = nil
+	_, err = Get[scanTrip](context.Background(), client, "SELECT id, rider_id FROM trips WHERE id = @p1", 8)
+	if !errors.Is(err, ErrNotFound) || !apperrors.IsNotFound(err) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestNamedArgs(t *testing.T) {
+	trip := scanTrip{ID: 3, RiderID: "r1", Status: "completed"}
+	query := `UPDATE trips SET status = @Status, notes = '@notes' /* @fare */ WHERE id = @id AND rider_id = @rider_id AND @id > 0 AND @@ROWCOUNT = 0 -- @x`
+
+	args, err := NamedArgs(query, &trip)
+	if err != nil {
+		t.Fatalf("NamedArgs failed: %v", err)
+	}
+	want := map[string]interface{}{"Status": "completed", "id": int64(3), "rider_id": "r1"}
+	if len(args) != len(want) {
+		t.Fatalf("expected %d args, got %v", len(want), args)
+	}
+	for _, arg := range args {
+		named := arg.(sql.NamedArg)
+		if want[named.Name] != named.Value {
+			t.Errorf("unexpected arg %s=%v", named.Name, named.Value)
+		}
+	}
+
+	args, err = NamedArgs("SELECT * FROM trips WHERE rider_id = @riderId", map[string]interface{}{"@riderId": "r9"})
+	if err != nil || len(args) != 1 || args[0].(sql.NamedArg).Value != "r9" {
+		t.Errorf("unexpected map args: %v, %v", args, err)
+	}
+
+	if _, err := NamedArgs("SELECT * FROM trips WHERE city = @city", trip); err == nil {
+		t.Error("expected an error for a parameter without value")
+	}
+	if _, err := NamedArgs("SELECT 1", 42); err == nil {
+		t.Error("expected an error for a non-struct argument")
+	}
+}
+
+func TestBulkInsert(t *testing.T) {
+	client, fake := newFakeSQLClient(t, func(query string, args []driver.NamedValue) fakeSQLResult {
+		return fakeSQLResult{affected: int64(strings.Count(query, "(")) - 1}
+	})
+
+	type wide struct {
+		A, B, C, D, E, F, G, H, I, J string
+	}
+	rows := make([]wide, 450)
+	n, err := BulkInsert(context.Background(), client, "dbo.wide", rows)
+	if err != nil {
+		t.Fatalf("BulkInsert failed: %v", err)
+	}
+	if n != 450 {
+		t.Errorf("expected 450 rows inserted, got %d", n)
+	}
+
+	calls := fake.Calls()
+	if len(calls) != 3 {
+		t.Fatalf("expected 3 statements for 10 columns, got %d", len(calls))
+	}
+	if !strings.HasPrefix(calls[0].query, "INSERT INTO [dbo].[wide] ([A], [B], ") || len(calls[0].args) != 2090 {
+		t.Errorf("unexpected first statement: %.60s (%d args)", calls[0].query, len(calls[0].args))
+	}
+	if len(calls[2].args) != 10*(450-2*209) {
+		t.Errorf("unexpected last statement args: %d", len(calls[2].args))
+	}
+
+	trips := []scanTrip{{RiderID: "r1", Status: "requested"}}
+	if _, err := BulkInsert(context.Background(), client, "trips", trips); err != nil {
+		t.Fatalf("BulkInsert failed: %v", err)
+	}
+	last := fake.Calls()[3]
+	if strings.Contains(last.query, "[id]") || !strings.Contains(last.query, "([created_at], [updated_at], [rider_id], [notes], [fare], [Status])") {
+		t.Errorf("unexpected columns: %s", last.query)
+	}
+
+	if _, err := BulkInsert(context.Background(), client, "trips; DROP TABLE x", trips); err == nil {
+		t.Error("expected invalid table name to be rejected")
+	}
+}
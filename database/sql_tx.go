@@ -0,0 +1,113 @@
+// Package database provides database client utilities.
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrNestedTransaction is returned when Commit or Rollback is called on a
+// nested transaction. Its work commits with the outermost transaction; return
+// an error from its function to roll it back.
+var ErrNestedTransaction = errors.New("cannot commit or roll back a nested transaction")
+
+// TxOption configures a transaction.
+type TxOption func(*sql.TxOptions)
+
+// WithIsolation sets the transaction's isolation level, e.g.
+// sql.LevelSnapshot to read without blocking writers. Snapshot isolation must
+// be enabled on the database.
+func WithIsolation(level sql.IsolationLevel) TxOption {
+	return func(o *sql.TxOptions) {
+		o.Isolation = level
+	}
+}
+
+func txOptions(opts []TxOption) *sql.TxOptions {
+	if len(opts) == 0 {
+		return nil
+	}
+	o := &sql.TxOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// maxSavepointName is SQL Server's limit on savepoint name length.
+const maxSavepointName = 32
+
+// Savepoint marks a point in the transaction that RollbackTo can return to.
+func (t *Transaction) Savepoint(ctx context.Context, name string) error {
+	if err := validateSavepoint(name); err != nil {
+		return err
+	}
+	if _, err := t.Exec(ctx, "SAVE TRANSACTION ["+name+"]"); err != nil {
+		return fmt.Errorf("failed to create savepoint %s: %w", name, err)
+	}
+	return nil
+}
+
+// RollbackTo undoes the work done since the savepoint name. The transaction
+// stays open.
+func (t *Transaction) RollbackTo(ctx context.Context, name string) error {
+	if err := validateSavepoint(name); err != nil {
+		return err
+	}
+	if _, err := t.Exec(ctx, "ROLLBACK TRANSACTION ["+name+"]"); err != nil {
+		return fmt.Errorf("failed to roll back to savepoint %s: %w", name, err)
+	}
+	return nil
+}
+
+func validateSavepoint(name string) error {
+	if !sqlColumnPattern.MatchString(name) || len(name) > maxSavepointName {
+		return fmt.Errorf("invalid savepoint name %q", name)
+	}
+	return nil
+}
+
+// WithTransaction runs fn in a nested transaction, backed by a savepoint. If
+// fn returns an error its work is rolled back and the enclosing transaction
+// can carry on; otherwise the work commits with the outermost transaction.
+//
+//	err := client.WithTransaction(ctx, func(tx *database.Transaction) error {
+//		// ... complete the trip
+//		if err := tx.WithTransaction(ctx, applyPromotion); err != nil {
+//			log.Printf("promotion not applied: %v", err)
+//		}
+//		return nil
+//	})
+//
+// Some errors, such as being chosen as a deadlock victim, roll back the whole
+// transaction; the rollback to the savepoint then fails too and both errors
+// are returned, so that WithTransactionRetry still retries it.
+func (t *Transaction) WithTransaction(ctx context.Context, fn func(*Transaction) error) error {
+	if t.savepoints == nil {
+		t.savepoints = new(int)
+	}
+	*t.savepoints++
+	name := fmt.Sprintf("sp%d", *t.savepoints)
+
+	if err := t.Savepoint(ctx, name); err != nil {
+		return err
+	}
+	nested := &Transaction{tx: t.tx, hooks: t.hooks, savepoints: t.savepoints, nested: true}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = t.RollbackTo(ctx, name) // best-effort rollback on panic
+			panic(p)
+		}
+	}()
+
+	if err := fn(nested); err != nil {
+		if rbErr := t.RollbackTo(ctx, name); rbErr != nil {
+			return fmt.Errorf("rollback to savepoint failed: %v (original error: %w)", rbErr, err)
+		}
+		return err
+	}
+	return nil
+}
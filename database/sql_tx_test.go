@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	mssql "github.com/microsoft/go-mssqldb"
+)
+
+func callQueries(calls []fakeSQLCall) []string {
+	queries := make([]string, len(calls))
+	for i, call := range calls {
+		queries[i] = call.query
+	}
+	return queries
+}
+
+func TestTransaction_Nested(t *testing.T) {
+	client, fake := newFakeSQLClient(t, nil)
+	ctx := context.Background()
+	failure := errors.New("promotion expired")
+
+	err := client.WithTransaction(ctx, func(tx *Transaction) error {
+		if _, err := tx.Exec(ctx, "UPDATE trips SET status = 'completed'"); err != nil {
+			return err
+		}
+		err := tx.WithTransaction(ctx, func(tx *Transaction) error {
+			if _, err := tx.Exec(ctx, "INSERT INTO promotions_used (id) VALUES (1)"); err != nil {
+				return err
+			}
+			return failure
+		})
+		if !errors.Is(err, failure) {
+			t.Errorf("expected the nested failure, got %v", err)
+		}
+		return tx.WithTransaction(ctx, func(nested *Transaction) error {
+			if err := nested.Commit(); !errors.Is(err, ErrNestedTransaction) {
+				t.Errorf("expected ErrNestedTransaction, got %v", err)
+			}
+			_, err := nested.Exec(ctx, "INSERT INTO receipts (id) VALUES (1)")
+			return err
+		})
+	}, WithIsolation(sql.LevelSnapshot))
+	if err != nil {
+		t.Fatalf("transaction failed: %v", err)
+	}
+
+	want := []string{
+		"BEGIN ISOLATION Snapshot",
+		"UPDATE trips SET status = 'completed'",
+		"SAVE TRANSACTION [sp1]",
+		"INSERT INTO promotions_used (id) VALUES (1)",
+		"ROLLBACK TRANSACTION [sp1]",
+		"SAVE TRANSACTION [sp2]",
+		"INSERT INTO receipts (id) VALUES (1)",
+		"COMMIT",
+	}
+	got := callQueries(fake.Calls())
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected statements:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestTransaction_SavepointName(t *testing.T) {
+	client, _ := newFakeSQLClient(t, nil)
+	ctx := context.Background()
+
+	err := client.WithTransaction(ctx, func(tx *Transaction) error {
+		return tx.Savepoint(ctx, "sp]; DROP TABLE trips; --")
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid savepoint name") {
+		t.Errorf("expected invalid savepoint name, got %v", err)
+	}
+}
+
+func TestWithTransactionRetry_Deadlock(t *testing.T) {
+	deadlocks := 1
+	client, fake := newFakeSQLClient(t, func(query string, _ []driver.NamedValue) fakeSQLResult {
+		if strings.HasPrefix(query, "UPDATE") && deadlocks > 0 {
+			deadlocks--
+			return fakeSQLResult{err: mssql.Error{Number: 1205, Message: "Transaction was deadlocked"}}
+		}
+		if strings.HasPrefix(query, "INSERT") {
+			return fakeSQLResult{err: mssql.Error{Number: 2627, Message: "Violation of PRIMARY KEY constraint"}}
+		}
+		return fakeSQLResult{affected: 1}
+	})
+	ctx := context.Background()
+
+	update := func(tx *Transaction) error {
+		_, err := tx.Exec(ctx, "UPDATE drivers SET status = 'busy'")
+		return err
+	}
+	if err := client.WithTransactionRetry(ctx, update); err != nil {
+		t.Fatalf("expected the deadlock to be retried, got %v", err)
+	}
+	if got := strings.Join(callQueries(fake.Calls()), ","); got != "BEGIN,UPDATE drivers SET status = 'busy',ROLLBACK,BEGIN,UPDATE drivers SET status = 'busy',COMMIT" {
+		t.Errorf("unexpected statements: %s", got)
+	}
+
+	attempts := 0
+	err := client.WithTransactionRetry(ctx, func(tx *Transaction) error {
+		attempts++
+		_, err := tx.Exec(ctx, "INSERT INTO trips (id) VALUES (1)")
+		return err
+	})
+	if number, _ := SQLErrorNumber(err); number != 2627 || attempts != 1 {
+		t.Errorf("constraint violations should not be retried, got %v after %d attempts", err, attempts)
+	}
+}
@@ -0,0 +1,355 @@
+// Package fraud provides fraud signal detection for rideshare operations.
+package fraud
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mycobrun/cobrun-shared/geo"
+	"github.com/mycobrun/cobrun-shared/logging"
+)
+
+// SignalType identifies a kind of location fraud signal.
+type SignalType string
+
+const (
+	// SignalImpossibleSpeed means two fixes imply a speed no vehicle can reach.
+	SignalImpossibleSpeed SignalType = "impossible_speed"
+	// SignalTeleport means the device jumped a long distance between fixes.
+	SignalTeleport SignalType = "teleport"
+	// SignalSyntheticTrack means the track is too regular to be real GPS.
+	SignalSyntheticTrack SignalType = "synthetic_track"
+	// SignalDistanceMismatch means the reported distance disagrees with the route.
+	SignalDistanceMismatch SignalType = "distance_mismatch"
+)
+
+// Signal is a single scored piece of fraud evidence.
+type Signal struct {
+	Type SignalType `json:"type"`
+	// Score is the confidence that the signal indicates fraud (0-1).
+	Score       float64                `json:"score"`
+	Description string                 `json:"description"`
+	Location    *geo.Point             `json:"location,omitempty"`
+	Timestamp   time.Time              `json:"timestamp,omitempty"`
+	Details     map[string]interface{} `json:"details,omitempty"`
+}
+
+// Assessment is the combined result of analysing a driver's locations.
+type Assessment struct {
+	DriverID string   `json:"driver_id"`
+	TripID   string   `json:"trip_id,omitempty"`
+	Signals  []Signal `json:"signals"`
+	// Score combines the signal scores as independent evidence (0-1).
+	Score float64 `json:"score"`
+	// Flagged is set when Score reaches the configured flag threshold.
+	Flagged bool `json:"flagged"`
+}
+
+// LocationAnalyzerConfig holds location fraud detection configuration.
+type LocationAnalyzerConfig struct {
+	// MaxSpeedMPS is the fastest plausible speed between two fixes.
+	MaxSpeedMPS float64
+	// TeleportMeters is the jump distance above which an impossible move is a teleport.
+	TeleportMeters float64
+	// SyntheticMinPoints is the minimum track length checked for regularity.
+	SyntheticMinPoints int
+	// SyntheticMaxVariation is the coefficient of variation of step length and
+	// interval below which a track is considered machine-generated.
+	SyntheticMaxVariation float64
+	// DistanceTolerance is the accepted relative difference between reported
+	// and route-derived distance.
+	DistanceTolerance float64
+	// FlagThreshold is the assessment score at which a driver is flagged.
+	FlagThreshold float64
+}
+
+// DefaultLocationAnalyzerConfig returns sensible defaults.
+func DefaultLocationAnalyzerConfig() LocationAnalyzerConfig {
+	return LocationAnalyzerConfig{
+		MaxSpeedMPS:           70, // ~250 km/h
+		TeleportMeters:        5000,
+		SyntheticMinPoints:    10,
+		SyntheticMaxVariation: 0.01,
+		DistanceTolerance:     0.25,
+		FlagThreshold:         0.8,
+	}
+}
+
+// TrackInput is the evidence analysed for a driver, typically one trip.
+type TrackInput struct {
+	DriverID string
+	TripID   string
+	Points   []geo.TracePoint
+	// ReportedDistanceMeters is the distance claimed by the driver app (0 skips the check).
+	ReportedDistanceMeters float64
+	// Route is the expected route the reported distance is compared against.
+	// When empty, the cleaned track distance is used.
+	Route []geo.Point
+}
+
+// LocationAnalyzer detects GPS spoofing and impossible travel.
+type LocationAnalyzer struct {
+	config LocationAnalyzerConfig
+	audit  *logging.AuditLogger
+	traces *geo.TraceProcessor
+
+	mu   sync.Mutex
+	last map[string]geo.TracePoint
+}
+
+// NewLocationAnalyzer creates a new location analyzer.
+// If audit is non-nil, flagged assessments are logged as fraud security events.
+func NewLocationAnalyzer(config LocationAnalyzerConfig, audit *logging.AuditLogger) *LocationAnalyzer {
+	return &LocationAnalyzer{
+		config: config,
+		audit:  audit,
+		traces: geo.NewTraceProcessor(geo.DefaultTraceProcessorConfig()),
+		last:   make(map[string]geo.TracePoint),
+	}
+}
+
+// Analyze checks a complete track for all signal types.
+func (a *LocationAnalyzer) Analyze(ctx context.Context, input TrackInput) *Assessment {
+	points := append([]geo.TracePoint(nil), input.Points...)
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].Timestamp.Before(points[j].Timestamp)
+	})
+
+	var signals []Signal
+	for i := 1; i < len(points); i++ {
+		if signal, ok := a.checkMove(points[i-1], points[i]); ok {
+			signals = append(signals, signal)
+		}
+	}
+	if signal, ok := a.checkSynthetic(points); ok {
+		signals = append(signals, signal)
+	}
+	if signal, ok := a.checkDistance(input, points); ok {
+		signals = append(signals, signal)
+	}
+
+	return a.assess(ctx, input.DriverID, input.TripID, signals)
+}
+
+// Observe checks a single fix against the previous fix seen for the driver.
+// Fixes older than the previous one are ignored.
+func (a *LocationAnalyzer) Observe(ctx context.Context, driverID string, point geo.TracePoint) *Assessment {
+	a.mu.Lock()
+	prev, ok := a.last[driverID]
+	if !ok || !point.Timestamp.Before(prev.Timestamp) {
+		a.last[driverID] = point
+	}
+	a.mu.Unlock()
+
+	var signals []Signal
+	if ok && point.Timestamp.After(prev.Timestamp) {
+		if signal, found := a.checkMove(prev, point); found {
+			signals = append(signals, signal)
+		}
+	}
+
+	return a.assess(ctx, driverID, "", signals)
+}
+
+// Forget drops the streaming state for a driver, e.g. when they go offline.
+func (a *LocationAnalyzer) Forget(driverID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.last, driverID)
+}
+
+// checkMove scores the move between two consecutive fixes.
+func (a *LocationAnalyzer) checkMove(prev, cur geo.TracePoint) (Signal, bool) {
+	distance := geo.HaversineDistanceMeters(prev.Point, cur.Point)
+	dt := cur.Timestamp.Sub(prev.Timestamp).Seconds()
+
+	// Allow for the reported inaccuracy of both fixes
+	slack := prev.Accuracy + cur.Accuracy
+	effective := math.Max(0, distance-slack)
+
+	var speed float64
+	if dt > 0 {
+		speed = effective / dt
+	} else if effective > 0 {
+		speed = math.Inf(1)
+	}
+	if speed <= a.config.MaxSpeedMPS {
+		return Signal{}, false
+	}
+
+	location := cur.Point
+	signal := Signal{
+		Location:  &location,
+		Timestamp: cur.Timestamp,
+		Details: map[string]interface{}{
+			"distance_meters":  distance,
+			"interval_seconds": dt,
+		},
+	}
+	if !math.IsInf(speed, 1) {
+		signal.Details["speed_mps"] = speed
+	}
+
+	if distance >= a.config.TeleportMeters {
+		signal.Type = SignalTeleport
+		signal.Score = 0.9
+		signal.Description = fmt.Sprintf("Jumped %.1fkm in %.0fs", distance/geo.MetersPerKm, dt)
+		return signal, true
+	}
+
+	// Scale with how far over the limit the move is: 2x the limit scores 0.8
+	ratio := speed / a.config.MaxSpeedMPS
+	signal.Type = SignalImpossibleSpeed
+	signal.Score = math.Min(0.95, 0.4*ratio)
+	signal.Description = fmt.Sprintf("Implied speed %.0f m/s exceeds %.0f m/s", speed, a.config.MaxSpeedMPS)
+	return signal, true
+}
+
+// checkSynthetic flags tracks whose step lengths and intervals are almost
+// perfectly uniform, as produced by mock location apps replaying a route.
+func (a *LocationAnalyzer) checkSynthetic(points []geo.TracePoint) (Signal, bool) {
+	if a.config.SyntheticMinPoints <= 0 || len(points) < a.config.SyntheticMinPoints {
+		return Signal{}, false
+	}
+
+	steps := make([]float64, 0, len(points)-1)
+	intervals := make([]float64, 0, len(points)-1)
+	for i := 1; i < len(points); i++ {
+		steps = append(steps, geo.HaversineDistanceMeters(points[i-1].Point, points[i].Point))
+		intervals = append(intervals, points[i].Timestamp.Sub(points[i-1].Timestamp).Seconds())
+	}
+
+	// A stationary device is legitimately regular
+	stepMean, stepCV := meanAndVariation(steps)
+	if stepMean < 1 {
+		return Signal{}, false
+	}
+	_, intervalCV := meanAndVariation(intervals)
+
+	if stepCV > a.config.SyntheticMaxVariation || intervalCV > a.config.SyntheticMaxVariation {
+		return Signal{}, false
+	}
+
+	score := 0.6
+	if constantAccuracy(points) {
+		score = 0.75
+	}
+
+	return Signal{
+		Type:        SignalSyntheticTrack,
+		Score:       score,
+		Description: fmt.Sprintf("%d fixes with uniform %.1fm steps", len(points), stepMean),
+		Timestamp:   points[len(points)-1].Timestamp,
+		Details: map[string]interface{}{
+			"step_variation":     stepCV,
+			"interval_variation": intervalCV,
+			"points":             len(points),
+		},
+	}, true
+}
+
+// checkDistance compares the reported distance with the route-derived one.
+func (a *LocationAnalyzer) checkDistance(input TrackInput, points []geo.TracePoint) (Signal, bool) {
+	if input.ReportedDistanceMeters <= 0 {
+		return Signal{}, false
+	}
+
+	var expected float64
+	if len(input.Route) > 1 {
+		expected = geo.RouteLengthMeters(input.Route)
+	} else if len(points) > 1 {
+		expected = a.traces.Process(points).DistanceMeters
+	}
+	if expected <= 0 {
+		return Signal{}, false
+	}
+
+	diff := (input.ReportedDistanceMeters - expected) / expected
+	if math.Abs(diff) <= a.config.DistanceTolerance {
+		return Signal{}, false
+	}
+
+	// Inflated distances are what fraud looks like; deflated ones are usually bugs
+	score := math.Min(0.9, math.Abs(diff))
+	if diff < 0 {
+		score /= 2
+	}
+
+	return Signal{
+		Type:        SignalDistanceMismatch,
+		Score:       score,
+		Description: fmt.Sprintf("Reported %.0fm but route is %.0fm", input.ReportedDistanceMeters, expected),
+		Details: map[string]interface{}{
+			"reported_meters": input.ReportedDistanceMeters,
+			"expected_meters": expected,
+			"difference":      diff,
+		},
+	}, true
+}
+
+func (a *LocationAnalyzer) assess(ctx context.Context, driverID, tripID string, signals []Signal) *Assessment {
+	assessment := &Assessment{
+		DriverID: driverID,
+		TripID:   tripID,
+		Signals:  signals,
+		Score:    CombineScores(signals),
+	}
+	assessment.Flagged = len(signals) > 0 && assessment.Score >= a.config.FlagThreshold
+
+	if assessment.Flagged && a.audit != nil {
+		types := make([]string, len(signals))
+		for i, s := range signals {
+			types[i] = string(s.Type)
+		}
+		a.audit.LogSecurityEvent(ctx, logging.AuditEventFraudDetected, "", "", map[string]interface{}{
+			"driver_id": driverID,
+			"trip_id":   tripID,
+			"score":     assessment.Score,
+			"signals":   types,
+		})
+	}
+
+	return assessment
+}
+
+// CombineScores combines signal scores as independent evidence: 1 - Π(1 - score).
+func CombineScores(signals []Signal) float64 {
+	remaining := 1.0
+	for _, s := range signals {
+		remaining *= 1 - math.Max(0, math.Min(1, s.Score))
+	}
+	return 1 - remaining
+}
+
+func meanAndVariation(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if mean == 0 {
+		return 0, 0
+	}
+
+	var sq float64
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sq/float64(len(values))) / math.Abs(mean)
+}
+
+func constantAccuracy(points []geo.TracePoint) bool {
+	for _, p := range points[1:] {
+		if p.Accuracy != points[0].Accuracy {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,150 @@
+package fraud
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mycobrun/cobrun-shared/geo"
+	"github.com/mycobrun/cobrun-shared/logging"
+	"github.com/mycobrun/cobrun-shared/testing/fixtures"
+)
+
+// noisyTrack is a plausible ~15 m/s drive north with irregular fixes.
+func noisyTrack(start time.Time) []geo.TracePoint {
+	var points []geo.TracePoint
+	elapsed := 0.0
+	for i := 0; i < 30; i++ {
+		elapsed += 1 + float64(i%3)*0.4
+		jitter := float64(i%5-2) * 0.00002
+		points = append(points, geo.TracePoint{
+			Point:     geo.NewPoint(47.6062+elapsed*0.000135+jitter, -122.3321+jitter),
+			Timestamp: start.Add(time.Duration(elapsed * float64(time.Second))),
+			Accuracy:  5 + float64(i%4),
+		})
+	}
+	return points
+}
+
+func TestLocationAnalyzer_CleanTrack(t *testing.T) {
+	analyzer := NewLocationAnalyzer(DefaultLocationAnalyzerConfig(), nil)
+	assessment := analyzer.Analyze(context.Background(), TrackInput{
+		DriverID: "driver-1",
+		Points:   noisyTrack(time.Now()),
+	})
+
+	if len(assessment.Signals) != 0 || assessment.Flagged {
+		t.Errorf("expected clean track, got %+v", assessment.Signals)
+	}
+}
+
+func TestLocationAnalyzer_Teleport(t *testing.T) {
+	points := noisyTrack(time.Now())
+	points[15].Lat += 0.1 // ~11km
+
+	analyzer := NewLocationAnalyzer(DefaultLocationAnalyzerConfig(), nil)
+	assessment := analyzer.Analyze(context.Background(), TrackInput{DriverID: "driver-1", Points: points})
+
+	// Jump away and jump back
+	teleports := 0
+	for _, s := range assessment.Signals {
+		if s.Type == SignalTeleport {
+			teleports++
+		}
+	}
+	if teleports != 2 {
+		t.Fatalf("expected 2 teleport signals, got %+v", assessment.Signals)
+	}
+	if !assessment.Flagged || assessment.Score < 0.99 {
+		t.Errorf("expected flagged assessment, got score %f", assessment.Score)
+	}
+}
+
+func TestLocationAnalyzer_ImpossibleSpeed(t *testing.T) {
+	analyzer := NewLocationAnalyzer(DefaultLocationAnalyzerConfig(), nil)
+	start := time.Now()
+	ctx := context.Background()
+
+	if a := analyzer.Observe(ctx, "driver-1", geo.TracePoint{Point: geo.NewPoint(47.6062, -122.3321), Timestamp: start}); len(a.Signals) != 0 {
+		t.Fatalf("expected no signals for first fix, got %+v", a.Signals)
+	}
+
+	// ~1.1km in 5s is ~220 m/s
+	a := analyzer.Observe(ctx, "driver-1", geo.TracePoint{Point: geo.NewPoint(47.6162, -122.3321), Timestamp: start.Add(5 * time.Second)})
+	if len(a.Signals) != 1 || a.Signals[0].Type != SignalImpossibleSpeed {
+		t.Fatalf("expected impossible speed signal, got %+v", a.Signals)
+	}
+	if a.Signals[0].Score < 0.9 {
+		t.Errorf("expected high score for 3x the speed limit, got %f", a.Signals[0].Score)
+	}
+
+	analyzer.Forget("driver-1")
+	if a := analyzer.Observe(ctx, "driver-1", geo.TracePoint{Point: geo.NewPoint(47.7, -122.3321), Timestamp: start.Add(6 * time.Second)}); len(a.Signals) != 0 {
+		t.Errorf("expected no signals after forget, got %+v", a.Signals)
+	}
+}
+
+func TestLocationAnalyzer_SyntheticTrack(t *testing.T) {
+	// The fixture batch is perfectly regular, like a mock location app
+	batch := fixtures.NewLocationBatch("driver-1", 30)
+
+	analyzer := NewLocationAnalyzer(DefaultLocationAnalyzerConfig(), nil)
+	assessment := analyzer.Analyze(context.Background(), TrackInput{DriverID: batch.DriverID, Points: batch.TracePoints()})
+
+	if len(assessment.Signals) != 1 || assessment.Signals[0].Type != SignalSyntheticTrack {
+		t.Fatalf("expected synthetic track signal, got %+v", assessment.Signals)
+	}
+	if assessment.Signals[0].Score != 0.75 {
+		t.Errorf("expected constant accuracy to raise the score, got %f", assessment.Signals[0].Score)
+	}
+}
+
+func TestLocationAnalyzer_DistanceMismatch(t *testing.T) {
+	route := []geo.Point{geo.NewPoint(47.60, -122.33), geo.NewPoint(47.61, -122.33)} // ~1.1km
+	analyzer := NewLocationAnalyzer(DefaultLocationAnalyzerConfig(), nil)
+
+	ok := analyzer.Analyze(context.Background(), TrackInput{DriverID: "driver-1", ReportedDistanceMeters: 1200, Route: route})
+	if len(ok.Signals) != 0 {
+		t.Errorf("expected distance within tolerance, got %+v", ok.Signals)
+	}
+
+	inflated := analyzer.Analyze(context.Background(), TrackInput{DriverID: "driver-1", ReportedDistanceMeters: 2000, Route: route})
+	if len(inflated.Signals) != 1 || inflated.Signals[0].Type != SignalDistanceMismatch {
+		t.Fatalf("expected distance mismatch signal, got %+v", inflated.Signals)
+	}
+	if inflated.Signals[0].Score < 0.7 {
+		t.Errorf("expected high score for ~80%% inflation, got %f", inflated.Signals[0].Score)
+	}
+}
+
+func TestLocationAnalyzer_AuditsFlagged(t *testing.T) {
+	var buf bytes.Buffer
+	audit := logging.NewAuditLogger(logging.AuditLoggerConfig{
+		ServiceName: "test-service",
+		Logger:      slog.New(slog.NewJSONHandler(&buf, nil)),
+	})
+
+	points := noisyTrack(time.Now())
+	points[10].Lat += 0.1
+
+	analyzer := NewLocationAnalyzer(DefaultLocationAnalyzerConfig(), audit)
+	analyzer.Analyze(context.Background(), TrackInput{DriverID: "driver-1", TripID: "trip-1", Points: points})
+
+	if !strings.Contains(buf.String(), string(logging.AuditEventFraudDetected)) {
+		t.Errorf("expected fraud audit event, got %q", buf.String())
+	}
+}
+
+func TestCombineScores(t *testing.T) {
+	got := CombineScores([]Signal{{Score: 0.5}, {Score: 0.5}})
+	if math.Abs(got-0.75) > 1e-9 {
+		t.Errorf("expected 0.75, got %f", got)
+	}
+	if CombineScores(nil) != 0 {
+		t.Error("expected zero score for no signals")
+	}
+}
@@ -182,6 +182,33 @@ func FindNearestPoints(center Point, points []Point, radiusKm float64) []Point {
 	return nearest
 }
 
+// projectOntoSegment returns the point on segment a-b closest to p and the
+// fraction (0-1) along the segment. Uses a local equirectangular projection,
+// which is accurate for the short segments found in routes and geofences.
+func projectOntoSegment(p, a, b Point) (Point, float64) {
+	cosLat := math.Cos(degreesToRadians(p.Lat))
+	ax, ay := (a.Lng-p.Lng)*cosLat, a.Lat-p.Lat
+	bx, by := (b.Lng-p.Lng)*cosLat, b.Lat-p.Lat
+
+	dx, dy := bx-ax, by-ay
+	lenSq := dx*dx + dy*dy
+	if lenSq == 0 {
+		return a, 0
+	}
+
+	t := -(ax*dx + ay*dy) / lenSq
+	if t < 0 {
+		t = 0
+	} else if t > 1 {
+		t = 1
+	}
+
+	return Point{
+		Lat: a.Lat + (b.Lat-a.Lat)*t,
+		Lng: a.Lng + (b.Lng-a.Lng)*t,
+	}, t
+}
+
 // Helper functions
 
 func degreesToRadians(degrees float64) float64 {
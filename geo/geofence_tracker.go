@@ -0,0 +1,323 @@
+// Package geo provides geospatial utilities.
+package geo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// GeofenceEventType identifies a geofence membership transition.
+type GeofenceEventType string
+
+const (
+	// GeofenceEventEnter is emitted when an entity is confirmed inside a geofence.
+	GeofenceEventEnter GeofenceEventType = "enter"
+	// GeofenceEventExit is emitted when an entity is confirmed outside a geofence it was in.
+	GeofenceEventExit GeofenceEventType = "exit"
+	// GeofenceEventDwell is emitted once an entity has stayed inside a geofence for the dwell threshold.
+	GeofenceEventDwell GeofenceEventType = "dwell"
+)
+
+// GeofenceEvent describes an enter, exit or dwell transition.
+type GeofenceEvent struct {
+	Type         GeofenceEventType `json:"type"`
+	EntityID     string            `json:"entity_id"`
+	TripID       string            `json:"trip_id,omitempty"`
+	GeofenceID   string            `json:"geofence_id"`
+	GeofenceName string            `json:"geofence_name,omitempty"`
+	GeofenceType string            `json:"geofence_type,omitempty"`
+	Location     Point             `json:"location"`
+	Timestamp    time.Time         `json:"timestamp"`
+	// DwellTime is the time spent inside the geofence (exit and dwell events).
+	DwellTime time.Duration `json:"dwell_time,omitempty"`
+}
+
+// GeofenceEventHandler receives geofence events as they are detected.
+type GeofenceEventHandler func(ctx context.Context, event GeofenceEvent) error
+
+// LocationUpdate is a single position report for a tracked entity.
+type LocationUpdate struct {
+	// EntityID is the driver (or trip) whose membership is tracked.
+	EntityID string
+	// TripID is attached to emitted events when set.
+	TripID    string
+	Location  Point
+	Timestamp time.Time
+}
+
+// GeofenceState is the membership state of one entity in one geofence.
+type GeofenceState struct {
+	Inside    bool      `json:"inside"`
+	EnteredAt time.Time `json:"entered_at,omitempty"`
+	// Pending is set while an opposite observation waits out MinDwell.
+	Pending       bool      `json:"pending,omitempty"`
+	PendingSince  time.Time `json:"pending_since,omitempty"`
+	DwellNotified bool      `json:"dwell_notified,omitempty"`
+}
+
+// GeofenceMembership is the persisted membership state of one entity.
+type GeofenceMembership struct {
+	EntityID  string                    `json:"entity_id"`
+	Fences    map[string]*GeofenceState `json:"fences"`
+	UpdatedAt time.Time                 `json:"updated_at"`
+}
+
+// GeofenceStateStore persists membership state between location updates.
+// Get returns nil, nil when the entity has no state yet.
+type GeofenceStateStore interface {
+	Get(ctx context.Context, entityID string) (*GeofenceMembership, error)
+	Save(ctx context.Context, membership *GeofenceMembership) error
+	Delete(ctx context.Context, entityID string) error
+}
+
+// GeofenceTrackerConfig holds geofence tracker configuration.
+type GeofenceTrackerConfig struct {
+	// HysteresisMeters is the width of the band around a boundary in which
+	// observations are ignored, so GPS jitter at the border does not flap.
+	HysteresisMeters float64
+	// MinDwell is how long an opposite observation must persist before the
+	// transition is confirmed.
+	MinDwell time.Duration
+	// DwellThreshold emits a dwell event after this long inside (0 disables).
+	DwellThreshold time.Duration
+}
+
+// DefaultGeofenceTrackerConfig returns sensible defaults.
+func DefaultGeofenceTrackerConfig() GeofenceTrackerConfig {
+	return GeofenceTrackerConfig{
+		HysteresisMeters: 25,
+		MinDwell:         10 * time.Second,
+		DwellThreshold:   5 * time.Minute,
+	}
+}
+
+// GeofenceTracker turns a stream of location updates into enter, exit and
+// dwell events. Updates for the same entity must be delivered in order.
+type GeofenceTracker struct {
+	fences  *GeofenceCollection
+	store   GeofenceStateStore
+	handler GeofenceEventHandler
+	config  GeofenceTrackerConfig
+}
+
+// NewGeofenceTracker creates a new geofence tracker.
+// If store is nil, an in-memory store is used. handler may be nil.
+func NewGeofenceTracker(fences *GeofenceCollection, store GeofenceStateStore, handler GeofenceEventHandler, config GeofenceTrackerConfig) *GeofenceTracker {
+	if store == nil {
+		store = NewInMemoryGeofenceStateStore()
+	}
+	return &GeofenceTracker{
+		fences:  fences,
+		store:   store,
+		handler: handler,
+		config:  config,
+	}
+}
+
+// Update applies a location update and returns the events it produced.
+// Events are also delivered to the handler, in order.
+func (t *GeofenceTracker) Update(ctx context.Context, update LocationUpdate) ([]GeofenceEvent, error) {
+	if update.EntityID == "" {
+		return nil, fmt.Errorf("entity ID is required")
+	}
+	if update.Timestamp.IsZero() {
+		update.Timestamp = time.Now()
+	}
+
+	membership, err := t.store.Get(ctx, update.EntityID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load geofence state: %w", err)
+	}
+	if membership == nil {
+		membership = &GeofenceMembership{EntityID: update.EntityID}
+	}
+	if membership.Fences == nil {
+		membership.Fences = make(map[string]*GeofenceState)
+	}
+
+	var events []GeofenceEvent
+	active := make(map[string]bool, len(t.fences.Geofences))
+
+	for _, gf := range t.fences.Geofences {
+		active[gf.ID] = true
+		state := membership.Fences[gf.ID]
+		if state == nil {
+			state = &GeofenceState{}
+		}
+
+		if event, ok := t.evaluate(gf, state, update); ok {
+			events = append(events, event)
+		}
+
+		if state.Inside || state.Pending {
+			membership.Fences[gf.ID] = state
+		} else {
+			delete(membership.Fences, gf.ID)
+		}
+	}
+
+	// Drop state for geofences that are no longer configured
+	for id := range membership.Fences {
+		if !active[id] {
+			delete(membership.Fences, id)
+		}
+	}
+
+	membership.UpdatedAt = update.Timestamp
+	if err := t.store.Save(ctx, membership); err != nil {
+		return nil, fmt.Errorf("failed to save geofence state: %w", err)
+	}
+
+	if t.handler != nil {
+		for _, event := range events {
+			if err := t.handler(ctx, event); err != nil {
+				return events, fmt.Errorf("geofence event handler failed: %w", err)
+			}
+		}
+	}
+
+	return events, nil
+}
+
+// evaluate advances the state of one geofence and reports any event.
+func (t *GeofenceTracker) evaluate(gf *Geofence, state *GeofenceState, update LocationUpdate) (GeofenceEvent, bool) {
+	inside := gf.Contains(update.Location)
+
+	// Inside the hysteresis band the observation is ambiguous; keep the current state
+	if t.config.HysteresisMeters > 0 && gf.Polygon != nil &&
+		gf.Polygon.DistanceToBoundaryMeters(update.Location) < t.config.HysteresisMeters {
+		state.Pending = false
+		return GeofenceEvent{}, false
+	}
+
+	if inside == state.Inside {
+		state.Pending = false
+		if inside && t.config.DwellThreshold > 0 && !state.DwellNotified &&
+			update.Timestamp.Sub(state.EnteredAt) >= t.config.DwellThreshold {
+			state.DwellNotified = true
+			event := newGeofenceEvent(GeofenceEventDwell, gf, update)
+			event.DwellTime = update.Timestamp.Sub(state.EnteredAt)
+			return event, true
+		}
+		return GeofenceEvent{}, false
+	}
+
+	if !state.Pending {
+		state.Pending = true
+		state.PendingSince = update.Timestamp
+	}
+	if update.Timestamp.Sub(state.PendingSince) < t.config.MinDwell {
+		return GeofenceEvent{}, false
+	}
+
+	// Transition confirmed
+	if inside {
+		state.Inside = true
+		state.EnteredAt = state.PendingSince
+		state.Pending = false
+		state.DwellNotified = false
+		return newGeofenceEvent(GeofenceEventEnter, gf, update), true
+	}
+
+	event := newGeofenceEvent(GeofenceEventExit, gf, update)
+	event.DwellTime = state.PendingSince.Sub(state.EnteredAt)
+	*state = GeofenceState{}
+	return event, true
+}
+
+// Remove clears all tracked state for an entity (e.g. when a driver goes offline).
+// No exit events are emitted.
+func (t *GeofenceTracker) Remove(ctx context.Context, entityID string) error {
+	return t.store.Delete(ctx, entityID)
+}
+
+// Membership returns the geofence IDs the entity is currently confirmed inside.
+func (t *GeofenceTracker) Membership(ctx context.Context, entityID string) ([]string, error) {
+	membership, err := t.store.Get(ctx, entityID)
+	if err != nil {
+		return nil, err
+	}
+	if membership == nil {
+		return nil, nil
+	}
+
+	var ids []string
+	for id, state := range membership.Fences {
+		if state.Inside {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func newGeofenceEvent(eventType GeofenceEventType, gf *Geofence, update LocationUpdate) GeofenceEvent {
+	return GeofenceEvent{
+		Type:         eventType,
+		EntityID:     update.EntityID,
+		TripID:       update.TripID,
+		GeofenceID:   gf.ID,
+		GeofenceName: gf.Name,
+		GeofenceType: gf.Type,
+		Location:     update.Location,
+		Timestamp:    update.Timestamp,
+	}
+}
+
+// InMemoryGeofenceStateStore keeps geofence membership state in process memory.
+// Use for testing or single-instance deployments.
+type InMemoryGeofenceStateStore struct {
+	mu   sync.RWMutex
+	data map[string]*GeofenceMembership
+}
+
+// NewInMemoryGeofenceStateStore creates a new in-memory state store.
+func NewInMemoryGeofenceStateStore() *InMemoryGeofenceStateStore {
+	return &InMemoryGeofenceStateStore{
+		data: make(map[string]*GeofenceMembership),
+	}
+}
+
+// Get returns a copy of the stored membership state.
+func (s *InMemoryGeofenceStateStore) Get(ctx context.Context, entityID string) (*GeofenceMembership, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m, ok := s.data[entityID]
+	if !ok {
+		return nil, nil
+	}
+	return m.clone(), nil
+}
+
+// Save stores the membership state.
+func (s *InMemoryGeofenceStateStore) Save(ctx context.Context, membership *GeofenceMembership) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data[membership.EntityID] = membership.clone()
+	return nil
+}
+
+// Delete removes the membership state.
+func (s *InMemoryGeofenceStateStore) Delete(ctx context.Context, entityID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.data, entityID)
+	return nil
+}
+
+func (m *GeofenceMembership) clone() *GeofenceMembership {
+	c := &GeofenceMembership{
+		EntityID:  m.EntityID,
+		Fences:    make(map[string]*GeofenceState, len(m.Fences)),
+		UpdatedAt: m.UpdatedAt,
+	}
+	for id, state := range m.Fences {
+		s := *state
+		c.Fences[id] = &s
+	}
+	return c
+}
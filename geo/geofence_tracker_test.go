@@ -0,0 +1,189 @@
+package geo
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// airportQueue is a ~1.1km square zone.
+func airportQueue() *GeofenceCollection {
+	gc := NewGeofenceCollection()
+	gc.Add(&Geofence{
+		ID:   "sfo-queue",
+		Name: "SFO Queue",
+		Type: "airport_queue",
+		Polygon: NewPolygon([]Point{
+			{Lat: 37.610, Lng: -122.390},
+			{Lat: 37.610, Lng: -122.380},
+			{Lat: 37.620, Lng: -122.380},
+			{Lat: 37.620, Lng: -122.390},
+		}),
+	})
+	return gc
+}
+
+var (
+	queueCenter  = Point{Lat: 37.615, Lng: -122.385}
+	queueOutside = Point{Lat: 37.600, Lng: -122.385}
+	// ~5m inside the southern edge
+	queueBorder = Point{Lat: 37.61005, Lng: -122.385}
+)
+
+func TestGeofenceTracker_EnterExit(t *testing.T) {
+	ctx := context.Background()
+	var delivered []GeofenceEvent
+	handler := func(ctx context.Context, e GeofenceEvent) error {
+		delivered = append(delivered, e)
+		return nil
+	}
+
+	tracker := NewGeofenceTracker(airportQueue(), nil, handler, GeofenceTrackerConfig{
+		HysteresisMeters: 20,
+		MinDwell:         10 * time.Second,
+	})
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	steps := []struct {
+		point  Point
+		offset time.Duration
+		want   GeofenceEventType
+	}{
+		{queueOutside, 0, ""},
+		{queueCenter, 5 * time.Second, ""},                  // pending enter
+		{queueCenter, 15 * time.Second, GeofenceEventEnter}, // confirmed after MinDwell
+		{queueCenter, 60 * time.Second, ""},                 // still inside
+		{queueOutside, 70 * time.Second, ""},                // pending exit
+		{queueOutside, 80 * time.Second, GeofenceEventExit}, // confirmed
+		{queueOutside, 120 * time.Second, ""},
+	}
+
+	for i, step := range steps {
+		events, err := tracker.Update(ctx, LocationUpdate{
+			EntityID:  "driver-1",
+			TripID:    "trip-1",
+			Location:  step.point,
+			Timestamp: start.Add(step.offset),
+		})
+		if err != nil {
+			t.Fatalf("step %d: unexpected error: %v", i, err)
+		}
+		if step.want == "" {
+			if len(events) != 0 {
+				t.Errorf("step %d: expected no events, got %v", i, events)
+			}
+			continue
+		}
+		if len(events) != 1 || events[0].Type != step.want {
+			t.Fatalf("step %d: expected %s event, got %v", i, step.want, events)
+		}
+		if events[0].GeofenceID != "sfo-queue" || events[0].TripID != "trip-1" {
+			t.Errorf("step %d: unexpected event fields %+v", i, events[0])
+		}
+	}
+
+	if len(delivered) != 2 {
+		t.Fatalf("expected 2 delivered events, got %d", len(delivered))
+	}
+	exit := delivered[1]
+	if exit.DwellTime != 65*time.Second {
+		t.Errorf("expected exit dwell time 65s, got %v", exit.DwellTime)
+	}
+}
+
+func TestGeofenceTracker_Hysteresis(t *testing.T) {
+	ctx := context.Background()
+	tracker := NewGeofenceTracker(airportQueue(), nil, nil, GeofenceTrackerConfig{
+		HysteresisMeters: 20,
+	})
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		events, err := tracker.Update(ctx, LocationUpdate{
+			EntityID:  "driver-1",
+			Location:  queueBorder,
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(events) != 0 {
+			t.Fatalf("expected border points to be ignored, got %v", events)
+		}
+	}
+
+	ids, err := tracker.Membership(ctx, "driver-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ids) != 0 {
+		t.Errorf("expected no membership, got %v", ids)
+	}
+}
+
+func TestGeofenceTracker_Dwell(t *testing.T) {
+	ctx := context.Background()
+	tracker := NewGeofenceTracker(airportQueue(), nil, nil, GeofenceTrackerConfig{
+		DwellThreshold: time.Minute,
+	})
+
+	start := time.Now()
+	var types []GeofenceEventType
+	for _, offset := range []time.Duration{0, 30 * time.Second, 61 * time.Second, 90 * time.Second} {
+		events, err := tracker.Update(ctx, LocationUpdate{
+			EntityID:  "driver-1",
+			Location:  queueCenter,
+			Timestamp: start.Add(offset),
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, e := range events {
+			types = append(types, e.Type)
+		}
+	}
+
+	if len(types) != 2 || types[0] != GeofenceEventEnter || types[1] != GeofenceEventDwell {
+		t.Errorf("expected [enter dwell], got %v", types)
+	}
+}
+
+func TestGeofenceTracker_Remove(t *testing.T) {
+	ctx := context.Background()
+	tracker := NewGeofenceTracker(airportQueue(), nil, nil, GeofenceTrackerConfig{})
+
+	if _, err := tracker.Update(ctx, LocationUpdate{EntityID: "driver-1", Location: queueCenter}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ids, _ := tracker.Membership(ctx, "driver-1")
+	if len(ids) != 1 {
+		t.Fatalf("expected membership in 1 geofence, got %v", ids)
+	}
+
+	if err := tracker.Remove(ctx, "driver-1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ids, _ = tracker.Membership(ctx, "driver-1")
+	if len(ids) != 0 {
+		t.Errorf("expected no membership after remove, got %v", ids)
+	}
+}
+
+func TestGeofenceTracker_RequiresEntityID(t *testing.T) {
+	tracker := NewGeofenceTracker(airportQueue(), nil, nil, GeofenceTrackerConfig{})
+	if _, err := tracker.Update(context.Background(), LocationUpdate{Location: queueCenter}); err == nil {
+		t.Error("expected error for missing entity ID")
+	}
+}
+
+func TestPolygon_DistanceToBoundaryMeters(t *testing.T) {
+	poly := airportQueue().Geofences[0].Polygon
+
+	if d := poly.DistanceToBoundaryMeters(queueBorder); d < 3 || d > 8 {
+		t.Errorf("expected ~5.5m to boundary, got %f", d)
+	}
+	// Center is ~440m from the east/west edges
+	if d := poly.DistanceToBoundaryMeters(queueCenter); d < 400 || d > 600 {
+		t.Errorf("expected ~440-560m to boundary, got %f", d)
+	}
+}
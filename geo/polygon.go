@@ -135,6 +135,27 @@ func (p *Polygon) Perimeter() float64 {
 	return perimeter
 }
 
+// DistanceToBoundaryMeters returns the distance in meters from a point to
+// the nearest edge of the polygon, regardless of whether it is inside.
+func (p *Polygon) DistanceToBoundaryMeters(point Point) float64 {
+	if len(p.Points) == 0 {
+		return math.Inf(1)
+	}
+	if len(p.Points) == 1 {
+		return HaversineDistanceMeters(point, p.Points[0])
+	}
+
+	minDist := math.Inf(1)
+	n := len(p.Points)
+	for i := 0; i < n; i++ {
+		closest, _ := projectOntoSegment(point, p.Points[i], p.Points[(i+1)%n])
+		if d := HaversineDistanceMeters(point, closest); d < minDist {
+			minDist = d
+		}
+	}
+	return minDist
+}
+
 // IsValid checks if the polygon is valid (at least 3 points, closed).
 func (p *Polygon) IsValid() bool {
 	if len(p.Points) < 3 {
@@ -0,0 +1,363 @@
+// Package geo provides geospatial utilities.
+package geo
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// DefaultPolylinePrecision is the precision used by Google encoded polylines (1e5).
+const DefaultPolylinePrecision = 5
+
+// metersPerDegree is the length of one degree of latitude on the mean Earth sphere.
+const metersPerDegree = EarthRadiusKm * MetersPerKm * math.Pi / 180
+
+// EncodePolyline encodes points using the Google encoded polyline algorithm.
+func EncodePolyline(points []Point) string {
+	return EncodePolylineWithPrecision(points, DefaultPolylinePrecision)
+}
+
+// EncodePolylineWithPrecision encodes points with the given decimal precision
+// (5 for Google Maps, 6 for OSRM/Valhalla).
+func EncodePolylineWithPrecision(points []Point, precision int) string {
+	factor := math.Pow10(precision)
+
+	var sb strings.Builder
+	sb.Grow(len(points) * 8)
+
+	var prevLat, prevLng int64
+	for _, p := range points {
+		lat := int64(math.Round(p.Lat * factor))
+		lng := int64(math.Round(p.Lng * factor))
+
+		encodePolylineValue(&sb, lat-prevLat)
+		encodePolylineValue(&sb, lng-prevLng)
+
+		prevLat, prevLng = lat, lng
+	}
+
+	return sb.String()
+}
+
+func encodePolylineValue(sb *strings.Builder, v int64) {
+	shifted := v << 1
+	if v < 0 {
+		shifted = ^shifted
+	}
+	for shifted >= 0x20 {
+		sb.WriteByte(byte((0x20 | (shifted & 0x1f)) + 63))
+		shifted >>= 5
+	}
+	sb.WriteByte(byte(shifted + 63))
+}
+
+// DecodePolyline decodes a Google encoded polyline.
+func DecodePolyline(encoded string) ([]Point, error) {
+	return DecodePolylineWithPrecision(encoded, DefaultPolylinePrecision)
+}
+
+// DecodePolylineWithPrecision decodes an encoded polyline with the given precision.
+func DecodePolylineWithPrecision(encoded string, precision int) ([]Point, error) {
+	factor := math.Pow10(precision)
+	points := make([]Point, 0, len(encoded)/4)
+
+	var lat, lng int64
+	for i := 0; i < len(encoded); {
+		dLat, next, err := decodePolylineValue(encoded, i)
+		if err != nil {
+			return nil, err
+		}
+		dLng, next, err := decodePolylineValue(encoded, next)
+		if err != nil {
+			return nil, err
+		}
+		i = next
+
+		lat += dLat
+		lng += dLng
+		points = append(points, Point{
+			Lat: float64(lat) / factor,
+			Lng: float64(lng) / factor,
+		})
+	}
+
+	return points, nil
+}
+
+func decodePolylineValue(encoded string, i int) (int64, int, error) {
+	var result int64
+	var shift uint
+	for {
+		if i >= len(encoded) {
+			return 0, i, fmt.Errorf("invalid polyline: truncated at position %d", i)
+		}
+		b := int64(encoded[i]) - 63
+		i++
+		if b < 0 || b > 0x3f {
+			return 0, i, fmt.Errorf("invalid polyline: unexpected character at position %d", i-1)
+		}
+		result |= (b & 0x1f) << shift
+		shift += 5
+		if b < 0x20 {
+			break
+		}
+		if shift > 60 {
+			return 0, i, fmt.Errorf("invalid polyline: value overflow at position %d", i)
+		}
+	}
+
+	if result&1 != 0 {
+		return ^(result >> 1), i, nil
+	}
+	return result >> 1, i, nil
+}
+
+// RouteLengthMeters returns the total length of a route in meters.
+func RouteLengthMeters(points []Point) float64 {
+	var total float64
+	for i := 1; i < len(points); i++ {
+		total += HaversineDistanceMeters(points[i-1], points[i])
+	}
+	return total
+}
+
+// SimplifyDouglasPeucker simplifies a route with the Douglas-Peucker algorithm.
+// Points closer than toleranceMeters to the simplified line are removed.
+// The first and last points are always kept.
+func SimplifyDouglasPeucker(points []Point, toleranceMeters float64) []Point {
+	if len(points) < 3 || toleranceMeters <= 0 {
+		return append([]Point(nil), points...)
+	}
+
+	keep := make([]bool, len(points))
+	keep[0], keep[len(points)-1] = true, true
+
+	// Iterative to avoid deep recursion on long traces
+	type span struct{ first, last int }
+	stack := []span{{0, len(points) - 1}}
+
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		maxDist := 0.0
+		index := -1
+		for i := s.first + 1; i < s.last; i++ {
+			closest, _ := projectOntoSegment(points[i], points[s.first], points[s.last])
+			if d := HaversineDistanceMeters(points[i], closest); d > maxDist {
+				maxDist = d
+				index = i
+			}
+		}
+
+		if index >= 0 && maxDist > toleranceMeters {
+			keep[index] = true
+			stack = append(stack, span{s.first, index}, span{index, s.last})
+		}
+	}
+
+	result := make([]Point, 0, len(points))
+	for i, p := range points {
+		if keep[i] {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// SimplifyVisvalingam simplifies a route with the Visvalingam-Whyatt algorithm.
+// Points whose effective triangle area is below toleranceMeters² are removed,
+// smallest first. The first and last points are always kept.
+func SimplifyVisvalingam(points []Point, toleranceMeters float64) []Point {
+	if len(points) < 3 || toleranceMeters <= 0 {
+		return append([]Point(nil), points...)
+	}
+
+	threshold := toleranceMeters * toleranceMeters
+	n := len(points)
+
+	prev := make([]int, n)
+	next := make([]int, n)
+	removed := make([]bool, n)
+	nodes := make([]*vwNode, n)
+	h := make(vwHeap, 0, n-2)
+
+	for i := range points {
+		prev[i] = i - 1
+		next[i] = i + 1
+	}
+	for i := 1; i < n-1; i++ {
+		nodes[i] = &vwNode{
+			index:     i,
+			area:      triangleAreaMeters(points[i-1], points[i], points[i+1]),
+			heapIndex: len(h),
+		}
+		h = append(h, nodes[i])
+	}
+	heap.Init(&h)
+
+	for h.Len() > 0 {
+		node := heap.Pop(&h).(*vwNode)
+		if node.area >= threshold {
+			break
+		}
+		removed[node.index] = true
+
+		p, nx := prev[node.index], next[node.index]
+		next[p] = nx
+		prev[nx] = p
+
+		// Recompute neighbours; an area never drops below the one just removed
+		for _, j := range []int{p, nx} {
+			if j <= 0 || j >= n-1 {
+				continue
+			}
+			area := triangleAreaMeters(points[prev[j]], points[j], points[next[j]])
+			if area < node.area {
+				area = node.area
+			}
+			nodes[j].area = area
+			heap.Fix(&h, nodes[j].heapIndex)
+		}
+	}
+
+	result := make([]Point, 0, n)
+	for i, p := range points {
+		if !removed[i] {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// triangleAreaMeters returns the area in square meters of the triangle a-b-c,
+// using a local equirectangular projection centered on b.
+func triangleAreaMeters(a, b, c Point) float64 {
+	cosLat := math.Cos(degreesToRadians(b.Lat))
+	ax, ay := (a.Lng-b.Lng)*cosLat*metersPerDegree, (a.Lat-b.Lat)*metersPerDegree
+	cx, cy := (c.Lng-b.Lng)*cosLat*metersPerDegree, (c.Lat-b.Lat)*metersPerDegree
+	return math.Abs(ax*cy-cx*ay) / 2
+}
+
+type vwNode struct {
+	index     int
+	area      float64
+	heapIndex int
+}
+
+type vwHeap []*vwNode
+
+func (h vwHeap) Len() int           { return len(h) }
+func (h vwHeap) Less(i, j int) bool { return h[i].area < h[j].area }
+func (h vwHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].heapIndex = i
+	h[j].heapIndex = j
+}
+
+func (h *vwHeap) Push(x interface{}) {
+	node := x.(*vwNode)
+	node.heapIndex = len(*h)
+	*h = append(*h, node)
+}
+
+func (h *vwHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	node := old[n-1]
+	*h = old[:n-1]
+	return node
+}
+
+// ResampleRoute returns points spaced intervalMeters apart along the route,
+// interpolating between the original vertices. The first and last points are kept.
+func ResampleRoute(points []Point, intervalMeters float64) []Point {
+	if len(points) < 2 || intervalMeters <= 0 {
+		return append([]Point(nil), points...)
+	}
+
+	result := []Point{points[0]}
+	carried := 0.0 // distance travelled since the last emitted point
+
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		segLen := HaversineDistanceMeters(a, b)
+		if segLen == 0 {
+			continue
+		}
+
+		pos := intervalMeters - carried
+		for pos <= segLen {
+			result = append(result, interpolate(a, b, pos/segLen))
+			pos += intervalMeters
+		}
+		carried = segLen - (pos - intervalMeters)
+	}
+
+	last := points[len(points)-1]
+	if HaversineDistanceMeters(result[len(result)-1], last) > 0 {
+		result = append(result, last)
+	}
+	return result
+}
+
+// interpolate returns the point at fraction t (0-1) between a and b.
+func interpolate(a, b Point, t float64) Point {
+	return Point{
+		Lat: a.Lat + (b.Lat-a.Lat)*t,
+		Lng: a.Lng + (b.Lng-a.Lng)*t,
+	}
+}
+
+// RouteProjection describes the nearest point on a route to a location.
+type RouteProjection struct {
+	// Point is the closest point on the route.
+	Point Point `json:"point"`
+	// SegmentIndex is the index of the segment start vertex.
+	SegmentIndex int `json:"segment_index"`
+	// DistanceMeters is the distance from the location to the route.
+	DistanceMeters float64 `json:"distance_meters"`
+	// DistanceAlongMeters is the route distance from the start to Point.
+	DistanceAlongMeters float64 `json:"distance_along_meters"`
+	// Fraction is DistanceAlongMeters divided by the route length (0-1).
+	Fraction float64 `json:"fraction"`
+}
+
+// ProjectOntoRoute finds the point on the route nearest to p.
+// Returns false if the route has no points.
+func ProjectOntoRoute(route []Point, p Point) (RouteProjection, bool) {
+	switch len(route) {
+	case 0:
+		return RouteProjection{}, false
+	case 1:
+		return RouteProjection{
+			Point:          route[0],
+			DistanceMeters: HaversineDistanceMeters(p, route[0]),
+		}, true
+	}
+
+	best := RouteProjection{DistanceMeters: math.Inf(1)}
+	along := 0.0
+	for i := 1; i < len(route); i++ {
+		a, b := route[i-1], route[i]
+		segLen := HaversineDistanceMeters(a, b)
+
+		closest, t := projectOntoSegment(p, a, b)
+		if d := HaversineDistanceMeters(p, closest); d < best.DistanceMeters {
+			best = RouteProjection{
+				Point:               closest,
+				SegmentIndex:        i - 1,
+				DistanceMeters:      d,
+				DistanceAlongMeters: along + segLen*t,
+			}
+		}
+		along += segLen
+	}
+
+	if along > 0 {
+		best.Fraction = best.DistanceAlongMeters / along
+	}
+	return best, true
+}
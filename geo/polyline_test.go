@@ -0,0 +1,182 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+// Reference example from the Google encoded polyline documentation.
+const googleExamplePolyline = "_p~iF~ps|U_ulLnnqC_mqNvxq`@"
+
+var googleExamplePoints = []Point{
+	{Lat: 38.5, Lng: -120.2},
+	{Lat: 40.7, Lng: -120.95},
+	{Lat: 43.252, Lng: -126.453},
+}
+
+func TestEncodePolyline(t *testing.T) {
+	if got := EncodePolyline(googleExamplePoints); got != googleExamplePolyline {
+		t.Errorf("expected %q, got %q", googleExamplePolyline, got)
+	}
+	if got := EncodePolyline(nil); got != "" {
+		t.Errorf("expected empty string for no points, got %q", got)
+	}
+}
+
+func TestDecodePolyline(t *testing.T) {
+	points, err := DecodePolyline(googleExamplePolyline)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(points) != len(googleExamplePoints) {
+		t.Fatalf("expected %d points, got %d", len(googleExamplePoints), len(points))
+	}
+	for i, p := range points {
+		if math.Abs(p.Lat-googleExamplePoints[i].Lat) > 1e-9 || math.Abs(p.Lng-googleExamplePoints[i].Lng) > 1e-9 {
+			t.Errorf("point %d: expected %v, got %v", i, googleExamplePoints[i], p)
+		}
+	}
+}
+
+func TestDecodePolyline_Invalid(t *testing.T) {
+	tests := []string{
+		"_p~iF~ps|U_ulLnnqC_mqNvxq", // truncated
+		"_p~iF\x01",                 // invalid character
+	}
+	for _, encoded := range tests {
+		if _, err := DecodePolyline(encoded); err == nil {
+			t.Errorf("expected error decoding %q", encoded)
+		}
+	}
+}
+
+func TestPolylineRoundTrip_Precision6(t *testing.T) {
+	points := []Point{{Lat: 37.774929, Lng: -122.419416}, {Lat: 37.787994, Lng: -122.407437}}
+	decoded, err := DecodePolylineWithPrecision(EncodePolylineWithPrecision(points, 6), 6)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := range points {
+		if math.Abs(decoded[i].Lat-points[i].Lat) > 1e-7 || math.Abs(decoded[i].Lng-points[i].Lng) > 1e-7 {
+			t.Errorf("point %d: expected %v, got %v", i, points[i], decoded[i])
+		}
+	}
+}
+
+// zigzagRoute heads east with small lateral wobble and one real turn north.
+func zigzagRoute() []Point {
+	var route []Point
+	for i := 0; i <= 20; i++ {
+		wobble := 0.00002 // ~2m
+		if i%2 == 0 {
+			wobble = -wobble
+		}
+		route = append(route, Point{Lat: 37.77 + wobble, Lng: -122.42 + float64(i)*0.0005})
+	}
+	for i := 1; i <= 10; i++ {
+		route = append(route, Point{Lat: 37.77 + float64(i)*0.0005, Lng: -122.41})
+	}
+	return route
+}
+
+func TestSimplifyDouglasPeucker(t *testing.T) {
+	route := zigzagRoute()
+	simplified := SimplifyDouglasPeucker(route, 10)
+
+	if len(simplified) != 3 {
+		t.Fatalf("expected start, corner and end, got %d points", len(simplified))
+	}
+	if simplified[0] != route[0] || simplified[2] != route[len(route)-1] {
+		t.Error("expected endpoints to be kept")
+	}
+
+	// At 1m the wobble is kept but the straight northern leg still collapses
+	if got := SimplifyDouglasPeucker(route, 1); len(got) != 22 {
+		t.Errorf("expected 22 points at 1m tolerance, got %d", len(got))
+	}
+}
+
+func TestSimplifyVisvalingam(t *testing.T) {
+	route := zigzagRoute()
+	simplified := SimplifyVisvalingam(route, 20)
+
+	if len(simplified) >= len(route)/2 {
+		t.Fatalf("expected wobble to be removed, got %d of %d points", len(simplified), len(route))
+	}
+	if simplified[0] != route[0] || simplified[len(simplified)-1] != route[len(route)-1] {
+		t.Error("expected endpoints to be kept")
+	}
+
+	corner := Point{Lat: 37.77, Lng: -122.41}
+	found := false
+	for _, p := range simplified {
+		if HaversineDistanceMeters(p, corner) < 5 {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected the corner to survive simplification")
+	}
+}
+
+func TestRouteLengthMeters(t *testing.T) {
+	a := Point{Lat: 37.77, Lng: -122.42}
+	b := Point{Lat: 37.78, Lng: -122.42}
+	c := Point{Lat: 37.79, Lng: -122.42}
+
+	got := RouteLengthMeters([]Point{a, b, c})
+	want := HaversineDistanceMeters(a, c)
+	if math.Abs(got-want) > 0.01 {
+		t.Errorf("expected %f, got %f", want, got)
+	}
+	if RouteLengthMeters([]Point{a}) != 0 {
+		t.Error("expected zero length for a single point")
+	}
+}
+
+func TestResampleRoute(t *testing.T) {
+	route := []Point{{Lat: 37.77, Lng: -122.42}, {Lat: 37.78, Lng: -122.42}, {Lat: 37.78, Lng: -122.41}}
+	resampled := ResampleRoute(route, 100)
+
+	total := RouteLengthMeters(route)
+	expected := int(total/100) + 2
+	if len(resampled) < expected-1 || len(resampled) > expected {
+		t.Errorf("expected ~%d points, got %d", expected, len(resampled))
+	}
+
+	// Interior spacing along the route is the interval
+	for i := 1; i < len(resampled)-1; i++ {
+		p1, _ := ProjectOntoRoute(route, resampled[i-1])
+		p2, _ := ProjectOntoRoute(route, resampled[i])
+		if gap := p2.DistanceAlongMeters - p1.DistanceAlongMeters; math.Abs(gap-100) > 1 {
+			t.Errorf("point %d: expected 100m spacing, got %f", i, gap)
+		}
+	}
+
+	if resampled[len(resampled)-1] != route[len(route)-1] {
+		t.Error("expected last point to be kept")
+	}
+}
+
+func TestProjectOntoRoute(t *testing.T) {
+	route := []Point{{Lat: 37.77, Lng: -122.42}, {Lat: 37.77, Lng: -122.41}}
+
+	// ~111m north of the route midpoint
+	proj, ok := ProjectOntoRoute(route, Point{Lat: 37.771, Lng: -122.415})
+	if !ok {
+		t.Fatal("expected projection")
+	}
+	if math.Abs(proj.DistanceMeters-111) > 2 {
+		t.Errorf("expected ~111m from route, got %f", proj.DistanceMeters)
+	}
+	if math.Abs(proj.Fraction-0.5) > 0.01 {
+		t.Errorf("expected fraction ~0.5, got %f", proj.Fraction)
+	}
+	if proj.SegmentIndex != 0 {
+		t.Errorf("expected segment 0, got %d", proj.SegmentIndex)
+	}
+
+	if _, ok := ProjectOntoRoute(nil, route[0]); ok {
+		t.Error("expected no projection for empty route")
+	}
+}
@@ -0,0 +1,315 @@
+// Package geo provides geospatial utilities.
+package geo
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// TracePoint is a timestamped GPS fix.
+type TracePoint struct {
+	Point
+	Timestamp time.Time `json:"timestamp"`
+	Accuracy  float64   `json:"accuracy,omitempty"` // meters (1 sigma)
+	Speed     float64   `json:"speed,omitempty"`    // meters per second, as reported
+	Heading   float64   `json:"heading,omitempty"`  // degrees
+	// Interpolated marks points synthesized to fill a gap.
+	Interpolated bool `json:"interpolated,omitempty"`
+}
+
+// TraceProcessorConfig holds GPS trace cleaning configuration.
+type TraceProcessorConfig struct {
+	// MaxSpeedMPS rejects points implying a faster speed from the previous accepted point.
+	MaxSpeedMPS float64
+	// MaxAccuracyMeters rejects fixes reporting worse accuracy (0 disables).
+	MaxAccuracyMeters float64
+	// MaxConsecutiveRejects re-anchors on the current point after this many
+	// rejections in a row, in case the anchor itself was the outlier.
+	MaxConsecutiveRejects int
+	// DefaultAccuracyMeters is assumed for fixes that do not report accuracy.
+	DefaultAccuracyMeters float64
+	// AccelerationNoise is the Kalman process noise in m/s² (0 disables smoothing).
+	AccelerationNoise float64
+	// GapThreshold is the time between fixes above which the gap is interpolated.
+	GapThreshold time.Duration
+	// GapFillInterval is the spacing of interpolated points inside a gap.
+	GapFillInterval time.Duration
+	// MinMovementMeters ignores movement below this distance when summing,
+	// so a stationary device does not accumulate jitter.
+	MinMovementMeters float64
+}
+
+// DefaultTraceProcessorConfig returns defaults tuned for vehicle traces.
+func DefaultTraceProcessorConfig() TraceProcessorConfig {
+	return TraceProcessorConfig{
+		MaxSpeedMPS:           55, // ~200 km/h
+		MaxAccuracyMeters:     100,
+		MaxConsecutiveRejects: 3,
+		DefaultAccuracyMeters: 10,
+		AccelerationNoise:     2,
+		GapThreshold:          15 * time.Second,
+		GapFillInterval:       5 * time.Second,
+		MinMovementMeters:     10,
+	}
+}
+
+// TraceResult is the outcome of processing a GPS trace.
+type TraceResult struct {
+	Points             []TracePoint  `json:"points"`
+	DistanceMeters     float64       `json:"distance_meters"`
+	RawDistanceMeters  float64       `json:"raw_distance_meters"`
+	Duration           time.Duration `json:"duration"`
+	OutliersRemoved    int           `json:"outliers_removed"`
+	PointsInterpolated int           `json:"points_interpolated"`
+}
+
+// DurationSeconds returns the duration in whole seconds, as stored on trips.
+func (r *TraceResult) DurationSeconds() int {
+	return int(r.Duration.Seconds())
+}
+
+// Route returns the cleaned trace as plain points.
+func (r *TraceResult) Route() []Point {
+	route := make([]Point, len(r.Points))
+	for i, p := range r.Points {
+		route[i] = p.Point
+	}
+	return route
+}
+
+// TraceProcessor cleans raw GPS traces and computes trip distance.
+type TraceProcessor struct {
+	config TraceProcessorConfig
+}
+
+// NewTraceProcessor creates a new trace processor.
+func NewTraceProcessor(config TraceProcessorConfig) *TraceProcessor {
+	return &TraceProcessor{config: config}
+}
+
+// Process cleans a trace: removes invalid fixes and speed outliers, smooths
+// with a Kalman filter, fills gaps and computes distance and duration.
+// The input slice is not modified.
+func (tp *TraceProcessor) Process(points []TracePoint) *TraceResult {
+	result := &TraceResult{}
+
+	sorted := tp.sanitize(points)
+	result.RawDistanceMeters = traceLength(sorted)
+
+	accepted := tp.removeOutliers(sorted)
+	result.OutliersRemoved = len(sorted) - len(accepted)
+
+	smoothed := tp.smooth(accepted)
+	filled := tp.fillGaps(smoothed)
+	result.PointsInterpolated = len(filled) - len(smoothed)
+
+	result.Points = filled
+	result.DistanceMeters = tp.distance(filled)
+	if len(filled) > 1 {
+		result.Duration = filled[len(filled)-1].Timestamp.Sub(filled[0].Timestamp)
+	}
+
+	return result
+}
+
+// sanitize sorts by time and drops invalid, inaccurate and duplicate fixes.
+func (tp *TraceProcessor) sanitize(points []TracePoint) []TracePoint {
+	out := make([]TracePoint, 0, len(points))
+	for _, p := range points {
+		if !p.IsValid() || (p.Lat == 0 && p.Lng == 0) {
+			continue
+		}
+		if tp.config.MaxAccuracyMeters > 0 && p.Accuracy > tp.config.MaxAccuracyMeters {
+			continue
+		}
+		out = append(out, p)
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Timestamp.Before(out[j].Timestamp)
+	})
+
+	deduped := out[:0]
+	for i, p := range out {
+		if i > 0 && p.Timestamp.Equal(deduped[len(deduped)-1].Timestamp) {
+			continue
+		}
+		deduped = append(deduped, p)
+	}
+	return deduped
+}
+
+// removeOutliers drops fixes whose implied speed from the previous accepted
+// fix exceeds MaxSpeedMPS.
+func (tp *TraceProcessor) removeOutliers(points []TracePoint) []TracePoint {
+	if len(points) == 0 || tp.config.MaxSpeedMPS <= 0 {
+		return points
+	}
+
+	accepted := []TracePoint{points[0]}
+	rejected := 0
+
+	for _, p := range points[1:] {
+		anchor := accepted[len(accepted)-1]
+		dt := p.Timestamp.Sub(anchor.Timestamp).Seconds()
+		speed := HaversineDistanceMeters(anchor.Point, p.Point) / dt
+
+		if speed <= tp.config.MaxSpeedMPS {
+			accepted = append(accepted, p)
+			rejected = 0
+			continue
+		}
+
+		rejected++
+		if tp.config.MaxConsecutiveRejects > 0 && rejected > tp.config.MaxConsecutiveRejects {
+			// The anchor is more likely wrong than every fix after it
+			accepted[len(accepted)-1] = p
+			rejected = 0
+		}
+	}
+
+	return accepted
+}
+
+// smooth applies a constant-velocity Kalman filter in a local metric frame.
+func (tp *TraceProcessor) smooth(points []TracePoint) []TracePoint {
+	if len(points) < 2 || tp.config.AccelerationNoise <= 0 {
+		return append([]TracePoint(nil), points...)
+	}
+
+	origin := points[0].Point
+	cosLat := math.Cos(degreesToRadians(origin.Lat))
+
+	var east, north kalmanAxis
+	out := make([]TracePoint, len(points))
+
+	for i, p := range points {
+		x := (p.Lng - origin.Lng) * cosLat * metersPerDegree
+		y := (p.Lat - origin.Lat) * metersPerDegree
+
+		accuracy := p.Accuracy
+		if accuracy <= 0 {
+			accuracy = tp.config.DefaultAccuracyMeters
+		}
+		if accuracy <= 0 {
+			accuracy = 1
+		}
+		r := accuracy * accuracy
+
+		if i == 0 {
+			east.init(x, r)
+			north.init(y, r)
+		} else {
+			dt := p.Timestamp.Sub(points[i-1].Timestamp).Seconds()
+			q := tp.config.AccelerationNoise * tp.config.AccelerationNoise
+			east.predict(dt, q)
+			north.predict(dt, q)
+			east.update(x, r)
+			north.update(y, r)
+		}
+
+		sp := p
+		sp.Lat = origin.Lat + north.pos/metersPerDegree
+		sp.Lng = origin.Lng + east.pos/(cosLat*metersPerDegree)
+		out[i] = sp
+	}
+
+	return out
+}
+
+// kalmanAxis is a 1D constant-velocity Kalman filter (position, velocity).
+type kalmanAxis struct {
+	pos, vel           float64
+	p00, p01, p10, p11 float64
+}
+
+func (k *kalmanAxis) init(z, r float64) {
+	k.pos, k.vel = z, 0
+	k.p00, k.p01, k.p10, k.p11 = r, 0, 0, 400 // unknown velocity, ~20 m/s sigma
+}
+
+func (k *kalmanAxis) predict(dt, q float64) {
+	k.pos += k.vel * dt
+
+	dt2 := dt * dt
+	p00 := k.p00 + dt*(k.p10+k.p01) + dt2*k.p11
+	p01 := k.p01 + dt*k.p11
+	p10 := k.p10 + dt*k.p11
+
+	k.p00 = p00 + q*dt2*dt2/4
+	k.p01 = p01 + q*dt2*dt/2
+	k.p10 = p10 + q*dt2*dt/2
+	k.p11 += q * dt2
+}
+
+func (k *kalmanAxis) update(z, r float64) {
+	s := k.p00 + r
+	k0 := k.p00 / s
+	k1 := k.p10 / s
+	y := z - k.pos
+
+	k.pos += k0 * y
+	k.vel += k1 * y
+
+	p00, p01 := k.p00, k.p01
+	k.p00 = (1 - k0) * p00
+	k.p01 = (1 - k0) * p01
+	k.p10 -= k1 * p00
+	k.p11 -= k1 * p01
+}
+
+// fillGaps inserts linearly interpolated points into gaps longer than GapThreshold.
+func (tp *TraceProcessor) fillGaps(points []TracePoint) []TracePoint {
+	if len(points) < 2 || tp.config.GapThreshold <= 0 || tp.config.GapFillInterval <= 0 {
+		return points
+	}
+
+	out := make([]TracePoint, 0, len(points))
+	out = append(out, points[0])
+
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		gap := b.Timestamp.Sub(a.Timestamp)
+
+		if gap > tp.config.GapThreshold {
+			for offset := tp.config.GapFillInterval; offset < gap; offset += tp.config.GapFillInterval {
+				t := float64(offset) / float64(gap)
+				out = append(out, TracePoint{
+					Point:        interpolate(a.Point, b.Point, t),
+					Timestamp:    a.Timestamp.Add(offset),
+					Interpolated: true,
+				})
+			}
+		}
+		out = append(out, b)
+	}
+
+	return out
+}
+
+// distance sums movement, ignoring displacements below MinMovementMeters.
+func (tp *TraceProcessor) distance(points []TracePoint) float64 {
+	if len(points) < 2 {
+		return 0
+	}
+
+	var total float64
+	anchor := points[0].Point
+	for _, p := range points[1:] {
+		d := HaversineDistanceMeters(anchor, p.Point)
+		if d >= tp.config.MinMovementMeters {
+			total += d
+			anchor = p.Point
+		}
+	}
+	return total
+}
+
+func traceLength(points []TracePoint) float64 {
+	var total float64
+	for i := 1; i < len(points); i++ {
+		total += HaversineDistanceMeters(points[i-1].Point, points[i].Point)
+	}
+	return total
+}
@@ -0,0 +1,121 @@
+package geo_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/mycobrun/cobrun-shared/geo"
+	"github.com/mycobrun/cobrun-shared/testing/fixtures"
+)
+
+func TestTraceProcessor_CleanTrace(t *testing.T) {
+	batch := fixtures.NewLocationBatch("driver-1", 60)
+	result := geo.NewTraceProcessor(geo.DefaultTraceProcessorConfig()).Process(batch.TracePoints())
+
+	if result.OutliersRemoved != 0 {
+		t.Errorf("expected no outliers, got %d", result.OutliersRemoved)
+	}
+	if result.Duration != 59*time.Second || result.DurationSeconds() != 59 {
+		t.Errorf("expected 59s duration, got %v", result.Duration)
+	}
+
+	// A clean straight trace should keep (almost) its full length
+	if diff := math.Abs(result.DistanceMeters-result.RawDistanceMeters) / result.RawDistanceMeters; diff > 0.05 {
+		t.Errorf("expected cleaned distance within 5%% of raw %f, got %f", result.RawDistanceMeters, result.DistanceMeters)
+	}
+}
+
+func TestTraceProcessor_RemovesTeleport(t *testing.T) {
+	batch := fixtures.NewLocationBatch("driver-1", 60)
+	points := batch.TracePoints()
+	clean := geo.NewTraceProcessor(geo.DefaultTraceProcessorConfig()).Process(points)
+
+	// Jump ~5km away for a single fix
+	points[30].Lat += 0.045
+	result := geo.NewTraceProcessor(geo.DefaultTraceProcessorConfig()).Process(points)
+
+	if result.OutliersRemoved != 1 {
+		t.Errorf("expected 1 outlier removed, got %d", result.OutliersRemoved)
+	}
+	if result.RawDistanceMeters < clean.RawDistanceMeters+9000 {
+		t.Errorf("expected raw distance inflated by the jump, got %f", result.RawDistanceMeters)
+	}
+	if math.Abs(result.DistanceMeters-clean.DistanceMeters) > 20 {
+		t.Errorf("expected cleaned distance ~%f, got %f", clean.DistanceMeters, result.DistanceMeters)
+	}
+}
+
+func TestTraceProcessor_StationaryJitter(t *testing.T) {
+	start := time.Now()
+	var points []geo.TracePoint
+	for i := 0; i < 120; i++ {
+		// Alternate ~8m either side of a fixed position
+		offset := 0.00007
+		if i%2 == 0 {
+			offset = -offset
+		}
+		points = append(points, geo.TracePoint{
+			Point:     geo.NewPoint(47.6062+offset, -122.3321),
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+			Accuracy:  10,
+		})
+	}
+
+	result := geo.NewTraceProcessor(geo.DefaultTraceProcessorConfig()).Process(points)
+
+	if result.RawDistanceMeters < 1500 {
+		t.Fatalf("expected raw jitter distance > 1500m, got %f", result.RawDistanceMeters)
+	}
+	if result.DistanceMeters > 100 {
+		t.Errorf("expected stationary distance < 100m, got %f", result.DistanceMeters)
+	}
+}
+
+func TestTraceProcessor_FillsGaps(t *testing.T) {
+	batch := fixtures.NewLocationBatch("driver-1", 60)
+	points := batch.TracePoints()
+
+	// Tunnel: no fixes for 31 seconds, filled every 5s
+	tunnel := append(append([]geo.TracePoint{}, points[:20]...), points[50:]...)
+
+	cfg := geo.DefaultTraceProcessorConfig()
+	result := geo.NewTraceProcessor(cfg).Process(tunnel)
+
+	if result.PointsInterpolated != 6 {
+		t.Errorf("expected 6 interpolated points, got %d", result.PointsInterpolated)
+	}
+	for i := 1; i < len(result.Points); i++ {
+		if !result.Points[i].Timestamp.After(result.Points[i-1].Timestamp) {
+			t.Fatalf("points out of order at %d", i)
+		}
+	}
+
+	full := geo.NewTraceProcessor(cfg).Process(points)
+	if math.Abs(result.DistanceMeters-full.DistanceMeters)/full.DistanceMeters > 0.05 {
+		t.Errorf("expected gap distance within 5%% of %f, got %f", full.DistanceMeters, result.DistanceMeters)
+	}
+}
+
+func TestTraceProcessor_SortsAndDropsInvalid(t *testing.T) {
+	start := time.Now()
+	points := []geo.TracePoint{
+		{Point: geo.NewPoint(47.6066, -122.3321), Timestamp: start.Add(2 * time.Second)},
+		{Point: geo.NewPoint(47.6062, -122.3321), Timestamp: start},
+		{Point: geo.NewPoint(47.6064, -122.3321), Timestamp: start.Add(time.Second)},
+		{Point: geo.NewPoint(95, 0), Timestamp: start.Add(3 * time.Second)},                             // invalid
+		{Point: geo.NewPoint(47.6080, -122.3321), Timestamp: start.Add(4 * time.Second), Accuracy: 500}, // inaccurate
+	}
+
+	result := geo.NewTraceProcessor(geo.DefaultTraceProcessorConfig()).Process(points)
+
+	if len(result.Points) != 3 {
+		t.Fatalf("expected 3 points, got %d", len(result.Points))
+	}
+	if !result.Points[0].Timestamp.Equal(start) {
+		t.Error("expected points sorted by timestamp")
+	}
+	if len(result.Route()) != 3 {
+		t.Error("expected route to mirror points")
+	}
+}
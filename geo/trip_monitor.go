@@ -0,0 +1,260 @@
+// Package geo provides geospatial utilities.
+package geo
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// TripMonitorEventType identifies a live trip anomaly.
+type TripMonitorEventType string
+
+const (
+	// TripEventRouteDeviation is emitted when the driver leaves the route corridor.
+	TripEventRouteDeviation TripMonitorEventType = "route_deviation"
+	// TripEventRouteRejoined is emitted when the driver returns to the corridor.
+	TripEventRouteRejoined TripMonitorEventType = "route_rejoined"
+	// TripEventUnexpectedStop is emitted when the driver stays in one place mid-trip.
+	TripEventUnexpectedStop TripMonitorEventType = "unexpected_stop"
+	// TripEventStopEnded is emitted when the driver moves on after an unexpected stop.
+	TripEventStopEnded TripMonitorEventType = "stop_ended"
+)
+
+// TripMonitorEvent describes a route deviation or stop detected on a live trip.
+type TripMonitorEvent struct {
+	Type      TripMonitorEventType `json:"type"`
+	TripID    string               `json:"trip_id"`
+	DriverID  string               `json:"driver_id,omitempty"`
+	Location  Point                `json:"location"`
+	Timestamp time.Time            `json:"timestamp"`
+	// DistanceFromRouteMeters is the distance to the route when the event fired.
+	DistanceFromRouteMeters float64 `json:"distance_from_route_meters"`
+	// RouteProgress is the fraction (0-1) of the route completed at the nearest point.
+	RouteProgress float64 `json:"route_progress"`
+	// Duration is how long the deviation or stop has lasted.
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// TripMonitorEventHandler receives trip monitor events as they are detected.
+type TripMonitorEventHandler func(ctx context.Context, event TripMonitorEvent) error
+
+// TripMonitorConfig holds trip monitor configuration.
+type TripMonitorConfig struct {
+	// CorridorMeters is the maximum distance from the route considered on-route.
+	CorridorMeters float64
+	// DeviationMinDuration is how long the driver must stay outside the
+	// corridor before a deviation is reported.
+	DeviationMinDuration time.Duration
+	// StopRadiusMeters is the radius of the cluster of fixes treated as one place.
+	StopRadiusMeters float64
+	// StopMinDuration is how long the driver must stay in one place before a
+	// stop is reported.
+	StopMinDuration time.Duration
+	// EndpointRadiusMeters suppresses stops this close to the route start or
+	// end, where waiting is expected.
+	EndpointRadiusMeters float64
+}
+
+// DefaultTripMonitorConfig returns sensible defaults.
+func DefaultTripMonitorConfig() TripMonitorConfig {
+	return TripMonitorConfig{
+		CorridorMeters:       100,
+		DeviationMinDuration: 30 * time.Second,
+		StopRadiusMeters:     30,
+		StopMinDuration:      3 * time.Minute,
+		EndpointRadiusMeters: 150,
+	}
+}
+
+// TripMonitor compares a stream of location fixes for one trip against its
+// estimated route and reports route deviations and prolonged stops.
+// Fixes must be delivered in order.
+type TripMonitor struct {
+	tripID   string
+	driverID string
+	handler  TripMonitorEventHandler
+	config   TripMonitorConfig
+
+	mu    sync.Mutex
+	route []Point
+
+	// Deviation state
+	offRoute      bool
+	offRouteSince time.Time
+	pending       bool
+	pendingSince  time.Time
+
+	// Stop clustering state
+	cluster       Point
+	clusterSize   int
+	clusterSince  time.Time
+	stopped       bool
+	lastTimestamp time.Time
+}
+
+// NewTripMonitor creates a new trip monitor for the given route. handler may be nil.
+func NewTripMonitor(tripID, driverID string, route []Point, handler TripMonitorEventHandler, config TripMonitorConfig) *TripMonitor {
+	return &TripMonitor{
+		tripID:   tripID,
+		driverID: driverID,
+		route:    append([]Point(nil), route...),
+		handler:  handler,
+		config:   config,
+	}
+}
+
+// SetRoute replaces the route, e.g. after the driver is re-routed.
+// An ongoing deviation is re-evaluated against the new route on the next fix.
+func (m *TripMonitor) SetRoute(route []Point) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.route = append([]Point(nil), route...)
+}
+
+// OffRoute reports whether the driver is currently outside the corridor.
+func (m *TripMonitor) OffRoute() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.offRoute
+}
+
+// Stopped reports whether the driver is currently in an unexpected stop.
+func (m *TripMonitor) Stopped() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stopped
+}
+
+// Update applies a location fix and returns the events it produced.
+// Events are also delivered to the handler, in order.
+func (m *TripMonitor) Update(ctx context.Context, fix TracePoint) ([]TripMonitorEvent, error) {
+	if !fix.IsValid() {
+		return nil, nil
+	}
+
+	m.mu.Lock()
+	if !m.lastTimestamp.IsZero() && fix.Timestamp.Before(m.lastTimestamp) {
+		// Late fixes would corrupt the dwell cluster
+		m.mu.Unlock()
+		return nil, nil
+	}
+	m.lastTimestamp = fix.Timestamp
+
+	proj, hasRoute := ProjectOntoRoute(m.route, fix.Point)
+
+	var events []TripMonitorEvent
+	if hasRoute {
+		if event, ok := m.checkDeviation(fix, proj); ok {
+			events = append(events, event)
+		}
+	}
+	if event, ok := m.checkStop(fix, proj); ok {
+		events = append(events, event)
+	}
+	m.mu.Unlock()
+
+	if m.handler != nil {
+		for _, event := range events {
+			if err := m.handler(ctx, event); err != nil {
+				return events, err
+			}
+		}
+	}
+
+	return events, nil
+}
+
+func (m *TripMonitor) checkDeviation(fix TracePoint, proj RouteProjection) (TripMonitorEvent, bool) {
+	// A fix only counts as off-route if its whole accuracy circle is outside
+	// the corridor, so a poor fix next to the route is not a deviation.
+	outside := proj.DistanceMeters-fix.Accuracy > m.config.CorridorMeters
+
+	if outside == m.offRoute {
+		m.pending = false
+		return TripMonitorEvent{}, false
+	}
+
+	if !m.pending {
+		m.pending = true
+		m.pendingSince = fix.Timestamp
+	}
+	if outside && fix.Timestamp.Sub(m.pendingSince) < m.config.DeviationMinDuration {
+		return TripMonitorEvent{}, false
+	}
+
+	m.pending = false
+	event := m.newEvent(fix, proj)
+
+	if outside {
+		m.offRoute = true
+		m.offRouteSince = m.pendingSince
+		event.Type = TripEventRouteDeviation
+		event.Duration = fix.Timestamp.Sub(m.offRouteSince)
+	} else {
+		m.offRoute = false
+		event.Type = TripEventRouteRejoined
+		event.Duration = fix.Timestamp.Sub(m.offRouteSince)
+	}
+	return event, true
+}
+
+func (m *TripMonitor) checkStop(fix TracePoint, proj RouteProjection) (TripMonitorEvent, bool) {
+	if m.clusterSize > 0 && HaversineDistanceMeters(m.cluster, fix.Point) <= m.config.StopRadiusMeters {
+		// Running mean keeps the center stable against jitter
+		m.clusterSize++
+		n := float64(m.clusterSize)
+		m.cluster.Lat += (fix.Lat - m.cluster.Lat) / n
+		m.cluster.Lng += (fix.Lng - m.cluster.Lng) / n
+
+		dwell := fix.Timestamp.Sub(m.clusterSince)
+		if m.stopped || dwell < m.config.StopMinDuration || m.nearEndpoint(m.cluster) {
+			return TripMonitorEvent{}, false
+		}
+
+		m.stopped = true
+		event := m.newEvent(fix, proj)
+		event.Type = TripEventUnexpectedStop
+		event.Location = m.cluster
+		event.Duration = dwell
+		return event, true
+	}
+
+	// Moved away: start a new cluster
+	wasStopped := m.stopped
+	stopCenter, stopSince := m.cluster, m.clusterSince
+
+	m.cluster = fix.Point
+	m.clusterSize = 1
+	m.clusterSince = fix.Timestamp
+	m.stopped = false
+
+	if !wasStopped {
+		return TripMonitorEvent{}, false
+	}
+
+	event := m.newEvent(fix, proj)
+	event.Type = TripEventStopEnded
+	event.Location = stopCenter
+	event.Duration = fix.Timestamp.Sub(stopSince)
+	return event, true
+}
+
+func (m *TripMonitor) nearEndpoint(p Point) bool {
+	if len(m.route) == 0 || m.config.EndpointRadiusMeters <= 0 {
+		return false
+	}
+	return HaversineDistanceMeters(p, m.route[0]) <= m.config.EndpointRadiusMeters ||
+		HaversineDistanceMeters(p, m.route[len(m.route)-1]) <= m.config.EndpointRadiusMeters
+}
+
+func (m *TripMonitor) newEvent(fix TracePoint, proj RouteProjection) TripMonitorEvent {
+	return TripMonitorEvent{
+		TripID:                  m.tripID,
+		DriverID:                m.driverID,
+		Location:                fix.Point,
+		Timestamp:               fix.Timestamp,
+		DistanceFromRouteMeters: proj.DistanceMeters,
+		RouteProgress:           proj.Fraction,
+	}
+}
@@ -0,0 +1,137 @@
+package geo
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// eastRoute runs ~4.4km east along a single street.
+var eastRoute = []Point{{Lat: 37.77, Lng: -122.45}, {Lat: 37.77, Lng: -122.40}}
+
+func TestTripMonitor_Deviation(t *testing.T) {
+	ctx := context.Background()
+	var delivered []TripMonitorEvent
+	handler := func(ctx context.Context, e TripMonitorEvent) error {
+		delivered = append(delivered, e)
+		return nil
+	}
+	monitor := NewTripMonitor("trip-1", "driver-1", eastRoute, handler, DefaultTripMonitorConfig())
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	steps := []struct {
+		lat    float64
+		offset time.Duration
+		want   TripMonitorEventType
+	}{
+		{37.7700, 0, ""},
+		{37.7720, 10 * time.Second, ""}, // ~220m off, pending
+		{37.7725, 30 * time.Second, ""},
+		{37.7730, 45 * time.Second, TripEventRouteDeviation},
+		{37.7730, 60 * time.Second, ""},
+		{37.7701, 75 * time.Second, TripEventRouteRejoined},
+	}
+
+	for i, step := range steps {
+		// Keep moving east so no stop is detected
+		lng := -122.44 + float64(step.offset/time.Second)*0.0002
+		events, err := monitor.Update(ctx, TracePoint{
+			Point:     Point{Lat: step.lat, Lng: lng},
+			Timestamp: start.Add(step.offset),
+			Accuracy:  10,
+		})
+		if err != nil {
+			t.Fatalf("step %d: unexpected error: %v", i, err)
+		}
+		if step.want == "" {
+			if len(events) != 0 {
+				t.Errorf("step %d: expected no events, got %v", i, events)
+			}
+			continue
+		}
+		if len(events) != 1 || events[0].Type != step.want {
+			t.Fatalf("step %d: expected %s, got %v", i, step.want, events)
+		}
+	}
+
+	if len(delivered) != 2 {
+		t.Fatalf("expected 2 delivered events, got %d", len(delivered))
+	}
+	if d := delivered[0]; d.Duration != 35*time.Second || d.DistanceFromRouteMeters < 300 || d.TripID != "trip-1" {
+		t.Errorf("unexpected deviation event %+v", d)
+	}
+	if delivered[1].Duration != 65*time.Second {
+		t.Errorf("expected rejoin after 65s off route, got %v", delivered[1].Duration)
+	}
+	if monitor.OffRoute() {
+		t.Error("expected monitor to be back on route")
+	}
+}
+
+func TestTripMonitor_BriefExcursionIgnored(t *testing.T) {
+	monitor := NewTripMonitor("trip-1", "driver-1", eastRoute, nil, DefaultTripMonitorConfig())
+
+	start := time.Now()
+	lats := []float64{37.770, 37.772, 37.772, 37.770}
+	for i, lat := range lats {
+		events, _ := monitor.Update(context.Background(), TracePoint{
+			Point:     Point{Lat: lat, Lng: -122.44 + float64(i)*0.002},
+			Timestamp: start.Add(time.Duration(i) * 10 * time.Second),
+		})
+		if len(events) != 0 {
+			t.Fatalf("fix %d: expected no events, got %v", i, events)
+		}
+	}
+}
+
+func TestTripMonitor_UnexpectedStop(t *testing.T) {
+	monitor := NewTripMonitor("trip-1", "driver-1", eastRoute, nil, DefaultTripMonitorConfig())
+	mid := Point{Lat: 37.77, Lng: -122.425}
+
+	start := time.Now()
+	var types []TripMonitorEventType
+	for i := 0; i <= 40; i++ {
+		// ~5m jitter around the stop
+		p := mid
+		if i%2 == 0 {
+			p.Lat += 0.00005
+		}
+		events, _ := monitor.Update(context.Background(), TracePoint{Point: p, Timestamp: start.Add(time.Duration(i) * 5 * time.Second)})
+		for _, e := range events {
+			types = append(types, e.Type)
+		}
+	}
+	if !monitor.Stopped() {
+		t.Fatal("expected monitor to report a stop")
+	}
+
+	events, _ := monitor.Update(context.Background(), TracePoint{
+		Point:     Point{Lat: 37.77, Lng: -122.42},
+		Timestamp: start.Add(210 * time.Second),
+	})
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+
+	if len(types) != 2 || types[0] != TripEventUnexpectedStop || types[1] != TripEventStopEnded {
+		t.Fatalf("expected [unexpected_stop stop_ended], got %v", types)
+	}
+	if events[0].Duration != 210*time.Second {
+		t.Errorf("expected stop duration 210s, got %v", events[0].Duration)
+	}
+}
+
+func TestTripMonitor_StopAtPickupIgnored(t *testing.T) {
+	monitor := NewTripMonitor("trip-1", "driver-1", eastRoute, nil, DefaultTripMonitorConfig())
+
+	start := time.Now()
+	for i := 0; i <= 60; i++ {
+		events, _ := monitor.Update(context.Background(), TracePoint{
+			Point:     eastRoute[0],
+			Timestamp: start.Add(time.Duration(i) * 5 * time.Second),
+		})
+		if len(events) != 0 {
+			t.Fatalf("expected waiting at pickup to be ignored, got %v", events)
+		}
+	}
+}
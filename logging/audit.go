@@ -41,6 +41,10 @@ const (
 
 	// Trip events
 	AuditEventTripCreated    AuditEventType = "trip.created"
+	AuditEventTripAccepted      AuditEventType = "trip.accepted"
+	AuditEventTripDriverEnRoute AuditEventType = "trip.driver_en_route"
+	AuditEventTripDriverArrived AuditEventType = "trip.driver_arrived"
+	AuditEventTripStarted       AuditEventType = "trip.started"
 	AuditEventTripCompleted  AuditEventType = "trip.completed"
 	AuditEventTripCancelled  AuditEventType = "trip.cancelled"
 	AuditEventTripDisputed   AuditEventType = "trip.disputed"
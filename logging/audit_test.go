@@ -495,6 +495,10 @@ func TestAuditEventTypes(t *testing.T) {
 		AuditEventDocumentVerified,
 		// Trip events
 		AuditEventTripCreated,
+		AuditEventTripAccepted,
+		AuditEventTripDriverEnRoute,
+		AuditEventTripDriverArrived,
+		AuditEventTripStarted,
 		AuditEventTripCompleted,
 		AuditEventTripCancelled,
 		AuditEventTripDisputed,
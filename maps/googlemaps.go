@@ -431,6 +431,11 @@ type RouteResult struct {
 	Legs                   []RouteLeg    `json:"legs,omitempty"`
 }
 
+// Points decodes the route's encoded polyline.
+func (r *RouteResult) Points() ([]geo.Point, error) {
+	return geo.DecodePolyline(r.EncodedPolyline)
+}
+
 // RouteLeg represents a leg of a route.
 type RouteLeg struct {
 	DistanceMeters    int       `json:"distance_meters"`
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/redis/go-redis/v9"
+
+	"github.com/mycobrun/cobrun-shared/cache"
 )
 
 // RedisCache implements the Cache interface using Redis.
@@ -190,40 +192,37 @@ func (r *RedisRateLimiter) Wait(ctx context.Context, key string) error {
 // InMemoryCache implements the Cache interface using in-memory storage.
 // Use for testing or single-instance deployments.
 type InMemoryCache struct {
-	data map[string]cacheEntry
+	lru *cache.LRU
 }
 
-type cacheEntry struct {
-	value     []byte
-	expiresAt time.Time
+// NewInMemoryCache creates a new in-memory cache holding up to 10000 entries.
+func NewInMemoryCache() *InMemoryCache {
+	return NewInMemoryCacheWithLRU(cache.NewLRU(cache.LRUConfig{MaxEntries: 10000}))
 }
 
-// NewInMemoryCache creates a new in-memory cache.
-func NewInMemoryCache() *InMemoryCache {
-	return &InMemoryCache{
-		data: make(map[string]cacheEntry),
-	}
+// NewInMemoryCacheWithLRU creates an in-memory cache backed by lru, e.g. one
+// with metrics or bounds of its own.
+func NewInMemoryCacheWithLRU(lru *cache.LRU) *InMemoryCache {
+	return &InMemoryCache{lru: lru}
 }
 
 // Get retrieves a cached value.
 func (c *InMemoryCache) Get(ctx context.Context, key string) ([]byte, error) {
-	entry, ok := c.data[key]
+	value, ok := c.lru.Get(key)
 	if !ok {
 		return nil, nil
 	}
-	if time.Now().After(entry.expiresAt) {
-		delete(c.data, key)
-		return nil, nil
-	}
-	return entry.value, nil
+	return value, nil
 }
 
-// Set stores a value in cache with TTL.
+// Set stores a value in cache with TTL. A ttl of 0 or less expires the
+// value immediately.
 func (c *InMemoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
-	c.data[key] = cacheEntry{
-		value:     value,
-		expiresAt: time.Now().Add(ttl),
+	if ttl <= 0 {
+		c.lru.Delete(key)
+		return nil
 	}
+	c.lru.Set(key, value, ttl)
 	return nil
 }
 
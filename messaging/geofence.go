@@ -0,0 +1,48 @@
+// Package messaging provides messaging client utilities.
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mycobrun/cobrun-shared/geo"
+)
+
+// NewGeofenceEventPublisher returns a geofence event handler that publishes
+// each event to Service Bus. The message ID is derived from the event so
+// redelivered location updates do not produce duplicate messages.
+func NewGeofenceEventPublisher(p *Publisher) geo.GeofenceEventHandler {
+	return func(ctx context.Context, event geo.GeofenceEvent) error {
+		id := fmt.Sprintf("%s:%s:%s:%d", event.EntityID, event.GeofenceID, event.Type, event.Timestamp.UnixNano())
+		return p.SendJSON(ctx, id, event,
+			WithSubject("geofence."+string(event.Type)),
+			WithSessionID(event.EntityID),
+			WithProperty("geofence_id", event.GeofenceID),
+			WithProperty("geofence_type", event.GeofenceType),
+		)
+	}
+}
+
+// NewGeofenceLocationHandler returns an Event Hubs handler that feeds
+// LocationUpdateMessage events into a geofence tracker.
+func NewGeofenceLocationHandler(tracker *geo.GeofenceTracker) EventHandler {
+	return func(ctx context.Context, event *ReceivedEvent) error {
+		var msg LocationUpdateMessage
+		if err := event.UnmarshalJSON(&msg); err != nil {
+			return fmt.Errorf("failed to decode location update: %w", err)
+		}
+
+		timestamp := event.EnqueuedTime
+		if msg.Timestamp > 0 {
+			timestamp = time.Unix(msg.Timestamp, 0)
+		}
+
+		_, err := tracker.Update(ctx, geo.LocationUpdate{
+			EntityID:  msg.DriverID,
+			Location:  geo.NewPoint(msg.Latitude, msg.Longitude),
+			Timestamp: timestamp,
+		})
+		return err
+	}
+}
@@ -0,0 +1,45 @@
+// Package messaging provides messaging client utilities.
+package messaging
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mycobrun/cobrun-shared/geo"
+)
+
+// NewTripMonitorNotifier returns a trip monitor event handler that sends each
+// event as a trip status update to the given users (e.g. rider and safety ops).
+func NewTripMonitorNotifier(client *SignalRClient, userIDs ...string) geo.TripMonitorEventHandler {
+	return func(ctx context.Context, event geo.TripMonitorEvent) error {
+		update := TripStatusMessageFromMonitorEvent(event)
+		for _, userID := range userIDs {
+			if err := client.SendTripStatusUpdate(ctx, userID, update); err != nil {
+				return fmt.Errorf("failed to send trip status update to %s: %w", userID, err)
+			}
+		}
+		return nil
+	}
+}
+
+// TripStatusMessageFromMonitorEvent converts a trip monitor event into a trip status message.
+func TripStatusMessageFromMonitorEvent(event geo.TripMonitorEvent) *TripStatusMessage {
+	var message string
+	switch event.Type {
+	case geo.TripEventRouteDeviation:
+		message = fmt.Sprintf("Driver is %.0fm off the planned route", event.DistanceFromRouteMeters)
+	case geo.TripEventRouteRejoined:
+		message = "Driver is back on the planned route"
+	case geo.TripEventUnexpectedStop:
+		message = fmt.Sprintf("Driver has been stopped for %d minutes", int(event.Duration.Minutes()))
+	case geo.TripEventStopEnded:
+		message = "Driver is moving again"
+	}
+
+	return &TripStatusMessage{
+		TripID:   event.TripID,
+		Status:   string(event.Type),
+		DriverID: event.DriverID,
+		Message:  message,
+	}
+}
@@ -187,6 +187,7 @@ type DatabaseMetrics struct {
 	operationDuration  metric.Float64Histogram
 	connectionPoolSize metric.Int64UpDownCounter
 	errorsTotal        metric.Int64Counter
+	requestUnits       metric.Float64Histogram
 }
 
 // NewDatabaseMetrics creates database metrics.
@@ -230,11 +231,22 @@ func NewDatabaseMetrics(meter metric.Meter, dbType string) (*DatabaseMetrics, er
 		return nil, err
 	}
 
+	requestUnits, err := meter.Float64Histogram(
+		prefix+"_request_units",
+		metric.WithDescription("Request units consumed per database operation"),
+		metric.WithUnit("{RU}"),
+		metric.WithExplicitBucketBoundaries(1, 2, 5, 10, 25, 50, 100, 250, 500, 1000),
+	)
+	if err != nil {
+		return nil, err
+	}
+
 	return &DatabaseMetrics{
 		operationsTotal:    operationsTotal,
 		operationDuration:  operationDuration,
 		connectionPoolSize: connectionPoolSize,
 		errorsTotal:        errorsTotal,
+		requestUnits:       requestUnits,
 	}, nil
 }
 
@@ -252,11 +264,78 @@ func (m *DatabaseMetrics) RecordOperation(ctx context.Context, operation string,
 	}
 }
 
+// RecordRequestCharge records the request units (Cosmos DB RU) an operation consumed.
+func (m *DatabaseMetrics) RecordRequestCharge(ctx context.Context, operation string, requestUnits float64) {
+	m.requestUnits.Record(ctx, requestUnits, metric.WithAttributes(
+		attribute.String("operation", operation),
+	))
+}
+
 // RecordPoolSize records the connection pool size.
 func (m *DatabaseMetrics) RecordPoolSize(ctx context.Context, size int64) {
 	m.connectionPoolSize.Add(ctx, size)
 }
 
+// CacheMetrics provides cache hit, miss and eviction metrics per cache tier.
+type CacheMetrics struct {
+	hitsTotal      metric.Int64Counter
+	missesTotal    metric.Int64Counter
+	evictionsTotal metric.Int64Counter
+}
+
+// NewCacheMetrics creates cache metrics.
+func NewCacheMetrics(meter metric.Meter, cacheName string) (*CacheMetrics, error) {
+	prefix := fmt.Sprintf("cache_%s", cacheName)
+
+	hitsTotal, err := meter.Int64Counter(
+		prefix+"_hits_total",
+		metric.WithDescription("Total cache hits"),
+		metric.WithUnit("{hits}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	missesTotal, err := meter.Int64Counter(
+		prefix+"_misses_total",
+		metric.WithDescription("Total cache misses"),
+		metric.WithUnit("{misses}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	evictionsTotal, err := meter.Int64Counter(
+		prefix+"_evictions_total",
+		metric.WithDescription("Total entries evicted to stay within the cache size"),
+		metric.WithUnit("{entries}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CacheMetrics{
+		hitsTotal:      hitsTotal,
+		missesTotal:    missesTotal,
+		evictionsTotal: evictionsTotal,
+	}, nil
+}
+
+// RecordHit records a cache hit in a tier, e.g. "local" or "redis".
+func (m *CacheMetrics) RecordHit(ctx context.Context, tier string) {
+	m.hitsTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("tier", tier)))
+}
+
+// RecordMiss records a cache miss in a tier.
+func (m *CacheMetrics) RecordMiss(ctx context.Context, tier string) {
+	m.missesTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("tier", tier)))
+}
+
+// RecordEviction records an entry evicted from a tier.
+func (m *CacheMetrics) RecordEviction(ctx context.Context, tier string) {
+	m.evictionsTotal.Add(ctx, 1, metric.WithAttributes(attribute.String("tier", tier)))
+}
+
 // BusinessMetrics provides business-specific metrics for rideshare.
 type BusinessMetrics struct {
 	tripsRequested   metric.Int64Counter
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/mycobrun/cobrun-shared/geo"
 )
 
 // DriverAvailabilityFixture represents a test driver availability record.
@@ -396,3 +397,18 @@ func NewLocationBatch(driverID string, count int) LocationBatchFixture {
 		Points:         points,
 	}
 }
+
+// TracePoints converts the batch into geo trace points.
+func (b LocationBatchFixture) TracePoints() []geo.TracePoint {
+	points := make([]geo.TracePoint, len(b.Points))
+	for i, p := range b.Points {
+		points[i] = geo.TracePoint{
+			Point:     geo.NewPoint(p.Lat, p.Lng),
+			Timestamp: p.Timestamp,
+			Accuracy:  p.Accuracy,
+			Speed:     p.Speed,
+			Heading:   p.Heading,
+		}
+	}
+	return points
+}
@@ -0,0 +1,437 @@
+// Package mocks provides mock implementations for testing.
+package mocks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
+	"github.com/mycobrun/cobrun-shared/database"
+	apperrors "github.com/mycobrun/cobrun-shared/errors"
+)
+
+// MockCosmosContainer is an in-memory implementation of database.Container.
+//
+// Items are stored per partition key and get the system properties _etag and
+// _ts, so ETag-based concurrency behaves as in Cosmos DB. With a default TTL
+// set, items expire based on their "ttl" property. Queries support the SQL
+// subset described in cosmos_sql.go. Errors are mapped like those of
+// database.CosmosContainer.
+type MockCosmosContainer struct {
+	mu               sync.RWMutex
+	items            map[string]map[string]*mockCosmosItem
+	partitionKeyPath []interface{}
+	defaultTTL       *int
+	now              func() time.Time
+	etagSeq          uint64
+	shouldFail       bool
+	failError        error
+}
+
+type mockCosmosItem struct {
+	raw     json.RawMessage
+	doc     interface{}
+	etag    string
+	written time.Time
+}
+
+var _ database.Container = (*MockCosmosContainer)(nil)
+
+// NewMockCosmosContainer creates an empty in-memory container.
+func NewMockCosmosContainer() *MockCosmosContainer {
+	return &MockCosmosContainer{
+		items: make(map[string]map[string]*mockCosmosItem),
+		now:   time.Now,
+	}
+}
+
+// SetPartitionKeyPath makes writes fail with a bad request when the item's
+// property at path (e.g. "/rider_id") does not match the partition key given.
+func (m *MockCosmosContainer) SetPartitionKeyPath(path string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.partitionKeyPath = splitPath(path)
+}
+
+// SetDefaultTTL enables expiry like a container's DefaultTimeToLive: items
+// expire ttl seconds after their last write unless their own "ttl" property
+// overrides it. -1 enables per-item TTL without a default.
+func (m *MockCosmosContainer) SetDefaultTTL(ttl int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.defaultTTL = &ttl
+}
+
+// SetClock replaces the time source used for _ts and TTL expiry.
+func (m *MockCosmosContainer) SetClock(now func() time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = now
+}
+
+// SetShouldFail makes all operations fail with err.
+func (m *MockCosmosContainer) SetShouldFail(shouldFail bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.shouldFail = shouldFail
+	m.failError = err
+}
+
+// Count returns the number of live items.
+func (m *MockCosmosContainer) Count() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	n := 0
+	for _, partition := range m.items {
+		for _, item := range partition {
+			if !m.expired(item) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// Clear removes all items.
+func (m *MockCosmosContainer) Clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items = make(map[string]map[string]*mockCosmosItem)
+}
+
+// Create creates a new item. Returns database.ErrConflict if it exists.
+func (m *MockCosmosContainer) Create(ctx context.Context, partitionKey string, item interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.check(ctx); err != nil {
+		return err
+	}
+	stored, id, err := m.prepare(partitionKey, item)
+	if err != nil {
+		return err
+	}
+	if m.get(partitionKey, id) != nil {
+		return statusError(409)
+	}
+	m.put(partitionKey, id, stored)
+	return nil
+}
+
+// Read reads an item. Returns database.ErrNotFound if it does not exist.
+func (m *MockCosmosContainer) Read(ctx context.Context, partitionKey, id string, result interface{}) error {
+	_, err := m.ReadWithETag(ctx, partitionKey, id, result)
+	return err
+}
+
+// ReadWithETag reads an item and returns its ETag.
+func (m *MockCosmosContainer) ReadWithETag(ctx context.Context, partitionKey, id string, result interface{}) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if err := m.check(ctx); err != nil {
+		return "", err
+	}
+	item := m.get(partitionKey, id)
+	if item == nil {
+		return "", statusError(404)
+	}
+	if err := json.Unmarshal(item.raw, result); err != nil {
+		return "", fmt.Errorf("failed to unmarshal item: %w", err)
+	}
+	return item.etag, nil
+}
+
+// Replace replaces an existing item.
+func (m *MockCosmosContainer) Replace(ctx context.Context, partitionKey, id string, item interface{}, opts ...database.ItemOption) error {
+	_, err := m.replace(ctx, partitionKey, id, item, ifMatch(opts))
+	return err
+}
+
+// ReplaceIfMatch replaces an item if its ETag matches and returns the new ETag.
+func (m *MockCosmosContainer) ReplaceIfMatch(ctx context.Context, partitionKey, id, etag string, item interface{}) (string, error) {
+	return m.replace(ctx, partitionKey, id, item, etag)
+}
+
+func (m *MockCosmosContainer) replace(ctx context.Context, partitionKey, id string, item interface{}, etag string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.check(ctx); err != nil {
+		return "", err
+	}
+	stored, itemID, err := m.prepare(partitionKey, item)
+	if err != nil {
+		return "", err
+	}
+	if itemID != id {
+		return "", apperrors.BadRequest("item id does not match the id to replace")
+	}
+	existing := m.get(partitionKey, id)
+	if existing == nil {
+		return "", statusError(404)
+	}
+	if etag != "" && etag != existing.etag {
+		return "", statusError(412)
+	}
+	return m.put(partitionKey, id, stored), nil
+}
+
+// Upsert creates or replaces an item. With IfMatch, the item must exist with
+// a matching ETag.
+func (m *MockCosmosContainer) Upsert(ctx context.Context, partitionKey string, item interface{}, opts ...database.ItemOption) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.check(ctx); err != nil {
+		return err
+	}
+	stored, id, err := m.prepare(partitionKey, item)
+	if err != nil {
+		return err
+	}
+	if etag := ifMatch(opts); etag != "" {
+		if existing := m.get(partitionKey, id); existing == nil || existing.etag != etag {
+			return statusError(412)
+		}
+	}
+	m.put(partitionKey, id, stored)
+	return nil
+}
+
+// Delete deletes an item; deleting a missing item is not an error.
+func (m *MockCosmosContainer) Delete(ctx context.Context, partitionKey, id string, opts ...database.ItemOption) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.check(ctx); err != nil {
+		return err
+	}
+	existing := m.get(partitionKey, id)
+	if existing == nil {
+		return nil
+	}
+	if etag := ifMatch(opts); etag != "" && etag != existing.etag {
+		return statusError(412)
+	}
+	delete(m.items[partitionKey], id)
+	return nil
+}
+
+// Query executes a query within a partition.
+func (m *MockCosmosContainer) Query(ctx context.Context, partitionKey, query string, params []database.QueryParam, results interface{}) error {
+	_, err := m.query(ctx, &partitionKey, query, params, 0, "", results)
+	return err
+}
+
+// QueryCrossPartition executes a query across all partitions.
+func (m *MockCosmosContainer) QueryCrossPartition(ctx context.Context, query string, params []database.QueryParam, results interface{}) error {
+	_, err := m.query(ctx, nil, query, params, 0, "", results)
+	return err
+}
+
+// QueryPage executes one page of a query. An empty partitionKey queries
+// across partitions.
+func (m *MockCosmosContainer) QueryPage(ctx context.Context, partitionKey, query string, params []database.QueryParam, pageSize int, continuation string, results interface{}) (string, error) {
+	var pk *string
+	if partitionKey != "" {
+		pk = &partitionKey
+	}
+	return m.query(ctx, pk, query, params, pageSize, continuation, results)
+}
+
+func (m *MockCosmosContainer) query(ctx context.Context, partitionKey *string, query string, params []database.QueryParam, pageSize int, continuation string, results interface{}) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if err := m.check(ctx); err != nil {
+		return "", err
+	}
+	q, err := parseCosmosQuery(query)
+	if err != nil {
+		return "", apperrors.Wrap(err, apperrors.CodeBadRequest, "invalid query")
+	}
+	values := make(map[string]interface{}, len(params))
+	for _, p := range params {
+		if values[p.Name], err = normalizeJSON(p.Value); err != nil {
+			return "", apperrors.Wrap(err, apperrors.CodeBadRequest, "invalid query parameter "+p.Name)
+		}
+	}
+
+	// Iterate in a stable order so unordered queries page consistently
+	var docs []interface{}
+	var raw []json.RawMessage
+	for _, pk := range sortedKeys(m.items) {
+		if partitionKey != nil && pk != *partitionKey {
+			continue
+		}
+		for _, id := range sortedKeys(m.items[pk]) {
+			if item := m.items[pk][id]; !m.expired(item) {
+				docs = append(docs, item.doc)
+				raw = append(raw, item.raw)
+			}
+		}
+	}
+
+	out, err := q.run(docs, raw, values)
+	if err != nil {
+		return "", apperrors.Wrap(err, apperrors.CodeBadRequest, "invalid query")
+	}
+
+	next := ""
+	if pageSize > 0 || continuation != "" {
+		start := 0
+		if continuation != "" {
+			if start, err = strconv.Atoi(continuation); err != nil || start < 0 {
+				return "", apperrors.BadRequest("invalid continuation token")
+			}
+		}
+		if start > len(out) {
+			start = len(out)
+		}
+		out = out[start:]
+		if pageSize > 0 && pageSize < len(out) {
+			out = out[:pageSize]
+			next = strconv.Itoa(start + pageSize)
+		}
+	}
+
+	if out == nil {
+		out = []json.RawMessage{}
+	}
+	data, err := json.Marshal(out)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal items: %w", err)
+	}
+	if err := json.Unmarshal(data, results); err != nil {
+		return "", fmt.Errorf("failed to unmarshal results: %w", err)
+	}
+	return next, nil
+}
+
+// check must be called with the lock held.
+func (m *MockCosmosContainer) check(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return apperrors.Wrap(err, apperrors.CodeTimeout, "operation cancelled")
+	}
+	if m.shouldFail {
+		return m.failError
+	}
+	return nil
+}
+
+// prepare validates an item and returns its stored form and ID.
+func (m *MockCosmosContainer) prepare(partitionKey string, item interface{}) (map[string]interface{}, string, error) {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal item: %w", err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, "", apperrors.BadRequest("item must be a JSON object")
+	}
+
+	id, _ := doc["id"].(string)
+	if id == "" {
+		return nil, "", apperrors.BadRequest("item must have a string id")
+	}
+	if m.partitionKeyPath != nil {
+		value := pathExpr{segments: m.partitionKeyPath}.eval(doc, nil)
+		if value != partitionKey {
+			return nil, "", apperrors.BadRequest("partition key does not match the item")
+		}
+	}
+	return doc, id, nil
+}
+
+// put stores a document with fresh system properties and returns its ETag.
+func (m *MockCosmosContainer) put(partitionKey, id string, doc map[string]interface{}) string {
+	m.etagSeq++
+	now := m.now()
+	etag := fmt.Sprintf("\"%016x\"", m.etagSeq)
+	doc["_etag"] = etag
+	doc["_ts"] = float64(now.Unix())
+
+	raw, _ := json.Marshal(doc)
+	if m.items[partitionKey] == nil {
+		m.items[partitionKey] = make(map[string]*mockCosmosItem)
+	}
+	m.items[partitionKey][id] = &mockCosmosItem{raw: raw, doc: doc, etag: etag, written: now}
+	return etag
+}
+
+// get returns a live item or nil.
+func (m *MockCosmosContainer) get(partitionKey, id string) *mockCosmosItem {
+	item := m.items[partitionKey][id]
+	if item == nil || m.expired(item) {
+		return nil
+	}
+	return item
+}
+
+func (m *MockCosmosContainer) expired(item *mockCosmosItem) bool {
+	if m.defaultTTL == nil {
+		return false
+	}
+	ttl := *m.defaultTTL
+	if v, ok := item.doc.(map[string]interface{})["ttl"].(float64); ok {
+		ttl = int(v)
+	}
+	if ttl < 0 {
+		return false
+	}
+	return !m.now().Before(item.written.Add(time.Duration(ttl) * time.Second))
+}
+
+// ifMatch returns the ETag set by database.IfMatch, or "".
+func ifMatch(opts []database.ItemOption) string {
+	o := &azcosmos.ItemOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.IfMatchEtag == nil {
+		return ""
+	}
+	return string(*o.IfMatchEtag)
+}
+
+// statusError returns the error database.CosmosContainer reports for a status code.
+func statusError(statusCode int) error {
+	switch statusCode {
+	case 404:
+		return apperrors.Wrap(database.ErrNotFound, apperrors.CodeNotFound, "cosmos item not found")
+	case 409:
+		return apperrors.Wrap(database.ErrConflict, apperrors.CodeConflict, "cosmos item conflict")
+	}
+	return apperrors.Wrap(database.ErrPreconditionFailed, apperrors.CodeConflict, "cosmos precondition failed")
+}
+
+func splitPath(path string) []interface{} {
+	var segments []interface{}
+	start := 0
+	for i := 0; i <= len(path); i++ {
+		if i == len(path) || path[i] == '/' {
+			if i > start {
+				segments = append(segments, path[start:i])
+			}
+			start = i + 1
+		}
+	}
+	return segments
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
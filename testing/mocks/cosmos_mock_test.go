@@ -0,0 +1,296 @@
+package mocks
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mycobrun/cobrun-shared/database"
+	apperrors "github.com/mycobrun/cobrun-shared/errors"
+)
+
+type testTrip struct {
+	ID      string   `json:"id"`
+	RiderID string   `json:"rider_id"`
+	Status  string   `json:"status"`
+	Fare    float64  `json:"fare"`
+	Tags    []string `json:"tags,omitempty"`
+	TTL     *int     `json:"ttl,omitempty"`
+	ETag    string   `json:"_etag,omitempty"`
+}
+
+func seedTrips(t *testing.T, m *MockCosmosContainer) {
+	t.Helper()
+	trips := []testTrip{
+		{ID: "t1", RiderID: "r1", Status: "completed", Fare: 12.5, Tags: []string{"airport"}},
+		{ID: "t2", RiderID: "r1", Status: "cancelled", Fare: 0},
+		{ID: "t3", RiderID: "r2", Status: "completed", Fare: 30},
+		{ID: "t4", RiderID: "r2", Status: "in_progress", Fare: 8},
+	}
+	for _, trip := range trips {
+		if err := m.Create(context.Background(), trip.RiderID, trip); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+}
+
+func TestMockCosmosContainer_CRUD(t *testing.T) {
+	ctx := context.Background()
+	m := NewMockCosmosContainer()
+	m.SetPartitionKeyPath("/rider_id")
+
+	trip := testTrip{ID: "t1", RiderID: "r1", Status: "requested"}
+	if err := m.Create(ctx, "r1", trip); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := m.Create(ctx, "r1", trip); !errors.Is(err, database.ErrConflict) || apperrors.Code(err) != apperrors.CodeConflict {
+		t.Errorf("expected ErrConflict, got %v", err)
+	}
+	if err := m.Create(ctx, "r2", testTrip{ID: "t2", RiderID: "r1"}); apperrors.Code(err) != apperrors.CodeBadRequest {
+		t.Errorf("expected partition key mismatch, got %v", err)
+	}
+
+	var got testTrip
+	if err := m.Read(ctx, "r2", "t1", &got); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("item should not be visible in another partition, got %v", err)
+	}
+	etag, err := m.ReadWithETag(ctx, "r1", "t1", &got)
+	if err != nil || etag == "" || got.ETag != etag {
+		t.Fatalf("expected item with ETag, got %+v, %q, %v", got, etag, err)
+	}
+
+	got.Status = "accepted"
+	newETag, err := m.ReplaceIfMatch(ctx, "r1", "t1", etag, got)
+	if err != nil || newETag == etag {
+		t.Fatalf("ReplaceIfMatch failed: %q, %v", newETag, err)
+	}
+	if _, err := m.ReplaceIfMatch(ctx, "r1", "t1", etag, got); !errors.Is(err, database.ErrPreconditionFailed) {
+		t.Errorf("expected ErrPreconditionFailed for a stale ETag, got %v", err)
+	}
+	if err := m.Delete(ctx, "r1", "t1", database.IfMatch(etag)); !errors.Is(err, database.ErrPreconditionFailed) {
+		t.Errorf("expected ErrPreconditionFailed on delete, got %v", err)
+	}
+	if err := m.Delete(ctx, "r1", "t1", database.IfMatch(newETag)); err != nil {
+		t.Errorf("Delete failed: %v", err)
+	}
+	if err := m.Delete(ctx, "r1", "t1"); err != nil {
+		t.Errorf("deleting a missing item should succeed, got %v", err)
+	}
+	if m.Count() != 0 {
+		t.Errorf("expected empty container, got %d items", m.Count())
+	}
+}
+
+func TestMockCosmosContainer_TTL(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	m := NewMockCosmosContainer()
+	m.SetClock(func() time.Time { return now })
+	m.SetDefaultTTL(60)
+
+	never := -1
+	short := 10
+	_ = m.Create(ctx, "r1", testTrip{ID: "default", RiderID: "r1"})
+	_ = m.Create(ctx, "r1", testTrip{ID: "never", RiderID: "r1", TTL: &never})
+	_ = m.Create(ctx, "r1", testTrip{ID: "short", RiderID: "r1", TTL: &short})
+
+	now = now.Add(30 * time.Second)
+	if m.Count() != 2 {
+		t.Errorf("expected the short-lived item to expire, got %d items", m.Count())
+	}
+	now = now.Add(time.Minute)
+	var got testTrip
+	if err := m.Read(ctx, "r1", "default", &got); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected default TTL to expire the item, got %v", err)
+	}
+	if err := m.Create(ctx, "r1", testTrip{ID: "default", RiderID: "r1"}); err != nil {
+		t.Errorf("expired item should not conflict, got %v", err)
+	}
+	if m.Count() != 2 {
+		t.Errorf("expected 2 live items, got %d", m.Count())
+	}
+}
+
+func TestMockCosmosContainer_Query(t *testing.T) {
+	ctx := context.Background()
+	m := NewMockCosmosContainer()
+	seedTrips(t, m)
+
+	tests := []struct {
+		name   string
+		query  string
+		params []database.QueryParam
+		want   []string
+	}{
+		{
+			name:   "filter and order",
+			query:  "SELECT * FROM c WHERE c.status = @status ORDER BY c.fare DESC",
+			params: []database.QueryParam{{Name: "@status", Value: "completed"}},
+			want:   []string{"t3", "t1"},
+		},
+		{
+			name:  "or and comparison",
+			query: "SELECT * FROM c WHERE (c.fare > 10 OR c.status = 'cancelled') AND c.rider_id != 'r2' ORDER BY c.id",
+			want:  []string{"t1", "t2"},
+		},
+		{
+			name:   "in list from builder",
+			query:  "SELECT * FROM c WHERE ARRAY_CONTAINS(@p0, c.status) ORDER BY c.fare",
+			params: []database.QueryParam{{Name: "@p0", Value: []string{"cancelled", "in_progress"}}},
+			want:   []string{"t2", "t4"},
+		},
+		{
+			name:  "functions",
+			query: "SELECT * FROM c WHERE STARTSWITH(c.status, 'comp') AND IS_DEFINED(c.tags) AND ARRAY_CONTAINS(c.tags, 'airport')",
+			want:  []string{"t1"},
+		},
+		{
+			name:  "missing property does not match",
+			query: "SELECT * FROM c WHERE c.tags[0] != 'airport' ORDER BY c.id",
+			want:  nil,
+		},
+		{
+			name:  "offset limit",
+			query: "SELECT * FROM c ORDER BY c.fare OFFSET 1 LIMIT 2",
+			want:  []string{"t4", "t1"},
+		},
+		{
+			name:  "top and in",
+			query: "SELECT TOP 1 * FROM c WHERE c.id IN ('t2', 't3') ORDER BY c.id DESC",
+			want:  []string{"t3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var trips []testTrip
+			if err := m.QueryCrossPartition(ctx, tt.query, tt.params, &trips); err != nil {
+				t.Fatalf("query failed: %v", err)
+			}
+			var ids []string
+			for _, trip := range trips {
+				ids = append(ids, trip.ID)
+			}
+			if len(ids) != len(tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, ids)
+			}
+			for i := range ids {
+				if ids[i] != tt.want[i] {
+					t.Fatalf("expected %v, got %v", tt.want, ids)
+				}
+			}
+		})
+	}
+}
+
+func TestMockCosmosContainer_QueryProjection(t *testing.T) {
+	ctx := context.Background()
+	m := NewMockCosmosContainer()
+	seedTrips(t, m)
+
+	var rows []map[string]interface{}
+	if err := m.Query(ctx, "r1", "SELECT c.id, c.fare AS amount FROM c ORDER BY c.id", nil, &rows); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(rows) != 2 || rows[0]["id"] != "t1" || rows[0]["amount"] != 12.5 || len(rows[0]) != 2 {
+		t.Errorf("unexpected projection: %v", rows)
+	}
+
+	var count []int
+	if err := m.QueryCrossPartition(ctx, "SELECT VALUE COUNT(1) FROM c WHERE c.status = 'completed'", nil, &count); err != nil {
+		t.Fatalf("count failed: %v", err)
+	}
+	if len(count) != 1 || count[0] != 2 {
+		t.Errorf("expected count 2, got %v", count)
+	}
+
+	var ids []string
+	if err := m.QueryCrossPartition(ctx, "SELECT VALUE c.id FROM c WHERE c.fare >= @min ORDER BY c.id", []database.QueryParam{{Name: "@min", Value: 10}}, &ids); err != nil {
+		t.Fatalf("value query failed: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != "t1" || ids[1] != "t3" {
+		t.Errorf("unexpected ids: %v", ids)
+	}
+}
+
+func TestMockCosmosContainer_QueryErrors(t *testing.T) {
+	ctx := context.Background()
+	m := NewMockCosmosContainer()
+	var out []testTrip
+
+	queries := []string{
+		"SELECT DISTINCT c.status FROM c",
+		"SELECT * FROM c WHERE c.status = @missing",
+		"SELECT * FROM c WHERE UNKNOWN_FN(c.id)",
+		"SELECT * FROM c WHERE c.id = 'open",
+		"DELETE FROM c",
+	}
+	for _, q := range queries {
+		if err := m.QueryCrossPartition(ctx, q, nil, &out); apperrors.Code(err) != apperrors.CodeBadRequest {
+			t.Errorf("%q: expected bad request, got %v", q, err)
+		}
+	}
+}
+
+func TestMockCosmosContainer_Repository(t *testing.T) {
+	ctx := context.Background()
+	m := NewMockCosmosContainer()
+	seedTrips(t, m)
+
+	repo := database.NewRepository(m,
+		func(t *testTrip) string { return t.RiderID },
+		func(t *testTrip) string { return t.ID },
+	)
+
+	q := database.NewQuery().OrderBy("id")
+	page, err := repo.FindPage(ctx, "", q, 3, "")
+	if err != nil {
+		t.Fatalf("FindPage failed: %v", err)
+	}
+	if len(page.Items) != 3 || page.NextCursor == "" {
+		t.Fatalf("expected a full first page, got %+v", page)
+	}
+	page, err = repo.FindPage(ctx, "", q, 3, page.NextCursor)
+	if err != nil {
+		t.Fatalf("FindPage failed: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].ID != "t4" || page.NextCursor != "" {
+		t.Errorf("unexpected last page: %+v", page)
+	}
+
+	updated, err := repo.Modify(ctx, "r2", "t4", func(t *testTrip) error {
+		t.Status = "completed"
+		return nil
+	})
+	if err != nil || updated.Status != "completed" {
+		t.Fatalf("Modify failed: %+v, %v", updated, err)
+	}
+	found, err := repo.FindOne(ctx, "r2", database.NewQuery().Where("status", "=", "completed").OrderByDesc("fare"))
+	if err != nil || found.ID != "t3" {
+		t.Errorf("unexpected FindOne result: %+v, %v", found, err)
+	}
+
+	// Across partitions, and without changing the caller's query
+	found, err = repo.FindOne(ctx, "", q)
+	if err != nil || found.ID != "t1" {
+		t.Errorf("unexpected cross-partition FindOne result: %+v, %v", found, err)
+	}
+	if text, _, _ := q.Build(); text != "SELECT * FROM c ORDER BY c.id ASC" {
+		t.Errorf("expected the query left unchanged, got %q", text)
+	}
+	_, err = repo.FindOne(ctx, "", database.NewQuery().Where("status", "=", "lost"))
+	if !errors.Is(err, database.ErrNotFound) || apperrors.Code(err) != apperrors.CodeNotFound {
+		t.Errorf("expected a wrapped ErrNotFound, got %v", err)
+	}
+}
+
+func TestMockCosmosContainer_ShouldFail(t *testing.T) {
+	m := NewMockCosmosContainer()
+	failure := apperrors.Unavailable("cosmos down")
+	m.SetShouldFail(true, failure)
+
+	if err := m.Create(context.Background(), "r1", testTrip{ID: "t1"}); !errors.Is(err, failure) {
+		t.Errorf("expected injected failure, got %v", err)
+	}
+}
@@ -0,0 +1,931 @@
+// Package mocks provides mock implementations for testing.
+package mocks
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// This file implements the subset of Cosmos DB SQL understood by
+// MockCosmosContainer:
+//
+//	SELECT [TOP n] * | VALUE expr | VALUE COUNT(expr) | expr [AS name], ...
+//	FROM alias
+//	[WHERE expr]
+//	[ORDER BY expr [ASC|DESC], ...]
+//	[OFFSET n LIMIT m]
+//
+// Expressions support paths (c.a.b, c["a"], c.tags[0]), literals, @params,
+// AND/OR/NOT, comparisons (=, !=, <>, <, <=, >, >=), IN (...) and the
+// functions CONTAINS, STARTSWITH, ENDSWITH, IS_DEFINED, IS_NULL,
+// ARRAY_CONTAINS, ARRAY_LENGTH, LENGTH, LOWER and UPPER. As in Cosmos DB,
+// comparing values of different types or missing properties yields undefined,
+// which does not match.
+
+// undefinedValue is the value of a missing property.
+type undefinedValue struct{}
+
+var undefined = undefinedValue{}
+
+type sqlExpr interface {
+	eval(doc interface{}, params map[string]interface{}) interface{}
+}
+
+type literalExpr struct{ value interface{} }
+
+type paramExpr struct{ name string }
+
+// pathExpr is a property path below the query's alias. Segments are strings
+// (properties) or ints (array indexes).
+type pathExpr struct{ segments []interface{} }
+
+type notExpr struct{ x sqlExpr }
+
+type binaryExpr struct {
+	op          string
+	left, right sqlExpr
+}
+
+type inExpr struct {
+	x    sqlExpr
+	list []sqlExpr
+	not  bool
+}
+
+type funcExpr struct {
+	name string
+	args []sqlExpr
+}
+
+type sqlProjection struct {
+	expr sqlExpr
+	name string
+}
+
+type sqlOrder struct {
+	expr sqlExpr
+	desc bool
+}
+
+// cosmosQuery is a parsed query.
+type cosmosQuery struct {
+	top         sqlExpr
+	star        bool
+	value       sqlExpr
+	count       bool
+	projections []sqlProjection
+	where       sqlExpr
+	orderBy     []sqlOrder
+	offset      sqlExpr
+	limit       sqlExpr
+	params      []string
+}
+
+func (e literalExpr) eval(interface{}, map[string]interface{}) interface{} { return e.value }
+
+func (e paramExpr) eval(_ interface{}, params map[string]interface{}) interface{} {
+	if v, ok := params[e.name]; ok {
+		return v
+	}
+	return undefined
+}
+
+func (e pathExpr) eval(doc interface{}, _ map[string]interface{}) interface{} {
+	v := doc
+	for _, seg := range e.segments {
+		switch s := seg.(type) {
+		case string:
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return undefined
+			}
+			if v, ok = m[s]; !ok {
+				return undefined
+			}
+		case int:
+			a, ok := v.([]interface{})
+			if !ok || s < 0 || s >= len(a) {
+				return undefined
+			}
+			v = a[s]
+		}
+	}
+	return v
+}
+
+func (e notExpr) eval(doc interface{}, params map[string]interface{}) interface{} {
+	if b, ok := e.x.eval(doc, params).(bool); ok {
+		return !b
+	}
+	return undefined
+}
+
+func (e binaryExpr) eval(doc interface{}, params map[string]interface{}) interface{} {
+	left := e.left.eval(doc, params)
+	switch e.op {
+	case "AND":
+		if left == false {
+			return false
+		}
+		right := e.right.eval(doc, params)
+		if right == false {
+			return false
+		}
+		if left == true && right == true {
+			return true
+		}
+		return undefined
+	case "OR":
+		if left == true {
+			return true
+		}
+		right := e.right.eval(doc, params)
+		if right == true {
+			return true
+		}
+		if left == false && right == false {
+			return false
+		}
+		return undefined
+	}
+
+	right := e.right.eval(doc, params)
+	switch e.op {
+	case "=":
+		if equal, ok := sqlEqual(left, right); ok {
+			return equal
+		}
+	case "!=":
+		if equal, ok := sqlEqual(left, right); ok {
+			return !equal
+		}
+	default:
+		cmp, ok := sqlCompare(left, right)
+		if !ok {
+			return undefined
+		}
+		switch e.op {
+		case "<":
+			return cmp < 0
+		case "<=":
+			return cmp <= 0
+		case ">":
+			return cmp > 0
+		case ">=":
+			return cmp >= 0
+		}
+	}
+	return undefined
+}
+
+func (e inExpr) eval(doc interface{}, params map[string]interface{}) interface{} {
+	x := e.x.eval(doc, params)
+	if x == undefined {
+		return undefined
+	}
+	for _, item := range e.list {
+		if equal, ok := sqlEqual(x, item.eval(doc, params)); ok && equal {
+			return !e.not
+		}
+	}
+	return e.not
+}
+
+func (e funcExpr) eval(doc interface{}, params map[string]interface{}) interface{} {
+	args := make([]interface{}, len(e.args))
+	for i, arg := range e.args {
+		args[i] = arg.eval(doc, params)
+	}
+
+	switch e.name {
+	case "IS_DEFINED":
+		return args[0] != undefined
+	case "IS_NULL":
+		return args[0] == nil
+	case "CONTAINS", "STARTSWITH", "ENDSWITH":
+		s, ok1 := args[0].(string)
+		sub, ok2 := args[1].(string)
+		if !ok1 || !ok2 {
+			return undefined
+		}
+		if len(args) > 2 && args[2] == true {
+			s, sub = strings.ToLower(s), strings.ToLower(sub)
+		}
+		switch e.name {
+		case "CONTAINS":
+			return strings.Contains(s, sub)
+		case "STARTSWITH":
+			return strings.HasPrefix(s, sub)
+		}
+		return strings.HasSuffix(s, sub)
+	case "ARRAY_CONTAINS":
+		arr, ok := args[0].([]interface{})
+		if !ok {
+			return undefined
+		}
+		partial := len(args) > 2 && args[2] == true
+		for _, item := range arr {
+			if partial && containsFields(item, args[1]) {
+				return true
+			}
+			if equal, ok := sqlEqual(item, args[1]); ok && equal {
+				return true
+			}
+		}
+		return false
+	case "ARRAY_LENGTH":
+		if arr, ok := args[0].([]interface{}); ok {
+			return float64(len(arr))
+		}
+	case "LENGTH":
+		if s, ok := args[0].(string); ok {
+			return float64(len([]rune(s)))
+		}
+	case "LOWER", "UPPER":
+		if s, ok := args[0].(string); ok {
+			if e.name == "LOWER" {
+				return strings.ToLower(s)
+			}
+			return strings.ToUpper(s)
+		}
+	}
+	return undefined
+}
+
+// sqlFunctions maps supported functions to their minimum and maximum arity.
+var sqlFunctions = map[string][2]int{
+	"IS_DEFINED":     {1, 1},
+	"IS_NULL":        {1, 1},
+	"CONTAINS":       {2, 3},
+	"STARTSWITH":     {2, 3},
+	"ENDSWITH":       {2, 3},
+	"ARRAY_CONTAINS": {2, 3},
+	"ARRAY_LENGTH":   {1, 1},
+	"LENGTH":         {1, 1},
+	"LOWER":          {1, 1},
+	"UPPER":          {1, 1},
+}
+
+// containsFields reports whether item is an object holding all fields of
+// partial, as ARRAY_CONTAINS with partial matching does.
+func containsFields(item, partial interface{}) bool {
+	obj, ok1 := item.(map[string]interface{})
+	want, ok2 := partial.(map[string]interface{})
+	if !ok1 || !ok2 {
+		return false
+	}
+	for k, v := range want {
+		if equal, ok := sqlEqual(obj[k], v); !ok || !equal {
+			return false
+		}
+	}
+	return true
+}
+
+// typeRank orders values of different types as Cosmos DB does in ORDER BY.
+func typeRank(v interface{}) int {
+	switch v.(type) {
+	case undefinedValue:
+		return 0
+	case nil:
+		return 1
+	case bool:
+		return 2
+	case float64:
+		return 3
+	case string:
+		return 4
+	case []interface{}:
+		return 5
+	}
+	return 6
+}
+
+// sqlEqual compares two values; ok is false when the comparison is undefined.
+func sqlEqual(a, b interface{}) (equal, ok bool) {
+	if a == undefined || b == undefined || typeRank(a) != typeRank(b) {
+		return false, false
+	}
+	return reflect.DeepEqual(a, b), true
+}
+
+// sqlCompare orders two scalar values of the same type.
+func sqlCompare(a, b interface{}) (int, bool) {
+	switch x := a.(type) {
+	case float64:
+		if y, ok := b.(float64); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), true
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0, true
+			case !x:
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+	return 0, false
+}
+
+// orderCompare orders any two values for ORDER BY.
+func orderCompare(a, b interface{}) int {
+	if ra, rb := typeRank(a), typeRank(b); ra != rb {
+		return ra - rb
+	}
+	cmp, _ := sqlCompare(a, b)
+	return cmp
+}
+
+// run evaluates the query over docs and returns the JSON results. raw holds
+// the stored bytes of each document, returned as-is for SELECT *.
+func (q *cosmosQuery) run(docs []interface{}, raw []json.RawMessage, params map[string]interface{}) ([]json.RawMessage, error) {
+	for _, name := range q.params {
+		if _, ok := params[name]; !ok {
+			return nil, fmt.Errorf("parameter %s is not defined", name)
+		}
+	}
+
+	var matches []int
+	for i, doc := range docs {
+		if q.where == nil || q.where.eval(doc, params) == true {
+			matches = append(matches, i)
+		}
+	}
+
+	if len(q.orderBy) > 0 {
+		sort.SliceStable(matches, func(i, j int) bool {
+			for _, o := range q.orderBy {
+				cmp := orderCompare(o.expr.eval(docs[matches[i]], params), o.expr.eval(docs[matches[j]], params))
+				if cmp == 0 {
+					continue
+				}
+				if o.desc {
+					return cmp > 0
+				}
+				return cmp < 0
+			}
+			return false
+		})
+	}
+
+	if q.count {
+		n := 0
+		for _, i := range matches {
+			if q.value.eval(docs[i], params) != undefined {
+				n++
+			}
+		}
+		data, _ := json.Marshal(n)
+		return []json.RawMessage{data}, nil
+	}
+
+	offset, limit := 0, -1
+	if q.offset != nil {
+		var err error
+		if offset, err = intValue(q.offset.eval(nil, params), "OFFSET"); err != nil {
+			return nil, err
+		}
+		if limit, err = intValue(q.limit.eval(nil, params), "LIMIT"); err != nil {
+			return nil, err
+		}
+	}
+	if q.top != nil {
+		top, err := intValue(q.top.eval(nil, params), "TOP")
+		if err != nil {
+			return nil, err
+		}
+		if limit < 0 || top < limit {
+			limit = top
+		}
+	}
+	if offset >= len(matches) {
+		matches = nil
+	} else {
+		matches = matches[offset:]
+	}
+	if limit >= 0 && limit < len(matches) {
+		matches = matches[:limit]
+	}
+
+	results := make([]json.RawMessage, 0, len(matches))
+	for _, i := range matches {
+		var out interface{}
+		switch {
+		case q.star:
+			results = append(results, raw[i])
+			continue
+		case q.value != nil:
+			out = q.value.eval(docs[i], params)
+			if out == undefined {
+				continue
+			}
+		default:
+			obj := make(map[string]interface{}, len(q.projections))
+			for _, p := range q.projections {
+				if v := p.expr.eval(docs[i], params); v != undefined {
+					obj[p.name] = v
+				}
+			}
+			out = obj
+		}
+		data, err := json.Marshal(out)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, data)
+	}
+	return results, nil
+}
+
+func intValue(v interface{}, clause string) (int, error) {
+	f, ok := v.(float64)
+	if !ok || f < 0 || f != float64(int(f)) {
+		return 0, fmt.Errorf("%s requires a non-negative integer", clause)
+	}
+	return int(f), nil
+}
+
+// normalizeJSON converts a Go value into its generic JSON form (float64,
+// string, bool, nil, []interface{}, map[string]interface{}).
+func normalizeJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Parsing
+
+type sqlTokenKind int
+
+const (
+	tokEOF sqlTokenKind = iota
+	tokIdent
+	tokParam
+	tokNumber
+	tokString
+	tokSymbol
+)
+
+type sqlToken struct {
+	kind sqlTokenKind
+	text string
+}
+
+func tokenizeSQL(input string) ([]sqlToken, error) {
+	var tokens []sqlToken
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsLetter(r) || r == '_' || r == '@':
+			start := i
+			i++
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			kind := tokIdent
+			if r == '@' {
+				kind = tokParam
+			}
+			tokens = append(tokens, sqlToken{kind: kind, text: string(runes[start:i])})
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == 'e' || runes[i] == 'E') {
+				i++
+			}
+			tokens = append(tokens, sqlToken{kind: tokNumber, text: string(runes[start:i])})
+		case r == '\'' || r == '"':
+			var b strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				b.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			i++
+			tokens = append(tokens, sqlToken{kind: tokString, text: b.String()})
+		default:
+			if i+1 < len(runes) {
+				switch two := string(runes[i : i+2]); two {
+				case "!=", "<>", "<=", ">=":
+					if two == "<>" {
+						two = "!="
+					}
+					tokens = append(tokens, sqlToken{kind: tokSymbol, text: two})
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("=<>(),.[]*", r) {
+				return nil, fmt.Errorf("unexpected character %q", r)
+			}
+			tokens = append(tokens, sqlToken{kind: tokSymbol, text: string(r)})
+			i++
+		}
+	}
+	return append(tokens, sqlToken{kind: tokEOF}), nil
+}
+
+type sqlParser struct {
+	tokens []sqlToken
+	pos    int
+	alias  string
+	params []string
+}
+
+// parseCosmosQuery parses a query in the supported subset.
+func parseCosmosQuery(input string) (*cosmosQuery, error) {
+	tokens, err := tokenizeSQL(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &sqlParser{tokens: tokens}
+	q, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("unsupported query %q: %w", input, err)
+	}
+	return q, nil
+}
+
+func (p *sqlParser) peek() sqlToken { return p.tokens[p.pos] }
+
+func (p *sqlParser) next() sqlToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *sqlParser) isKeyword(kw string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && strings.EqualFold(t.text, kw)
+}
+
+func (p *sqlParser) acceptKeyword(kw string) bool {
+	if p.isKeyword(kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *sqlParser) expectKeyword(kw string) error {
+	if !p.acceptKeyword(kw) {
+		return fmt.Errorf("expected %s, got %q", kw, p.peek().text)
+	}
+	return nil
+}
+
+func (p *sqlParser) acceptSymbol(sym string) bool {
+	if t := p.peek(); t.kind == tokSymbol && t.text == sym {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *sqlParser) expectSymbol(sym string) error {
+	if !p.acceptSymbol(sym) {
+		return fmt.Errorf("expected %q, got %q", sym, p.peek().text)
+	}
+	return nil
+}
+
+func (p *sqlParser) parse() (*cosmosQuery, error) {
+	q := &cosmosQuery{}
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return nil, err
+	}
+	if p.isKeyword("DISTINCT") {
+		return nil, fmt.Errorf("DISTINCT is not supported")
+	}
+
+	// The alias is only known after FROM; parse the select list lazily.
+	selectStart := p.pos
+	for p.peek().kind != tokEOF && (!p.isKeyword("FROM") || p.tokens[p.pos-1].text == ".") {
+		p.next()
+	}
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	alias := p.next()
+	if alias.kind != tokIdent {
+		return nil, fmt.Errorf("expected alias after FROM")
+	}
+	p.alias = alias.text
+	rest := p.pos
+
+	p.pos = selectStart
+	if err := p.parseSelect(q); err != nil {
+		return nil, err
+	}
+	if !p.isKeyword("FROM") {
+		return nil, fmt.Errorf("unexpected %q in select list", p.peek().text)
+	}
+	p.pos = rest
+
+	var err error
+	if p.acceptKeyword("WHERE") {
+		if q.where, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("ORDER") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			o := sqlOrder{}
+			if o.expr, err = p.parseExpr(); err != nil {
+				return nil, err
+			}
+			if p.acceptKeyword("DESC") {
+				o.desc = true
+			} else {
+				p.acceptKeyword("ASC")
+			}
+			q.orderBy = append(q.orderBy, o)
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+	}
+	if p.acceptKeyword("OFFSET") {
+		if q.offset, err = p.parsePrimary(); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("LIMIT"); err != nil {
+			return nil, err
+		}
+		if q.limit, err = p.parsePrimary(); err != nil {
+			return nil, err
+		}
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q", t.text)
+	}
+
+	q.params = p.params
+	return q, nil
+}
+
+func (p *sqlParser) parseSelect(q *cosmosQuery) error {
+	var err error
+	if p.acceptKeyword("TOP") {
+		if q.top, err = p.parsePrimary(); err != nil {
+			return err
+		}
+	}
+	if p.acceptSymbol("*") {
+		q.star = true
+		return nil
+	}
+	if p.acceptKeyword("VALUE") {
+		if p.isKeyword("COUNT") {
+			p.next()
+			if err := p.expectSymbol("("); err != nil {
+				return err
+			}
+			if q.value, err = p.parseExpr(); err != nil {
+				return err
+			}
+			q.count = true
+			return p.expectSymbol(")")
+		}
+		q.value, err = p.parseExpr()
+		return err
+	}
+
+	for {
+		proj := sqlProjection{}
+		if proj.expr, err = p.parseExpr(); err != nil {
+			return err
+		}
+		if p.acceptKeyword("AS") {
+			name := p.next()
+			if name.kind != tokIdent {
+				return fmt.Errorf("expected name after AS")
+			}
+			proj.name = name.text
+		} else if path, ok := proj.expr.(pathExpr); ok && len(path.segments) > 0 {
+			proj.name, _ = path.segments[len(path.segments)-1].(string)
+		}
+		if proj.name == "" {
+			proj.name = fmt.Sprintf("$%d", len(q.projections)+1)
+		}
+		q.projections = append(q.projections, proj)
+		if !p.acceptSymbol(",") {
+			return nil
+		}
+	}
+}
+
+func (p *sqlParser) parseExpr() (sqlExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: "OR", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *sqlParser) parseAnd() (sqlExpr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: "AND", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *sqlParser) parseNot() (sqlExpr, error) {
+	if p.acceptKeyword("NOT") {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{x: x}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *sqlParser) parseComparison() (sqlExpr, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	not := false
+	if p.isKeyword("NOT") && p.pos+1 < len(p.tokens) && strings.EqualFold(p.tokens[p.pos+1].text, "IN") {
+		p.next()
+		not = true
+	}
+	if p.acceptKeyword("IN") {
+		if err := p.expectSymbol("("); err != nil {
+			return nil, err
+		}
+		in := inExpr{x: left, not: not}
+		for {
+			item, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			in.list = append(in.list, item)
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+		return in, p.expectSymbol(")")
+	}
+
+	if t := p.peek(); t.kind == tokSymbol {
+		switch t.text {
+		case "=", "!=", "<", "<=", ">", ">=":
+			p.next()
+			right, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			return binaryExpr{op: t.text, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *sqlParser) parsePrimary() (sqlExpr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokParam:
+		p.params = append(p.params, t.text)
+		return paramExpr{name: t.text}, nil
+	case tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", t.text)
+		}
+		return literalExpr{value: f}, nil
+	case tokString:
+		return literalExpr{value: t.text}, nil
+	case tokSymbol:
+		if t.text == "(" {
+			x, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expectSymbol(")")
+		}
+	case tokIdent:
+		switch {
+		case t.text == p.alias:
+			return p.parsePath()
+		case strings.EqualFold(t.text, "true"):
+			return literalExpr{value: true}, nil
+		case strings.EqualFold(t.text, "false"):
+			return literalExpr{value: false}, nil
+		case strings.EqualFold(t.text, "null"):
+			return literalExpr{value: nil}, nil
+		case strings.EqualFold(t.text, "undefined"):
+			return literalExpr{value: undefined}, nil
+		}
+		name := strings.ToUpper(t.text)
+		arity, ok := sqlFunctions[name]
+		if !ok || !p.acceptSymbol("(") {
+			return nil, fmt.Errorf("unknown identifier %q", t.text)
+		}
+		fn := funcExpr{name: name}
+		for !p.acceptSymbol(")") {
+			if len(fn.args) > 0 {
+				if err := p.expectSymbol(","); err != nil {
+					return nil, err
+				}
+			}
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			fn.args = append(fn.args, arg)
+		}
+		if len(fn.args) < arity[0] || len(fn.args) > arity[1] {
+			return nil, fmt.Errorf("%s takes %d to %d arguments", name, arity[0], arity[1])
+		}
+		return fn, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", t.text)
+}
+
+func (p *sqlParser) parsePath() (sqlExpr, error) {
+	path := pathExpr{}
+	for {
+		switch {
+		case p.acceptSymbol("."):
+			t := p.next()
+			if t.kind != tokIdent {
+				return nil, fmt.Errorf("expected property name after '.'")
+			}
+			path.segments = append(path.segments, t.text)
+		case p.acceptSymbol("["):
+			t := p.next()
+			switch t.kind {
+			case tokString:
+				path.segments = append(path.segments, t.text)
+			case tokNumber:
+				n, err := strconv.Atoi(t.text)
+				if err != nil {
+					return nil, fmt.Errorf("invalid array index %q", t.text)
+				}
+				path.segments = append(path.segments, n)
+			default:
+				return nil, fmt.Errorf("expected property name or index in brackets")
+			}
+			if err := p.expectSymbol("]"); err != nil {
+				return nil, err
+			}
+		default:
+			return path, nil
+		}
+	}
+}
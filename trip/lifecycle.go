@@ -0,0 +1,301 @@
+// Package trip provides the trip lifecycle state machine.
+package trip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mycobrun/cobrun-shared/database/cosmosdb"
+	"github.com/mycobrun/cobrun-shared/logging"
+)
+
+// Status represents a trip status.
+type Status string
+
+const (
+	StatusRequested     Status = "requested"
+	StatusAccepted      Status = "accepted"
+	StatusDriverEnRoute Status = "driver_en_route"
+	StatusDriverArrived Status = "driver_arrived"
+	StatusInProgress    Status = "in_progress"
+	StatusCompleted     Status = "completed"
+	StatusCancelled     Status = "cancelled"
+
+	// StatusArriving is the legacy name of StatusDriverArrived, still found
+	// on trips saved before this package. Such trips move on as if
+	// driver_arrived; no transition leads to it.
+	StatusArriving Status = "arriving"
+)
+
+// transitions lists the statuses reachable from each status.
+var transitions = map[Status][]Status{
+	StatusRequested:     {StatusAccepted, StatusCancelled},
+	StatusAccepted:      {StatusDriverEnRoute, StatusDriverArrived, StatusCancelled},
+	StatusDriverEnRoute: {StatusDriverArrived, StatusCancelled},
+	StatusDriverArrived: {StatusInProgress, StatusCancelled},
+	StatusArriving:      {StatusInProgress, StatusCancelled},
+	StatusInProgress:    {StatusCompleted, StatusCancelled},
+	StatusCompleted:     {},
+	StatusCancelled:     {},
+}
+
+// IsValid checks if the status is a known trip status.
+func (s Status) IsValid() bool {
+	_, ok := transitions[s]
+	return ok
+}
+
+// IsTerminal reports whether no transition leaves the status.
+func (s Status) IsTerminal() bool {
+	return s == StatusCompleted || s == StatusCancelled
+}
+
+// CanTransition reports whether a trip may move from one status to another.
+func (s Status) CanTransition(to Status) bool {
+	for _, next := range transitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowedTransitions returns the statuses reachable from the status.
+func (s Status) AllowedTransitions() []Status {
+	return append([]Status(nil), transitions[s]...)
+}
+
+// Lifecycle errors.
+var (
+	// ErrInvalidTransition means the transition is not allowed from the trip's status.
+	ErrInvalidTransition = errors.New("invalid trip transition")
+	// ErrGuardFailed means the trip does not meet the preconditions of the transition.
+	ErrGuardFailed = errors.New("trip transition precondition failed")
+)
+
+// Transition describes a requested status change.
+type Transition struct {
+	To Status
+	// Actor performed the transition; nil records the system.
+	Actor *logging.AuditActor
+	// At is the transition time (default: now).
+	At time.Time
+
+	// DriverID and VehicleID are assigned on acceptance.
+	DriverID  string
+	VehicleID string
+
+	// CancelledBy (rider, driver or system), Reason and CancellationFee apply to cancellation.
+	CancelledBy     string
+	Reason          string
+	CancellationFee float64
+
+	// ActualFare applies to completion.
+	ActualFare float64
+}
+
+// Guard checks a transition against the trip before it is applied.
+type Guard func(trip *cosmosdb.Trip, t Transition) error
+
+// Machine applies trip transitions, enforcing the allowed transitions and
+// guards, setting timestamps and recording audit events.
+type Machine struct {
+	audit  *logging.AuditLogger
+	guards map[Status][]Guard
+	now    func() time.Time
+}
+
+// NewMachine creates a trip state machine with the default guards.
+// The audit logger is optional.
+func NewMachine(audit *logging.AuditLogger) *Machine {
+	return &Machine{
+		audit: audit,
+		guards: map[Status][]Guard{
+			StatusAccepted:      {requireDriver},
+			StatusDriverEnRoute: {requireDriver},
+			StatusDriverArrived: {requireDriver},
+			StatusInProgress:    {requireDriver, requireVehicle},
+			StatusCompleted:     {requireStarted},
+			StatusCancelled:     {requireCanceller},
+		},
+		now: time.Now,
+	}
+}
+
+// WithGuard adds a guard for transitions into the given status.
+func (m *Machine) WithGuard(to Status, guard Guard) *Machine {
+	m.guards[to] = append(m.guards[to], guard)
+	return m
+}
+
+// Init prepares a new trip in the requested status and returns its creation
+// event to record once the trip is saved.
+func (m *Machine) Init(trip *cosmosdb.Trip, actor *logging.AuditActor) *Event {
+	now := m.now().UTC()
+	trip.Status = string(StatusRequested)
+	if trip.CreatedAt.IsZero() {
+		trip.CreatedAt = now
+	}
+	trip.UpdatedAt = now
+
+	return &Event{Type: logging.AuditEventTripCreated, Trip: trip, Actor: actor}
+}
+
+// Event is the audit event of a created trip or an applied transition. It is
+// recorded with Record once the trip has been saved.
+type Event struct {
+	Type    logging.AuditEventType
+	Trip    *cosmosdb.Trip
+	Actor   *logging.AuditActor
+	Details map[string]interface{}
+}
+
+// Apply moves the trip to t.To in memory and returns the event to record once
+// the trip is saved. Denied transitions are audited and return an error
+// wrapping ErrInvalidTransition or ErrGuardFailed; the trip is left unchanged.
+func (m *Machine) Apply(ctx context.Context, trip *cosmosdb.Trip, t Transition) (*Event, error) {
+	from := Status(trip.Status)
+	if err := m.check(trip, from, t); err != nil {
+		m.record(ctx, auditEventType(t.To), trip, t.Actor, logging.AuditOutcomeDenied, map[string]interface{}{
+			"from":  string(from),
+			"to":    string(t.To),
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	at := t.At
+	if at.IsZero() {
+		at = m.now()
+	}
+	at = at.UTC()
+
+	switch t.To {
+	case StatusAccepted:
+		if t.DriverID != "" {
+			trip.DriverID = t.DriverID
+		}
+		if t.VehicleID != "" {
+			trip.VehicleID = t.VehicleID
+		}
+	case StatusDriverEnRoute:
+		trip.EnRouteAt = &at
+	case StatusDriverArrived:
+		trip.ArrivedAt = &at
+	case StatusInProgress:
+		trip.StartedAt = &at
+	case StatusCompleted:
+		trip.CompletedAt = &at
+		if t.ActualFare > 0 {
+			trip.ActualFare = t.ActualFare
+		}
+		if trip.StartedAt != nil {
+			trip.ActualDurationSecs = int(at.Sub(*trip.StartedAt).Seconds())
+		}
+	case StatusCancelled:
+		trip.CancelledAt = &at
+		trip.CancelledBy = t.CancelledBy
+		trip.CancellationReason = t.Reason
+		trip.CancellationFee = t.CancellationFee
+	}
+	trip.Status = string(t.To)
+	trip.UpdatedAt = at
+
+	details := map[string]interface{}{
+		"from": string(from),
+		"to":   string(t.To),
+	}
+	if t.Reason != "" {
+		details["reason"] = t.Reason
+	}
+	return &Event{Type: auditEventType(t.To), Trip: trip, Actor: t.Actor, Details: details}, nil
+}
+
+// Record audits a creation or transition returned by Init or Apply.
+func (m *Machine) Record(ctx context.Context, e *Event) {
+	m.record(ctx, e.Type, e.Trip, e.Actor, logging.AuditOutcomeSuccess, e.Details)
+}
+
+func (m *Machine) check(trip *cosmosdb.Trip, from Status, t Transition) error {
+	if !from.CanTransition(t.To) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, t.To)
+	}
+	for _, guard := range m.guards[t.To] {
+		if err := guard(trip, t); err != nil {
+			return fmt.Errorf("%w: %v", ErrGuardFailed, err)
+		}
+	}
+	return nil
+}
+
+func (m *Machine) record(ctx context.Context, eventType logging.AuditEventType, trip *cosmosdb.Trip, actor *logging.AuditActor, outcome logging.AuditOutcome, details map[string]interface{}) {
+	if m.audit == nil {
+		return
+	}
+	if actor == nil {
+		actor = &logging.AuditActor{Type: "system"}
+	}
+	m.audit.LogUserAction(ctx, eventType, actor, &logging.AuditResource{
+		Type: "trip",
+		ID:   trip.ID,
+		Identifiers: map[string]string{
+			"rider_id":  trip.RiderID,
+			"driver_id": trip.DriverID,
+		},
+	}, outcome, details)
+}
+
+func auditEventType(to Status) logging.AuditEventType {
+	switch to {
+	case StatusAccepted:
+		return logging.AuditEventTripAccepted
+	case StatusDriverEnRoute:
+		return logging.AuditEventTripDriverEnRoute
+	case StatusDriverArrived:
+		return logging.AuditEventTripDriverArrived
+	case StatusInProgress:
+		return logging.AuditEventTripStarted
+	case StatusCompleted:
+		return logging.AuditEventTripCompleted
+	case StatusCancelled:
+		return logging.AuditEventTripCancelled
+	default:
+		return logging.AuditEventType("trip." + string(to))
+	}
+}
+
+// Default guards
+
+func requireDriver(trip *cosmosdb.Trip, t Transition) error {
+	if trip.DriverID == "" && (t.To != StatusAccepted || t.DriverID == "") {
+		return errors.New("trip has no driver")
+	}
+	if t.To != StatusAccepted && t.DriverID != "" && t.DriverID != trip.DriverID {
+		return fmt.Errorf("driver %s is not assigned to the trip", t.DriverID)
+	}
+	return nil
+}
+
+func requireVehicle(trip *cosmosdb.Trip, _ Transition) error {
+	if trip.VehicleID == "" {
+		return errors.New("trip has no vehicle")
+	}
+	return nil
+}
+
+func requireStarted(trip *cosmosdb.Trip, _ Transition) error {
+	if trip.StartedAt == nil {
+		return errors.New("trip was never started")
+	}
+	return nil
+}
+
+func requireCanceller(_ *cosmosdb.Trip, t Transition) error {
+	switch t.CancelledBy {
+	case "rider", "driver", "system":
+		return nil
+	}
+	return fmt.Errorf("invalid cancelled_by %q", t.CancelledBy)
+}
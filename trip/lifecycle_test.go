@@ -0,0 +1,321 @@
+package trip
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mycobrun/cobrun-shared/database"
+	"github.com/mycobrun/cobrun-shared/database/cosmosdb"
+	"github.com/mycobrun/cobrun-shared/logging"
+)
+
+func TestStatusCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to Status
+		want     bool
+	}{
+		{StatusRequested, StatusAccepted, true},
+		{StatusRequested, StatusInProgress, false},
+		{StatusAccepted, StatusDriverEnRoute, true},
+		{StatusAccepted, StatusDriverArrived, true},
+		{StatusDriverArrived, StatusInProgress, true},
+		{StatusInProgress, StatusCompleted, true},
+		{StatusInProgress, StatusCancelled, true},
+		{StatusCompleted, StatusAccepted, false},
+		{StatusCancelled, StatusRequested, false},
+		{StatusArriving, StatusInProgress, true},
+		{StatusArriving, StatusCancelled, true},
+		{StatusAccepted, StatusArriving, false},
+		{Status("bogus"), StatusAccepted, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.CanTransition(tt.to); got != tt.want {
+			t.Errorf("%s -> %s: expected %v, got %v", tt.from, tt.to, tt.want, got)
+		}
+	}
+}
+
+func newTestMachine(buf *bytes.Buffer) *Machine {
+	audit := logging.NewAuditLogger(logging.AuditLoggerConfig{
+		ServiceName: "trip-service",
+		Logger:      slog.New(slog.NewJSONHandler(buf, nil)),
+	})
+	return NewMachine(audit)
+}
+
+func TestMachine_Lifecycle(t *testing.T) {
+	var buf bytes.Buffer
+	m := newTestMachine(&buf)
+	ctx := context.Background()
+
+	trip := &cosmosdb.Trip{ID: "trip-1", RiderID: "rider-1"}
+	m.Record(ctx, m.Init(trip, &logging.AuditActor{Type: "user", ID: "rider-1"}))
+	if trip.Status != string(StatusRequested) || trip.CreatedAt.IsZero() {
+		t.Fatalf("expected requested trip, got %+v", trip)
+	}
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	steps := []Transition{
+		{To: StatusAccepted, DriverID: "driver-1", VehicleID: "vehicle-1", At: start},
+		{To: StatusDriverEnRoute, At: start.Add(time.Minute)},
+		{To: StatusDriverArrived, At: start.Add(5 * time.Minute)},
+		{To: StatusInProgress, At: start.Add(7 * time.Minute)},
+		{To: StatusCompleted, ActualFare: 23.5, At: start.Add(27 * time.Minute)},
+	}
+	for _, step := range steps {
+		event, err := m.Apply(ctx, trip, step)
+		if err != nil {
+			t.Fatalf("transition to %s failed: %v", step.To, err)
+		}
+		m.Record(ctx, event)
+	}
+
+	if trip.DriverID != "driver-1" || trip.VehicleID != "vehicle-1" {
+		t.Errorf("expected driver and vehicle assigned, got %s/%s", trip.DriverID, trip.VehicleID)
+	}
+	if trip.EnRouteAt == nil || trip.ArrivedAt == nil || trip.StartedAt == nil || trip.CompletedAt == nil {
+		t.Fatalf("expected all timestamps set, got %+v", trip)
+	}
+	if trip.ActualDurationSecs != 20*60 || trip.ActualFare != 23.5 {
+		t.Errorf("expected 20 minute trip with fare 23.5, got %d/%f", trip.ActualDurationSecs, trip.ActualFare)
+	}
+	if !trip.UpdatedAt.Equal(start.Add(27 * time.Minute)) {
+		t.Errorf("expected UpdatedAt at completion, got %v", trip.UpdatedAt)
+	}
+
+	// Completed trips cannot be reopened
+	_, err := m.Apply(ctx, trip, Transition{To: StatusAccepted, DriverID: "driver-2"})
+	if !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("expected ErrInvalidTransition, got %v", err)
+	}
+	if trip.Status != string(StatusCompleted) || trip.DriverID != "driver-1" {
+		t.Errorf("expected trip unchanged, got %s/%s", trip.Status, trip.DriverID)
+	}
+
+	logs := buf.String()
+	for _, event := range []string{"trip.created", "trip.accepted", "trip.started", "trip.completed"} {
+		if !strings.Contains(logs, event) {
+			t.Errorf("expected audit event %s", event)
+		}
+	}
+	if !strings.Contains(logs, `"outcome":"denied"`) {
+		t.Error("expected denied transition to be audited")
+	}
+}
+
+func TestMachine_Guards(t *testing.T) {
+	m := NewMachine(nil)
+	ctx := context.Background()
+
+	t.Run("AcceptWithoutDriver", func(t *testing.T) {
+		trip := &cosmosdb.Trip{ID: "trip-1", Status: string(StatusRequested)}
+		_, err := m.Apply(ctx, trip, Transition{To: StatusAccepted})
+		if !errors.Is(err, ErrGuardFailed) {
+			t.Errorf("expected ErrGuardFailed, got %v", err)
+		}
+	})
+
+	t.Run("StartWithoutVehicle", func(t *testing.T) {
+		trip := &cosmosdb.Trip{ID: "trip-1", DriverID: "driver-1", Status: string(StatusDriverArrived)}
+		_, err := m.Apply(ctx, trip, Transition{To: StatusInProgress})
+		if !errors.Is(err, ErrGuardFailed) {
+			t.Errorf("expected ErrGuardFailed, got %v", err)
+		}
+		if trip.StartedAt != nil {
+			t.Error("StartedAt should not be set on a failed transition")
+		}
+	})
+
+	t.Run("WrongDriver", func(t *testing.T) {
+		trip := &cosmosdb.Trip{ID: "trip-1", DriverID: "driver-1", Status: string(StatusAccepted)}
+		_, err := m.Apply(ctx, trip, Transition{To: StatusDriverEnRoute, DriverID: "driver-2"})
+		if !errors.Is(err, ErrGuardFailed) {
+			t.Errorf("expected ErrGuardFailed, got %v", err)
+		}
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		trip := &cosmosdb.Trip{ID: "trip-1", Status: string(StatusRequested)}
+		if _, err := m.Apply(ctx, trip, Transition{To: StatusCancelled}); !errors.Is(err, ErrGuardFailed) {
+			t.Errorf("expected ErrGuardFailed without canceller, got %v", err)
+		}
+		_, err := m.Apply(ctx, trip, Transition{To: StatusCancelled, CancelledBy: "rider", Reason: "changed plans", CancellationFee: 5})
+		if err != nil {
+			t.Fatalf("cancel failed: %v", err)
+		}
+		if trip.CancelledAt == nil || trip.CancelledBy != "rider" || trip.CancellationFee != 5 {
+			t.Errorf("expected cancellation recorded, got %+v", trip)
+		}
+	})
+
+	t.Run("CustomGuard", func(t *testing.T) {
+		m := NewMachine(nil).WithGuard(StatusCompleted, func(trip *cosmosdb.Trip, t Transition) error {
+			if t.ActualFare <= 0 {
+				return errors.New("fare required")
+			}
+			return nil
+		})
+		now := time.Now()
+		trip := &cosmosdb.Trip{ID: "trip-1", Status: string(StatusInProgress), StartedAt: &now}
+		if _, err := m.Apply(ctx, trip, Transition{To: StatusCompleted}); !errors.Is(err, ErrGuardFailed) {
+			t.Errorf("expected ErrGuardFailed, got %v", err)
+		}
+	})
+}
+
+// fakeStore simulates Cosmos ETags; conflicts forces that many concurrent writes.
+type fakeStore struct {
+	trip       cosmosdb.Trip
+	version    int
+	conflicts  int
+	onConflict func(trip *cosmosdb.Trip)
+	createErr  error
+}
+
+func (s *fakeStore) Create(_ context.Context, trip *cosmosdb.Trip) error {
+	if s.createErr != nil {
+		return s.createErr
+	}
+	s.trip = *trip
+	return nil
+}
+
+func (s *fakeStore) Get(_ context.Context, _, _ string) (*cosmosdb.Trip, error) {
+	trip := s.trip
+	trip.ETag = etagOf(s.version)
+	return &trip, nil
+}
+
+func (s *fakeStore) Replace(_ context.Context, trip *cosmosdb.Trip) error {
+	if s.conflicts > 0 {
+		s.conflicts--
+		s.version++
+		if s.onConflict != nil {
+			s.onConflict(&s.trip)
+		}
+	}
+	if trip.ETag != etagOf(s.version) {
+		return database.ErrPreconditionFailed
+	}
+	s.version++
+	s.trip = *trip
+	trip.ETag = etagOf(s.version)
+	return nil
+}
+
+func etagOf(version int) string {
+	return string(rune('a' + version))
+}
+
+func TestMachine_Update(t *testing.T) {
+	ctx := context.Background()
+	m := NewMachine(nil)
+
+	t.Run("RetriesOnConflict", func(t *testing.T) {
+		store := &fakeStore{
+			trip:      cosmosdb.Trip{ID: "trip-1", RiderID: "rider-1", Status: string(StatusRequested)},
+			conflicts: 1,
+			onConflict: func(trip *cosmosdb.Trip) {
+				trip.DropoffAddress = "updated concurrently"
+			},
+		}
+		trip, err := m.Update(ctx, store, "rider-1", "trip-1", Transition{To: StatusAccepted, DriverID: "driver-1"})
+		if err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if trip.Status != string(StatusAccepted) || store.trip.DropoffAddress != "updated concurrently" {
+			t.Errorf("expected both writes kept, got %+v", store.trip)
+		}
+	})
+
+	t.Run("RecheckedAfterConflict", func(t *testing.T) {
+		store := &fakeStore{
+			trip:      cosmosdb.Trip{ID: "trip-1", RiderID: "rider-1", Status: string(StatusRequested)},
+			conflicts: 1,
+			onConflict: func(trip *cosmosdb.Trip) {
+				trip.Status = string(StatusCancelled)
+			},
+		}
+		_, err := m.Update(ctx, store, "rider-1", "trip-1", Transition{To: StatusAccepted, DriverID: "driver-1"})
+		if !errors.Is(err, ErrInvalidTransition) {
+			t.Errorf("expected ErrInvalidTransition, got %v", err)
+		}
+		if store.trip.Status != string(StatusCancelled) {
+			t.Errorf("expected cancellation kept, got %s", store.trip.Status)
+		}
+	})
+
+	t.Run("Create", func(t *testing.T) {
+		var buf bytes.Buffer
+		m := newTestMachine(&buf)
+		store := &fakeStore{createErr: errors.New("cosmos down")}
+		if err := m.Create(ctx, store, &cosmosdb.Trip{ID: "trip-1", RiderID: "rider-1"}, nil); err == nil {
+			t.Fatal("expected Create to fail")
+		}
+		if buf.Len() != 0 {
+			t.Errorf("expected no audit event for an unsaved trip, got %s", buf.String())
+		}
+
+		store.createErr = nil
+		if err := m.Create(ctx, store, &cosmosdb.Trip{ID: "trip-1", RiderID: "rider-1"}, nil); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if store.trip.Status != string(StatusRequested) || !strings.Contains(buf.String(), "trip.created") {
+			t.Errorf("expected a saved and audited trip, got %+v", store.trip)
+		}
+	})
+
+	t.Run("LegacyArriving", func(t *testing.T) {
+		store := &fakeStore{
+			trip: cosmosdb.Trip{ID: "trip-1", RiderID: "rider-1", DriverID: "driver-1", Status: string(StatusArriving)},
+		}
+		trip, err := m.Update(ctx, store, "rider-1", "trip-1", Transition{To: StatusCancelled, CancelledBy: "rider"})
+		if err != nil || trip.Status != string(StatusCancelled) {
+			t.Errorf("expected an arriving trip to be cancellable, got %v", err)
+		}
+	})
+
+	t.Run("AuditedOnceSaved", func(t *testing.T) {
+		var buf bytes.Buffer
+		m := newTestMachine(&buf)
+		store := &fakeStore{
+			trip:      cosmosdb.Trip{ID: "trip-1", RiderID: "rider-1", Status: string(StatusRequested)},
+			conflicts: 1,
+		}
+		if _, err := m.Update(ctx, store, "rider-1", "trip-1", Transition{To: StatusAccepted, DriverID: "driver-1"}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if n := strings.Count(buf.String(), "\n"); n != 1 {
+			t.Errorf("expected one audit event for the saved transition, got %d", n)
+		}
+
+		buf.Reset()
+		store = &fakeStore{
+			trip:      cosmosdb.Trip{ID: "trip-2", RiderID: "rider-1", Status: string(StatusRequested)},
+			conflicts: DefaultMaxAttempts,
+		}
+		if _, err := m.Update(ctx, store, "rider-1", "trip-2", Transition{To: StatusAccepted, DriverID: "driver-1"}); err == nil {
+			t.Fatal("expected Update to fail")
+		}
+		if strings.Contains(buf.String(), "trip.accepted") {
+			t.Error("expected no audit event for an unsaved transition")
+		}
+	})
+
+	t.Run("GivesUp", func(t *testing.T) {
+		store := &fakeStore{
+			trip:      cosmosdb.Trip{ID: "trip-1", RiderID: "rider-1", Status: string(StatusRequested)},
+			conflicts: DefaultMaxAttempts,
+		}
+		_, err := m.Update(ctx, store, "rider-1", "trip-1", Transition{To: StatusAccepted, DriverID: "driver-1"})
+		if !errors.Is(err, database.ErrPreconditionFailed) {
+			t.Errorf("expected ErrPreconditionFailed, got %v", err)
+		}
+	})
+}
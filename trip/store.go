@@ -0,0 +1,99 @@
+// Package trip provides the trip lifecycle state machine.
+package trip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mycobrun/cobrun-shared/database"
+	"github.com/mycobrun/cobrun-shared/database/cosmosdb"
+	"github.com/mycobrun/cobrun-shared/logging"
+)
+
+// Store loads and saves trips with optimistic concurrency.
+type Store interface {
+	// Create saves a new trip.
+	Create(ctx context.Context, trip *cosmosdb.Trip) error
+	// Get returns the trip with its current ETag.
+	Get(ctx context.Context, riderID, tripID string) (*cosmosdb.Trip, error)
+	// Replace saves the trip if its ETag still matches, updating the ETag.
+	// Returns database.ErrPreconditionFailed if the trip changed since it was read.
+	Replace(ctx context.Context, trip *cosmosdb.Trip) error
+}
+
+// CosmosStore stores trips in a Cosmos DB container partitioned by rider ID.
+type CosmosStore struct {
+	container database.Container
+}
+
+// NewCosmosStore creates a trip store backed by a Cosmos DB container.
+func NewCosmosStore(container database.Container) *CosmosStore {
+	return &CosmosStore{container: container}
+}
+
+// Create saves a new trip.
+func (s *CosmosStore) Create(ctx context.Context, trip *cosmosdb.Trip) error {
+	return s.container.Create(ctx, trip.RiderID, trip)
+}
+
+// Get reads a trip with its ETag.
+func (s *CosmosStore) Get(ctx context.Context, riderID, tripID string) (*cosmosdb.Trip, error) {
+	var trip cosmosdb.Trip
+	etag, err := s.container.ReadWithETag(ctx, riderID, tripID, &trip)
+	if err != nil {
+		return nil, err
+	}
+	trip.ETag = etag
+	return &trip, nil
+}
+
+// Replace saves a trip conditioned on its ETag.
+func (s *CosmosStore) Replace(ctx context.Context, trip *cosmosdb.Trip) error {
+	etag, err := s.container.ReplaceIfMatch(ctx, trip.RiderID, trip.ID, trip.ETag, trip)
+	if err != nil {
+		return err
+	}
+	trip.ETag = etag
+	return nil
+}
+
+// DefaultMaxAttempts is the number of read-apply-replace attempts made by Update.
+const DefaultMaxAttempts = 3
+
+// Create initializes a new trip, saves it and records its creation.
+func (m *Machine) Create(ctx context.Context, store Store, trip *cosmosdb.Trip, actor *logging.AuditActor) error {
+	event := m.Init(trip, actor)
+	if err := store.Create(ctx, trip); err != nil {
+		return fmt.Errorf("failed to create trip: %w", err)
+	}
+	m.Record(ctx, event)
+	return nil
+}
+
+// Update reads a trip, applies the transition, saves it and records the
+// transition's audit event. When the trip was
+// modified concurrently the trip is re-read and the transition re-checked, so a
+// transition that became invalid (e.g. the trip was cancelled meanwhile) fails
+// with ErrInvalidTransition instead of overwriting the other change.
+func (m *Machine) Update(ctx context.Context, store Store, riderID, tripID string, t Transition) (*cosmosdb.Trip, error) {
+	for attempt := 1; ; attempt++ {
+		trip, err := store.Get(ctx, riderID, tripID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read trip: %w", err)
+		}
+		event, err := m.Apply(ctx, trip, t)
+		if err != nil {
+			return nil, err
+		}
+
+		err = store.Replace(ctx, trip)
+		if err == nil {
+			m.Record(ctx, event)
+			return trip, nil
+		}
+		if !errors.Is(err, database.ErrPreconditionFailed) || attempt >= DefaultMaxAttempts {
+			return nil, fmt.Errorf("failed to save trip: %w", err)
+		}
+	}
+}
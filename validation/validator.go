@@ -91,14 +91,16 @@ func validateUserType(fl validator.FieldLevel) bool {
 	return validUserTypes[fl.Field().String()]
 }
 
-// Trip statuses.
+// Trip statuses (see the trip package for the allowed transitions).
 var validTripStatuses = map[string]bool{
-	"requested":   true,
-	"accepted":    true,
-	"arriving":    true,
-	"in_progress": true,
-	"completed":   true,
-	"cancelled":   true,
+	"requested":       true,
+	"accepted":        true,
+	"arriving":        true, // legacy alias of driver_arrived
+	"driver_en_route": true,
+	"driver_arrived":  true,
+	"in_progress":     true,
+	"completed":       true,
+	"cancelled":       true,
 }
 
 func validateTripStatus(fl validator.FieldLevel) bool {